@@ -0,0 +1,46 @@
+// offchainhash berechnet den Hash einer Off-Chain-Datei wie die Oracles und gibt optional die
+// Argumente für VerifyOffChainData aus.
+//
+//	go run ./cmd/offchainhash <datei>
+//	go run ./cmd/offchainhash -dpp DPP_A_001 -ref <referenz> <datei>
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"dpp_transfer_chaincode/offchain"
+)
+
+func main() {
+	dppID := flag.String("dpp", "", "DPP-ID für die Argumente von VerifyOffChainData")
+	referenceID := flag.String("ref", "", "Referenz (offChainDataRef oder entryId), Standard: Dateipfad")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Verwendung: %s [-dpp <id> [-ref <referenz>]] <datei>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	hash, err := offchain.HashFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *dppID == "" {
+		fmt.Printf("%s  %s (%s)\n", hash, path, offchain.Algorithm)
+		return
+	}
+	ref := *referenceID
+	if ref == "" {
+		ref = path
+	}
+	args, _ := json.Marshal(map[string][]string{"Args": {"VerifyOffChainData", *dppID, ref, hash}})
+	fmt.Println(string(args))
+}
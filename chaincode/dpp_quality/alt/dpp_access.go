@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Zugriffskontrolle (ABAC) --------------------------- //
+//
+// Jede Contract-Funktion wird vor der Ausführung gegen die Berechtigungsmatrix geprüft
+// (GetBeforeTransaction). Die Rollen stammen aus dem Zertifikatsattribut "role" der
+// aufrufenden Identität, z.B. beim Registrieren an der CA: attrs "role=qa:ecert".
+// Mehrere Rollen werden kommagetrennt angegeben ("qa,oracle").
+//
+// Die Matrix regelt, welche Rolle (und optional welche MSP) eine Funktion aufrufen darf.
+// Eigentumsprüfungen (Eigentümer des DPP, Empfänger, Auslöser eines Rückrufs) stehen
+// zusätzlich in den jeweiligen Funktionen, weil sie vom Zustand des DPP abhängen.
+//
+// Die Matrix gilt für den ganzen Kanal. Ändern darf sie nur die steuernde Organisation
+// (GoverningMSP), nicht jeder Admin einer beliebigen MSP. Solange keine Matrix gespeichert ist,
+// ist das defaultGoverningMSP bzw. die Umgebungsvariable DPP_GOVERNING_MSP (auf allen Peers
+// gleich setzen); mit der ersten gespeicherten Matrix steht sie auf dem Ledger. Der Schlüssel der
+// Matrix erhält zusätzlich eine Endorsement Policy, die einen Peer der steuernden Organisation
+// verlangt, damit auch ein veränderter Chaincode auf fremden Peers die Matrix nicht ändern kann.
+
+const (
+	roleAttribute       = "role"
+	anyRole             = "*"
+	permissionMatrixKey = "ACL-PermissionMatrix"
+
+	defaultGoverningMSP = "Org1MSP"
+	governingMSPEnv     = "DPP_GOVERNING_MSP"
+)
+
+// FunctionPermission: Rollen (und optional MSPs), die eine Funktion aufrufen dürfen.
+// Roles ["*"] erlaubt jede Identität, leere MSPs erlauben jede MSP.
+type FunctionPermission struct {
+	Roles []string `json:"roles"`
+	MSPs  []string `json:"msps,omitempty" metadata:",optional"`
+}
+
+// PermissionMatrix: Auf dem Ledger gespeicherte Berechtigungen je Contract-Funktion.
+type PermissionMatrix struct {
+	Version      int                           `json:"version"`
+	Permissions  map[string]FunctionPermission `json:"permissions"`
+	GoverningMSP string                        `json:"governingMsp,omitempty" metadata:",optional"` // einzige MSP, die die Matrix ändern darf
+	UpdatedByMSP string                        `json:"updatedByMsp,omitempty" metadata:",optional"`
+	UpdatedAt    string                        `json:"updatedAt,omitempty"    metadata:",optional"`
+}
+
+// defaultPermissions gilt, solange keine Matrix auf dem Ledger gespeichert ist. Sie enthält
+// alle Contract-Funktionen; neue Funktionen müssen hier ergänzt werden.
+var defaultPermissions = map[string]FunctionPermission{
+	// Spezifikationskatalog
+	"PublishSpecificationSet": {Roles: []string{"qa"}},
+	"RetireSpecificationSet":  {Roles: []string{"qa"}},
+	"ListSpecificationSets":   {Roles: []string{anyRole}},
+	"QuerySpecificationSet":   {Roles: []string{anyRole}},
+	// DPP-Lebenszyklus
+	"CreateDPP":                             {Roles: []string{"qa", "production"}},
+	"RecordQualityData":                     {Roles: []string{"qa", "oracle"}},
+	"RecordQualityDataBatch":                {Roles: []string{"qa", "oracle"}},
+	"ReadPrivateQualityEntry":               {Roles: []string{"qa", "oracle"}},
+	"SetIntendedCustomer":                   {Roles: []string{"qa", "logistics"}},
+	"RequestConcession":                     {Roles: []string{"qa"}},
+	"ApproveConcession":                     {Roles: []string{"qa"}},
+	"DenyConcession":                        {Roles: []string{"qa"}},
+	"RecordRetest":                          {Roles: []string{"qa", "oracle"}},
+	"ReleaseBlockedDPP":                     {Roles: []string{"qa"}},
+	"RecordTransformation":                  {Roles: []string{"production", "qa"}},
+	"SplitDPP":                              {Roles: []string{"production", "logistics"}},
+	"TransferDPP":                           {Roles: []string{"logistics"}},
+	"AddTransportUpdate":                    {Roles: []string{"logistics", "oracle"}},
+	"AcknowledgeReceiptAndRecordInspection": {Roles: []string{"logistics", "qa"}},
+	"ClearTransportAlert":                   {Roles: []string{"qa"}},
+	"RejectDelivery":                        {Roles: []string{"logistics", "qa"}},
+	"AcknowledgeReturn":                     {Roles: []string{"logistics", "qa"}},
+	"InitiateRecall":                        {Roles: []string{"qa"}},
+	"CloseRecall":                           {Roles: []string{"qa"}},
+	// Abfragen
+	"QueryDPP":                       {Roles: []string{anyRole}},
+	"QueryDPPsByStatus":              {Roles: []string{anyRole}},
+	"QueryDPPsByOwner":               {Roles: []string{anyRole}},
+	"QueryDPPsByProductType":         {Roles: []string{anyRole}},
+	"QueryDPPsByBatch":               {Roles: []string{anyRole}},
+	"QueryDPPsByManufacturer":        {Roles: []string{anyRole}},
+	"QueryDPPsByProductionDateRange": {Roles: []string{anyRole}},
+	"GetDPPHistory":                  {Roles: []string{anyRole}},
+	"TraceUpstream":                  {Roles: []string{anyRole}},
+	"TraceDownstream":                {Roles: []string{anyRole}},
+	"QueryRecall":                    {Roles: []string{anyRole}},
+	"VerifyOffChainData":             {Roles: []string{anyRole}},
+	"ExportEPCISDocument":            {Roles: []string{anyRole}},
+	"QueryEvents":                    {Roles: []string{anyRole}},
+	"QueryDPPByDigitalLink":          {Roles: []string{anyRole}},
+	"ConvertEPCToDigitalLink":        {Roles: []string{anyRole}},
+	"ConvertDigitalLinkToEPC":        {Roles: []string{anyRole}},
+	// Verwaltung
+	"GetPermissionMatrix":     {Roles: []string{anyRole}},
+	"SetPermissionMatrix":     {Roles: []string{"admin"}},
+	"InitLedger":              {Roles: []string{"admin"}},
+	"RebuildEventIndex":       {Roles: []string{"admin"}},
+	"RebuildDigitalLinkIndex": {Roles: []string{"admin"}},
+}
+
+// GetBeforeTransaction: contractapi ruft die zurückgegebene Funktion vor jeder Transaktion auf.
+func (c *DPPQualityContract) GetBeforeTransaction() interface{} {
+	return c.checkAccess
+}
+
+// checkAccess: Prüft den Aufrufer gegen die Berechtigungsmatrix.
+func (c *DPPQualityContract) checkAccess(ctx contractapi.TransactionContextInterface) error {
+	nsFcn, _ := ctx.GetStub().GetFunctionAndParameters()
+	function := nsFcn[strings.LastIndex(nsFcn, ":")+1:]
+	if function == "" {
+		return nil
+	}
+	// contractapi akzeptiert Funktionsnamen mit kleinem Anfangsbuchstaben.
+	runes := []rune(function)
+	runes[0] = unicode.ToUpper(runes[0])
+	function = string(runes)
+
+	if _, known := defaultPermissions[function]; !known {
+		return nil // unbekannte Funktion: contractapi meldet "Function not found"
+	}
+
+	matrix, err := c.loadPermissionMatrix(ctx)
+	if err != nil {
+		return err
+	}
+	return authorize(ctx, matrix, function)
+}
+
+func authorize(ctx contractapi.TransactionContextInterface, matrix *PermissionMatrix, function string) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Zugriff verweigert: Client MSPID nicht ermittelbar: %v", err)
+	}
+	roles, err := callerRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	perm, ok := matrix.Permissions[function]
+	if !ok {
+		return fmt.Errorf("Zugriff verweigert: für %s ist in der Berechtigungsmatrix (v%d) keine Berechtigung konfiguriert", function, matrix.Version)
+	}
+	if len(perm.MSPs) > 0 && !containsString(perm.MSPs, mspID) {
+		return fmt.Errorf("Zugriff verweigert: %s darf von MSP %s nicht aufgerufen werden (erlaubt: [%s])", function, mspID, strings.Join(perm.MSPs, ", "))
+	}
+	if containsString(perm.Roles, anyRole) {
+		return nil
+	}
+	for _, role := range roles {
+		if containsString(perm.Roles, role) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Zugriff verweigert: %s erfordert eine der Rollen [%s], Aufrufer (%s) hat [%s]", function, strings.Join(perm.Roles, ", "), mspID, strings.Join(roles, ", "))
+}
+
+// callerRoles: Rollen aus dem Attribut "role" der aufrufenden Identität.
+func callerRoles(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("Zugriff verweigert: Attribut '%s' nicht lesbar: %v", roleAttribute, err)
+	}
+	if !found {
+		return []string{}, nil
+	}
+	var roles []string
+	for _, r := range strings.Split(value, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
+
+// requireRole: Die Aktion erfordert unabhängig von der Berechtigungsmatrix die angegebene Rolle.
+func requireRole(ctx contractapi.TransactionContextInterface, role, action string) error {
+	roles, err := callerRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsString(roles, role) {
+		return fmt.Errorf("Zugriff verweigert: %s erfordert die Rolle '%s', Aufrufer hat [%s]", action, role, strings.Join(roles, ", "))
+	}
+	return nil
+}
+
+// requireOwner: Nur der aktuelle Eigentümer (OwnerOrg) des DPP darf die Aktion ausführen.
+func requireOwner(ctx contractapi.TransactionContextInterface, dpp *DPP, action string) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if dpp.OwnerOrg != mspID {
+		return "", fmt.Errorf("Zugriff verweigert: nur der Eigentümer (%s) darf %s für DPP %s, Aufrufer ist %s", dpp.OwnerOrg, action, dpp.DppID, mspID)
+	}
+	return mspID, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *DPPQualityContract) loadPermissionMatrix(ctx contractapi.TransactionContextInterface) (*PermissionMatrix, error) {
+	data, err := ctx.GetStub().GetState(permissionMatrixKey)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen der Berechtigungsmatrix: %v", err)
+	}
+	if data == nil {
+		return &PermissionMatrix{Version: 0, Permissions: defaultPermissions, GoverningMSP: configuredGoverningMSP()}, nil
+	}
+	var matrix PermissionMatrix
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling der Berechtigungsmatrix: %v", err)
+	}
+	if matrix.GoverningMSP == "" {
+		matrix.GoverningMSP = configuredGoverningMSP() // vor Einführung von governingMsp gespeichert
+	}
+	return &matrix, nil
+}
+
+// configuredGoverningMSP: Steuernde Organisation, solange keine auf dem Ledger steht.
+func configuredGoverningMSP() string {
+	if msp := strings.TrimSpace(os.Getenv(governingMSPEnv)); msp != "" {
+		return msp
+	}
+	return defaultGoverningMSP
+}
+
+// GetPermissionMatrix: Aktuelle Berechtigungsmatrix (Version 0 = Standardmatrix, noch nichts gespeichert).
+func (c *DPPQualityContract) GetPermissionMatrix(ctx contractapi.TransactionContextInterface) (*PermissionMatrix, error) {
+	return c.loadPermissionMatrix(ctx)
+}
+
+// SetPermissionMatrix: Ersetzt die Berechtigungsmatrix. permissionsJSON ist ein Objekt
+// Funktionsname -> {"roles": [...], "msps": [...]}. Alle Contract-Funktionen müssen enthalten
+// sein, damit keine Funktion versehentlich gesperrt wird; SetPermissionMatrix selbst braucht
+// mindestens eine Rolle. Nur die steuernde Organisation (GoverningMSP) darf die Matrix ersetzen.
+func (c *DPPQualityContract) SetPermissionMatrix(ctx contractapi.TransactionContextInterface, permissionsJSON string) (*PermissionMatrix, error) {
+	var permissions map[string]FunctionPermission
+	if err := json.Unmarshal([]byte(permissionsJSON), &permissions); err != nil {
+		return nil, fmt.Errorf("Berechtigungen JSON fehlerhaft: %v", err)
+	}
+
+	var missing, unknown []string
+	for function := range defaultPermissions {
+		if _, ok := permissions[function]; !ok {
+			missing = append(missing, function)
+		}
+	}
+	for function := range permissions {
+		if _, ok := defaultPermissions[function]; !ok {
+			unknown = append(unknown, function)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unknown)
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unbekannte Funktionen in der Berechtigungsmatrix: [%s]", strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Berechtigungsmatrix unvollständig, es fehlen: [%s]", strings.Join(missing, ", "))
+	}
+	if len(permissions["SetPermissionMatrix"].Roles) == 0 {
+		return nil, fmt.Errorf("SetPermissionMatrix benötigt mindestens eine Rolle, sonst ist die Matrix nicht mehr änderbar")
+	}
+
+	current, err := c.loadPermissionMatrix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if clientMSPID != current.GoverningMSP {
+		return nil, fmt.Errorf("Zugriff verweigert: nur die steuernde Organisation %s darf die Berechtigungsmatrix ändern, Aufrufer ist %s", current.GoverningMSP, clientMSPID)
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &PermissionMatrix{
+		Version:      current.Version + 1,
+		Permissions:  permissions,
+		GoverningMSP: current.GoverningMSP,
+		UpdatedByMSP: clientMSPID,
+		UpdatedAt:    clk.timestamp(),
+	}
+	data, err := json.Marshal(matrix)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Marshalling der Berechtigungsmatrix: %v", err)
+	}
+	if err := ctx.GetStub().PutState(permissionMatrixKey, data); err != nil {
+		return nil, err
+	}
+	if err := setGoverningEndorsement(ctx, matrix.GoverningMSP); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[SetPermissionMatrix-INFO] Berechtigungsmatrix v%d durch %s gespeichert.\n", matrix.Version, clientMSPID)
+	return matrix, nil
+}
+
+// setGoverningEndorsement: Änderungen am Matrix-Schlüssel erfordern die Endorsement eines Peers
+// der steuernden Organisation (Key-Level Endorsement Policy).
+func setGoverningEndorsement(ctx contractapi.TransactionContextInterface, governingMSP string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	if err := ep.AddOrgs(statebased.RoleTypePeer, governingMSP); err != nil {
+		return fmt.Errorf("Endorsement Policy für die Berechtigungsmatrix nicht erstellbar: %v", err)
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return fmt.Errorf("Endorsement Policy für die Berechtigungsmatrix nicht erstellbar: %v", err)
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(permissionMatrixKey, policy); err != nil {
+		return fmt.Errorf("Endorsement Policy für die Berechtigungsmatrix nicht gesetzt: %v", err)
+	}
+	return nil
+}
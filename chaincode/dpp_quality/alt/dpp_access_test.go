@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// accessEnv ruft den Contract wie der Peer über contractapi auf, also mit checkAccess vor
+// jeder Funktion. Die Identität ist ein Zertifikat mit role-Attribut wie von der Fabric-CA.
+type accessEnv struct {
+	t    *testing.T
+	stub *shimtest.MockStub
+	n    int
+}
+
+func newAccessEnv(t *testing.T) *accessEnv {
+	cc, err := contractapi.NewChaincode(&DPPQualityContract{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &accessEnv{t: t, stub: shimtest.NewMockStub("dpp", cc)}
+}
+
+// invoke ruft function als Identität von mspID mit den Rollen roles ("" = ohne Attribut) auf
+// und liefert die Fehlermeldung ("" bei Erfolg).
+func (a *accessEnv) invoke(mspID, roles, function string, args ...string) string {
+	a.t.Helper()
+	a.stub.Creator = testCreator(a.t, mspID, roles)
+	a.n++
+	callArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		callArgs = append(callArgs, []byte(arg))
+	}
+	resp := a.stub.MockInvoke(fmt.Sprintf("acc%03d", a.n), callArgs)
+	if resp.Status >= 400 {
+		return resp.Message
+	}
+	return ""
+}
+
+// testCreator: Serialisierte Identität mit selbstsigniertem Zertifikat und Attributen im Format
+// der Fabric-CA (Erweiterung 1.2.3.4.5.6.7.8.1).
+func testCreator(t *testing.T, mspID, roles string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "user@" + mspID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if roles != "" {
+		attrs, _ := json.Marshal(map[string]map[string]string{"attrs": {roleAttribute: roles}})
+		template.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrs}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creator
+}
+
+func TestCheckAccessDefaultMatrix(t *testing.T) {
+	a := newAccessEnv(t)
+	tests := []struct {
+		msp, roles, function string
+		args                 []string
+		denied               string // Teil der Fehlermeldung, leer = zugelassen
+	}{
+		{"Org1MSP", "qa", "PublishSpecificationSet", []string{"PT-A", retestSpecs, "", ""}, ""},
+		{"Org1MSP", "", "PublishSpecificationSet", []string{"PT-A", retestSpecs, "", ""}, "erfordert eine der Rollen [qa]"},
+		{"Org1MSP", "logistics", "PublishSpecificationSet", []string{"PT-A", retestSpecs, "", ""}, "erfordert eine der Rollen [qa]"},
+		{"Org1MSP", "logistics, qa", "publishSpecificationSet", []string{"PT-A", retestSpecs, "", ""}, ""},
+		{"Org2MSP", "", "ListSpecificationSets", []string{"PT-A"}, ""},
+		{"Org2MSP", "qa", "RebuildEventIndex", []string{"X"}, "erfordert eine der Rollen [admin]"},
+	}
+	for _, tt := range tests {
+		msg := a.invoke(tt.msp, tt.roles, tt.function, tt.args...)
+		switch {
+		case tt.denied == "" && strings.Contains(msg, "Zugriff verweigert"):
+			t.Errorf("%s als %s [%s]: abgewiesen: %s", tt.function, tt.msp, tt.roles, msg)
+		case tt.denied != "" && !strings.Contains(msg, tt.denied):
+			t.Errorf("%s als %s [%s]: Meldung %q, erwartet %q", tt.function, tt.msp, tt.roles, msg, tt.denied)
+		}
+	}
+}
+
+func TestSetPermissionMatrixGoverningMSP(t *testing.T) {
+	a := newAccessEnv(t)
+	permissions := map[string]FunctionPermission{}
+	for function, perm := range defaultPermissions {
+		permissions[function] = perm
+	}
+	permissions["PublishSpecificationSet"] = FunctionPermission{Roles: []string{"qa"}, MSPs: []string{"Org1MSP"}}
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := a.invoke("Org2MSP", "admin", "SetPermissionMatrix", string(data)); !strings.Contains(msg, "nur die steuernde Organisation Org1MSP") {
+		t.Errorf("Admin von Org2MSP: Meldung %q", msg)
+	}
+	if msg := a.invoke("Org1MSP", "qa", "SetPermissionMatrix", string(data)); !strings.Contains(msg, "erfordert eine der Rollen [admin]") {
+		t.Errorf("Org1MSP ohne admin: Meldung %q", msg)
+	}
+	if msg := a.invoke("Org1MSP", "admin", "SetPermissionMatrix", string(data)); msg != "" {
+		t.Fatalf("Admin von Org1MSP: %s", msg)
+	}
+	if ep, _ := a.stub.GetStateValidationParameter(permissionMatrixKey); len(ep) == 0 {
+		t.Errorf("keine Endorsement Policy für die Berechtigungsmatrix")
+	}
+
+	if msg := a.invoke("Org2MSP", "qa", "PublishSpecificationSet", "PT-B", retestSpecs, "", ""); !strings.Contains(msg, "darf von MSP Org2MSP nicht aufgerufen werden") {
+		t.Errorf("qa von Org2MSP nach Einschränkung: Meldung %q", msg)
+	}
+	if msg := a.invoke("Org1MSP", "qa", "PublishSpecificationSet", "PT-B", retestSpecs, "", ""); msg != "" {
+		t.Errorf("qa von Org1MSP nach Einschränkung: %s", msg)
+	}
+	// Auch nach der ersten gespeicherten Matrix bleibt Org2MSP ausgeschlossen.
+	if msg := a.invoke("Org2MSP", "admin", "SetPermissionMatrix", string(data)); !strings.Contains(msg, "nur die steuernde Organisation Org1MSP") {
+		t.Errorf("zweiter Versuch von Org2MSP: Meldung %q", msg)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Batch-Erfassung von Qualitätsdaten --------------------------- //
+
+// RecordQualityDataBatch: Erfasst mehrere Qualitätseinträge (JSON-Array von QualityEntry) in
+// einer Transaktion. Alle Einträge werden bewertet, die Prüfstände ihrer Tests nachgeführt und
+// ein gemeinsames Inspektions-Event geschrieben; der DPP-Kopf bleibt unverändert. Ist ein
+// Eintrag fehlerhaft, schlägt die Transaktion fehl und kein Eintrag wird gespeichert.
+func (c *DPPQualityContract) RecordQualityDataBatch(ctx contractapi.TransactionContextInterface, dppID string, entriesJSON string, siteGLN string) error {
+	fmt.Printf("[RecordQualityDataBatch-DEBUG] Entry: dppID=%s, siteGLN=%s\n", dppID, siteGLN)
+	if err := validateSiteGLN("siteGLN", siteGLN); err != nil {
+		return err
+	}
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	clientMSPID, err := requireOwner(ctx, dpp, "Qualitätsdaten erfassen")
+	if err != nil {
+		return err
+	}
+	if err := dpp.checkNotTerminal("Qualitätsdaten erfasst"); err != nil {
+		return err
+	}
+
+	var entries []QualityEntry
+	if err := json.Unmarshal([]byte(entriesJSON), &entries); err != nil {
+		return fmt.Errorf("entriesJSON fehlerhaft (erwartet JSON-Array von QualityEntry): %v", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("entriesJSON enthält keine Einträge")
+	}
+	seen := make(map[string]bool, len(entries))
+	for i := range entries {
+		qe := &entries[i]
+		if qe.TestName == "" {
+			return fmt.Errorf("Eintrag %d ohne testName", i+1)
+		}
+		if seen[qe.TestName] {
+			return fmt.Errorf("Test '%s' ist im Batch mehrfach enthalten", qe.TestName)
+		}
+		seen[qe.TestName] = true
+		if err := anchorOffChainHash(qe); err != nil {
+			return fmt.Errorf("Eintrag %d (%s): %v", i+1, qe.TestName, err)
+		}
+	}
+
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	var testNames []string
+	var alerts []map[string]interface{}
+	var sensors []*SensorElement
+	allPass := true
+	for i := range entries {
+		qe := &entries[i]
+		if qe.Timestamp == "" {
+			qe.Timestamp = clk.timestamp()
+		}
+		if qe.PerformingOrg == "" {
+			qe.PerformingOrg = clientMSPID
+		}
+		// Vom Contract verwaltete Felder werden nicht vom Client übernommen.
+		qe.clearManagedFields()
+		if _, err := dpp.applyQualityEntry(ctx, clk, qe); err != nil {
+			return err
+		}
+
+		testNames = append(testNames, fmt.Sprintf("%s (%s)", qe.TestName, qe.EvaluationOutcome))
+		sensors = append(sensors, qualitySensorElement(dpp.specFor(qe.TestName), *qe))
+		if qe.EvaluationOutcome != OutcomePass {
+			allPass = false
+		}
+		if qe.EvaluationOutcome.isNonConformant() {
+			alerts = append(alerts, qualityAlertFields(*qe))
+		}
+	}
+
+	disposition := "urn:epcglobal:cbv:disp:active"
+	switch {
+	case len(alerts) > 0:
+		disposition = "urn:epcglobal:cbv:disp:non_conformant"
+	case allPass:
+		disposition = "urn:epcglobal:cbv:disp:conformant"
+	}
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("qc-batch"),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:inspecting",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         disposition,
+		ReadPoint:           siteLocation(siteGLN),
+		BizLocation:         siteLocation(siteGLN),
+		SensorElementList:   sensorElements(sensors...),
+		Extensions:          map[string]interface{}{"recordedQualityData": entries},
+	})
+
+	if len(alerts) > 0 {
+		// Nur ein Chaincode-Event pro Transaktion: alle nicht konformen Ergebnisse in einem QualityAlert.
+		alertPayload := qualityAlertHeader(dpp)
+		alertPayload["alerts"] = alerts
+		alertBytes, _ := json.Marshal(alertPayload)
+		if err := ctx.GetStub().SetEvent("QualityAlert", alertBytes); err != nil {
+			return fmt.Errorf("Fehler beim Setzen des QualityAlert-Events: %v", err)
+		}
+	}
+
+	fmt.Printf("[RecordQualityDataBatch-INFO] %d Qualitätseinträge für DPP %s erfasst: %s\n", len(entries), dppID, strings.Join(testNames, ", "))
+	return c.saveDPP(ctx, dpp)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// batchEnv: DPP B1 mit zwei Pflichtprüfungen (MFI, Dichte) und der optionalen Prüfung Farbe.
+func batchEnv(t *testing.T) (*testEnv, *DPPQualityContract) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-B", `[
+		{"testName":"MFI","isNumeric":true,"lowerLimit":10,"upperLimit":20,"unit":"g/10min","isMandatory":true},
+		{"testName":"Dichte","isNumeric":true,"lowerLimit":0.9,"upperLimit":1.1,"unit":"g/cm3","isMandatory":true},
+		{"testName":"Farbe","isNumeric":false,"expectedValue":"natur","isMandatory":false}
+	]`, "", "")
+	e.must(err)
+	_, err = c.CreateDPP(e.ctx("Org1MSP"), "B1", "urn:epc:id:sgtin:4012345.011111.8001", "PT-B", "4012345000016", "L1", "2025-06-01", 1000, "kg", 0)
+	e.must(err)
+	drainChaincodeEvents(e)
+	return e, c
+}
+
+// drainChaincodeEvents liefert die Namen und Payloads der seit dem letzten Aufruf gesetzten Events.
+func drainChaincodeEvents(e *testEnv) map[string][][]byte {
+	events := map[string][][]byte{}
+	for {
+		select {
+		case evt := <-e.stub.ChaincodeEventsChannel:
+			events[evt.EventName] = append(events[evt.EventName], evt.Payload)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRecordQualityDataBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     string
+		wantStatus  DPPStatus
+		disposition string
+		sensors     int // numerische Ergebnisse als sensorElement
+		alerts      int // Anzahl Ergebnisse im QualityAlert, 0 = kein Event
+	}{
+		{"alle Pflichtprüfungen bestanden",
+			`[{"testName":"MFI","result":"15","unit":"g/10min"},{"testName":"Dichte","result":"1.0","unit":"g/cm3"}]`,
+			StatusReleased, "urn:epcglobal:cbv:disp:conformant", 2, 0},
+		{"Abweichung und Fehler",
+			`[{"testName":"MFI","result":"15","unit":"g/10min"},{"testName":"Dichte","result":"1.5","unit":"g/cm3"},{"testName":"Farbe","result":"blau"}]`,
+			StatusBlocked, "urn:epcglobal:cbv:disp:non_conformant", 2, 2},
+		{"Pflichtprüfung offen",
+			`[{"testName":"MFI","result":"15","unit":"g/10min"},{"testName":"Farbe","result":"natur"}]`,
+			StatusAwaitingMandatoryChecks, "urn:epcglobal:cbv:disp:conformant", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, c := batchEnv(t)
+			before, err := c.QueryDPP(e.ctx("Org1MSP"), "B1")
+			e.must(err)
+			header := e.stub.State[dppPrefix+"B1"]
+			e.must(c.RecordQualityDataBatch(e.ctx("Org1MSP"), "B1", tt.entries, "4012345000016"))
+			dpp, err := c.QueryDPP(e.ctx("Org1MSP"), "B1")
+			e.must(err)
+
+			var entries []QualityEntry
+			e.must(json.Unmarshal([]byte(tt.entries), &entries))
+			if len(dpp.Quality) != len(entries) {
+				t.Errorf("%d Einträge gespeichert, erwartet %d", len(dpp.Quality), len(entries))
+			}
+			if dpp.Status != tt.wantStatus {
+				t.Errorf("Status %s, erwartet %s", dpp.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == StatusReleased && len(dpp.OpenMandatoryChecks) != 0 {
+				t.Errorf("offene Pflichtprüfungen %v", dpp.OpenMandatoryChecks)
+			}
+			// Der Batch schreibt nur Prüfstände und Einträge; der Status wird beim Lesen abgeleitet.
+			if !bytes.Equal(e.stub.State[dppPrefix+"B1"], header) {
+				t.Errorf("Kopf von DPP B1 durch den Batch geändert")
+			}
+			if len(dpp.StatusHistory) != len(before.StatusHistory) {
+				t.Errorf("unerwartete Statusänderungen %+v", dpp.StatusHistory[len(before.StatusHistory):])
+			}
+
+			var inspections []EPCISEvent
+			for _, evt := range dpp.EPCISEvents[len(before.EPCISEvents):] {
+				if evt.BizStep == "urn:epcglobal:cbv:bizstep:inspecting" {
+					inspections = append(inspections, evt)
+				}
+			}
+			if len(inspections) != 1 {
+				t.Fatalf("%d Inspektions-Events, erwartet eines", len(inspections))
+			}
+			if evt := inspections[0]; evt.Disposition != tt.disposition || len(evt.SensorElementList) != tt.sensors {
+				t.Errorf("Event %s: Disposition %s, %d Sensor-Elemente; erwartet %s, %d", evt.EventID, evt.Disposition, len(evt.SensorElementList), tt.disposition, tt.sensors)
+			}
+
+			events := drainChaincodeEvents(e)
+			if tt.alerts == 0 {
+				if len(events["QualityAlert"]) != 0 {
+					t.Errorf("unerwarteter QualityAlert")
+				}
+				return
+			}
+			if len(events["QualityAlert"]) != 1 {
+				t.Fatalf("%d QualityAlert-Events, erwartet eines", len(events["QualityAlert"]))
+			}
+			var payload struct {
+				Alerts []map[string]interface{} `json:"alerts"`
+			}
+			e.must(json.Unmarshal(events["QualityAlert"][0], &payload))
+			if len(payload.Alerts) != tt.alerts {
+				t.Errorf("%d Ergebnisse im QualityAlert, erwartet %d", len(payload.Alerts), tt.alerts)
+			}
+		})
+	}
+}
+
+func TestRecordQualityDataBatchRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries string
+		wantErr string
+	}{
+		{"Hash ungültig", `[{"testName":"MFI","result":"15","unit":"g/10min"},{"testName":"Dichte","result":"1.0","unit":"g/cm3","offChainDataHash":"xyz"}]`, "Eintrag 2 (Dichte)"},
+		{"Test doppelt", `[{"testName":"MFI","result":"15","unit":"g/10min"},{"testName":"MFI","result":"16","unit":"g/10min"}]`, "mehrfach"},
+		{"ohne testName", `[{"testName":"MFI","result":"15","unit":"g/10min"},{"result":"1.0"}]`, "Eintrag 2 ohne testName"},
+		{"leer", `[]`, "keine Einträge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, c := batchEnv(t)
+			state := make(map[string][]byte, len(e.stub.State))
+			for k, v := range e.stub.State {
+				state[k] = v
+			}
+			err := c.RecordQualityDataBatch(e.ctx("Org1MSP"), "B1", tt.entries, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Fehler %v, erwartet %q", err, tt.wantErr)
+			}
+			// Alles oder nichts: auch der gültige erste Eintrag wird nicht gespeichert.
+			if len(e.stub.State) != len(state) {
+				t.Errorf("%d Schlüssel nach dem Fehler, vorher %d", len(e.stub.State), len(state))
+			}
+			for k, v := range state {
+				if !bytes.Equal(e.stub.State[k], v) {
+					t.Errorf("Schlüssel %s verändert", k)
+				}
+			}
+			if events := drainChaincodeEvents(e); len(events) != 0 {
+				t.Errorf("Events trotz Fehler: %v", events)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Prüfstände je Test --------------------------- //
+//
+// Der Stand jeder Prüfung liegt unter einem eigenen Schlüssel DPP~check~<dppId>~<testName>.
+// RecordQualityData, RecordQualityDataBatch und RecordRetest lesen und schreiben nur die
+// Prüfstände der erfassten Tests, nicht den DPP-Kopf. Gleichzeitige Einreichungen für
+// verschiedene Tests desselben DPP berühren damit disjunkte Schlüssel und scheitern nicht an
+// der MVCC-Prüfung.
+//
+// OpenMandatoryChecks, FailedTests, DeviationTests, ersetzte Sonderfreigaben und der Status
+// der Qualitätsphasen werden beim Lesen aus den Prüfständen abgeleitet (applyChecks). Im Kopf
+// steht in den Qualitätsphasen der zuletzt übernommene Status: Transaktionen, die vom Status
+// abhängen (TransferDPP, SplitDPP, Sonderfreigaben, …), übernehmen den abgeleiteten Status mit
+// Eintrag in der StatusHistory (syncStatus). Ein Übergang, der sich allein aus Prüfergebnissen
+// ergibt, erscheint in der StatusHistory daher erst mit der nächsten solchen Transaktion.
+
+// statusFromChecksReason: Begründung in der StatusHistory, wenn syncStatus den abgeleiteten
+// Status übernimmt.
+const statusFromChecksReason = "Status aus den Prüfständen übernommen"
+
+// TestCheck: Prüfstand eines Tests. Critical/Deviation gelten seit dem letzten Retest des
+// Tests; Closed und Blocking bleiben bestehen.
+type TestCheck struct {
+	TestName         string            `json:"testName"`
+	Closed           bool              `json:"closed,omitempty"`           // Pflichtprüfung erfüllt (PASS oder genehmigte Sonderfreigabe)
+	Critical         bool              `json:"critical,omitempty"`         // kritisches Ergebnis (FAIL, INVALID_FORMAT, UNIT_MISMATCH)
+	Deviation        bool              `json:"deviation,omitempty"`        // Abweichung (DEVIATION_LOW/HIGH)
+	Blocking         bool              `json:"blocking,omitempty"`         // hat den DPP gesperrt, bis ReleaseBlockedDPP die Sperre aufhebt
+	DeviationEntryID string            `json:"deviationEntryId,omitempty"` // jüngste Abweichung; Sonderfreigaben gelten nur für sie
+	RetestEntryID    string            `json:"retestEntryId,omitempty"`    // jüngster Retest; ältere abgelehnte Sonderfreigaben sind ersetzt
+	LastEntryID      string            `json:"lastEntryId,omitempty"`
+	LastOutcome      EvaluationOutcome `json:"lastOutcome,omitempty"`
+}
+
+// initChecks: Neuer DPP, noch ohne Prüfstände.
+func (dpp *DPP) initChecks() {
+	dpp.CheckKeys = true
+	dpp.checks = map[string]*TestCheck{}
+	dpp.checksLoaded = true
+}
+
+// checkFor liefert den Prüfstand eines Tests; nur dessen Schlüssel gelangt ins Read-Set.
+func (dpp *DPP) checkFor(ctx contractapi.TransactionContextInterface, testName string) (*TestCheck, error) {
+	if check, ok := dpp.checks[testName]; ok {
+		return check, nil
+	}
+	if dpp.checks == nil {
+		dpp.checks = map[string]*TestCheck{}
+	}
+	check := &TestCheck{TestName: testName}
+	if !dpp.checksLoaded {
+		key, err := ctx.GetStub().CreateCompositeKey(checkObjectType, []string{dpp.DppID, testName})
+		if err != nil {
+			return nil, err
+		}
+		data, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("Fehler beim Lesen des Prüfstands '%s' von DPP %s: %v", testName, dpp.DppID, err)
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, check); err != nil {
+				return nil, fmt.Errorf("Fehler beim Unmarshalling des Prüfstands '%s' von DPP %s: %v", testName, dpp.DppID, err)
+			}
+		}
+	}
+	dpp.checks[testName] = check
+	return check, nil
+}
+
+// queueCheck merkt einen geänderten Prüfstand zum Schreiben vor. Der Wert wird erst in saveDPP
+// serialisiert, mehrfache Änderungen in einer Transaktion ergeben einen Schreibvorgang.
+func (dpp *DPP) queueCheck(check *TestCheck) {
+	for _, rec := range dpp.pending {
+		if rec.objectType == checkObjectType && rec.seq == check.TestName {
+			return
+		}
+	}
+	dpp.pending = append(dpp.pending, pendingRecord{objectType: checkObjectType, seq: check.TestName, value: check})
+}
+
+// checkNames: Tests mit Prüfstand, sortiert (deterministische Reihenfolge für Schreibvorgänge).
+func (dpp *DPP) checkNames() []string {
+	names := make([]string, 0, len(dpp.checks))
+	for name := range dpp.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadChecks liest alle Prüfstände des DPP und leitet die Zusammenfassungen ab. In dieser
+// Transaktion bereits geänderte Prüfstände haben Vorrang. Der Range-Read gelangt ins Read-Set;
+// Oracle-Erfassungen rufen loadChecks daher nicht auf.
+func (c *DPPQualityContract) loadChecks(ctx contractapi.TransactionContextInterface, dpp *DPP) error {
+	if !dpp.checksLoaded {
+		if dpp.checks == nil {
+			dpp.checks = map[string]*TestCheck{}
+		}
+		if err := forEachRecordKey(ctx, checkObjectType, dpp.DppID, func(testName string, data []byte) error {
+			if _, ok := dpp.checks[testName]; ok {
+				return nil
+			}
+			var check TestCheck
+			if err := json.Unmarshal(data, &check); err != nil {
+				return err
+			}
+			dpp.checks[testName] = &check
+			return nil
+		}); err != nil {
+			return fmt.Errorf("Fehler beim Lesen der Prüfstände von DPP %s: %v", dpp.DppID, err)
+		}
+		dpp.checksLoaded = true
+	}
+	dpp.applyChecks()
+	return nil
+}
+
+// deriveStatus setzt für lesende Aufrufe den aus den Prüfständen abgeleiteten Status, ohne
+// Eintrag in der StatusHistory.
+func (c *DPPQualityContract) deriveStatus(ctx contractapi.TransactionContextInterface, dpp *DPP) error {
+	if err := c.loadChecks(ctx, dpp); err != nil {
+		return err
+	}
+	dpp.Status = dpp.currentStatus()
+	return nil
+}
+
+// syncStatus übernimmt in schreibenden Transaktionen, die vom Status abhängen, den aus den
+// Prüfständen abgeleiteten Status in den Kopf (mit Eintrag in der StatusHistory).
+func (c *DPPQualityContract) syncStatus(ctx contractapi.TransactionContextInterface, clk *txClock, dpp *DPP) error {
+	if err := c.loadChecks(ctx, dpp); err != nil {
+		return err
+	}
+	return dpp.recalculateOverallStatus(ctx, clk, statusFromChecksReason)
+}
+
+// applyChecks leitet OpenMandatoryChecks, FailedTests und DeviationTests aus den Prüfständen ab
+// und markiert Sonderfreigaben, deren Abweichung durch eine neuere ersetzt wurde, als Superseded.
+func (dpp *DPP) applyChecks() {
+	dpp.OpenMandatoryChecks, dpp.FailedTests, dpp.DeviationTests = nil, nil, nil
+	var names []string
+	for _, spec := range dpp.Specifications {
+		check := dpp.checks[spec.TestName]
+		if spec.IsMandatory && (check == nil || !check.Closed) {
+			dpp.OpenMandatoryChecks = append(dpp.OpenMandatoryChecks, spec.TestName)
+		}
+		if check != nil {
+			names = append(names, spec.TestName)
+		}
+	}
+	for _, name := range dpp.checkNames() {
+		if dpp.specFor(name) == nil {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		check := dpp.checks[name]
+		if check.Critical {
+			dpp.FailedTests = append(dpp.FailedTests, name)
+		}
+		if check.Deviation {
+			dpp.DeviationTests = append(dpp.DeviationTests, name)
+		}
+	}
+
+	for i := range dpp.Concessions {
+		con := &dpp.Concessions[i]
+		check := dpp.checks[con.TestName]
+		if check == nil {
+			continue
+		}
+		switch con.Status {
+		case ConcessionRequested, ConcessionApproved:
+			if con.EntryID != check.DeviationEntryID {
+				con.Status = ConcessionSuperseded
+			}
+		case ConcessionDenied:
+			if check.RetestEntryID != "" && con.EntryID < check.RetestEntryID {
+				con.Status = ConcessionSuperseded
+			}
+		}
+	}
+}
+
+// hasBlockingCheck: Ein kritisches Ergebnis hat den DPP gesperrt und die Sperre ist nicht aufgehoben.
+func (dpp *DPP) hasBlockingCheck() bool {
+	for _, check := range dpp.checks {
+		if check.Blocking {
+			return true
+		}
+	}
+	return false
+}
+
+// currentStatus: In den Qualitätsphasen der aus den Prüfständen abgeleitete Status, sonst der
+// gespeicherte. Freigegebene DPPs bekommen keine offenen Pflichtprüfungen mehr.
+func (dpp *DPP) currentStatus() DPPStatus {
+	if !dpp.Status.isQualityPhase() {
+		return dpp.Status
+	}
+	target := dpp.derivedQualityStatus()
+	if target == StatusAwaitingMandatoryChecks && dpp.Status.isReleased() {
+		return dpp.Status
+	}
+	return target
+}
+
+// migrateLegacyChecks überführt die Zusammenfassungen älterer Köpfe (OpenMandatoryChecks,
+// FailedTests, DeviationTests, eingebettete Qualitätseinträge) beim nächsten Schreiben in
+// Prüfstände unter eigenen Schlüsseln.
+func (dpp *DPP) migrateLegacyChecks() {
+	if dpp.CheckKeys {
+		return
+	}
+	dpp.CheckKeys = true
+	dpp.checks = map[string]*TestCheck{}
+	check := func(testName string) *TestCheck {
+		if dpp.checks[testName] == nil {
+			dpp.checks[testName] = &TestCheck{TestName: testName}
+		}
+		return dpp.checks[testName]
+	}
+	for _, spec := range dpp.Specifications {
+		if spec.IsMandatory {
+			check(spec.TestName).Closed = !containsString(dpp.OpenMandatoryChecks, spec.TestName)
+		}
+	}
+	for _, qe := range dpp.Quality {
+		if qe.EvaluationOutcome.isCritical() {
+			check(qe.TestName).Critical, check(qe.TestName).Blocking = true, true
+		}
+		if qe.EvaluationOutcome.isDeviation() {
+			check(qe.TestName).Deviation, check(qe.TestName).DeviationEntryID = true, qe.EntryID
+		}
+	}
+	for _, testName := range dpp.FailedTests {
+		check(testName).Critical, check(testName).Blocking = true, true
+	}
+	for _, testName := range dpp.DeviationTests {
+		check(testName).Deviation = true
+	}
+	for _, con := range dpp.Concessions {
+		if con.Status == ConcessionRequested || con.Status == ConcessionApproved {
+			check(con.TestName).DeviationEntryID = con.EntryID
+		}
+	}
+	for _, name := range dpp.checkNames() {
+		dpp.queueCheck(dpp.checks[name])
+	}
+}
+
+// recordEvaluatedEntry führt zu einem vorgemerkten, bewerteten Eintrag den Prüfstand seines
+// Tests nach und schließt bei PASS die zugehörige Pflichtprüfung.
+func (dpp *DPP) recordEvaluatedEntry(ctx contractapi.TransactionContextInterface, qe *QualityEntry, spec *QualitySpecification) error {
+	check, err := dpp.checkFor(ctx, qe.TestName)
+	if err != nil {
+		return err
+	}
+	if qe.Supersedes != "" {
+		// Der Retest ist der jüngste gültige Eintrag des Tests und bestimmt dessen Prüfstand neu.
+		check.Critical, check.Deviation, check.DeviationEntryID = false, false, ""
+		check.RetestEntryID = qe.EntryID
+	}
+	if qe.EvaluationOutcome.isCritical() {
+		check.Critical, check.Blocking = true, true
+	}
+	if qe.EvaluationOutcome.isDeviation() {
+		// Sonderfreigaben früherer Abweichungen gelten damit als ersetzt (siehe applyChecks).
+		check.Deviation, check.DeviationEntryID = true, qe.EntryID
+	}
+	if spec != nil && spec.IsMandatory && qe.EvaluationOutcome == OutcomePass {
+		check.Closed = true
+	}
+	check.LastEntryID, check.LastOutcome = qe.EntryID, qe.EvaluationOutcome
+	dpp.queueCheck(check)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Zwei Labore erfassen gleichzeitig Ergebnisse verschiedener Tests desselben DPP. Keine der
+// beiden Transaktionen darf einen Key schreiben, den die andere gelesen hat (MVCC-Konflikt);
+// der Status ergibt sich beim Lesen aus beiden Prüfständen.
+func TestConcurrentChecksOnDifferentTests(t *testing.T) {
+	tests := []struct {
+		name          string
+		first, second string
+		wantStatus    DPPStatus
+		wantOpen      []string
+		wantFailed    []string
+		wantDeviation []string
+	}{
+		{"beide bestanden",
+			qualityJSON("MFI", "15", "g/10min"), qualityJSON("Dichte", "1.0", "g/cm3"),
+			StatusReleased, nil, nil, nil},
+		{"Abweichung",
+			qualityJSON("MFI", "15", "g/10min"), qualityJSON("Dichte", "1.5", "g/cm3"),
+			StatusAwaitingMandatoryChecks, []string{"Dichte"}, nil, []string{"Dichte"}},
+		{"kritischer Fehler",
+			qualityJSON("Farbe", "blau", ""), qualityJSON("MFI", "15", "g/10min"),
+			StatusBlocked, []string{"Dichte"}, []string{"Farbe"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, c := batchEnv(t)
+			header := e.stub.State[dppPrefix+"B1"]
+
+			e.must(c.RecordQualityData(e.ctx("Org1MSP"), "B1", tt.first, ""))
+			first := e.stub.rwsets[e.stub.TxID]
+			e.must(c.RecordQualityData(e.ctx("Org1MSP"), "B1", tt.second, ""))
+			second := e.stub.rwsets[e.stub.TxID]
+
+			if keys := second.conflictsWith(first); len(keys) != 0 {
+				t.Errorf("zweite Transaktion liest von der ersten geschriebene Keys %v", keys)
+			}
+			if keys := first.conflictsWith(second); len(keys) != 0 {
+				t.Errorf("erste Transaktion liest von der zweiten geschriebene Keys %v", keys)
+			}
+			if first.writes[dppPrefix+"B1"] || second.writes[dppPrefix+"B1"] {
+				t.Errorf("Kopf von DPP B1 geschrieben")
+			}
+			if string(e.stub.State[dppPrefix+"B1"]) != string(header) {
+				t.Errorf("Kopf von DPP B1 geändert")
+			}
+
+			dpp, err := c.QueryDPP(e.ctx("Org1MSP"), "B1")
+			e.must(err)
+			if dpp.Status != tt.wantStatus {
+				t.Errorf("Status %s, erwartet %s", dpp.Status, tt.wantStatus)
+			}
+			for _, list := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"OpenMandatoryChecks", dpp.OpenMandatoryChecks, tt.wantOpen},
+				{"FailedTests", dpp.FailedTests, tt.wantFailed},
+				{"DeviationTests", dpp.DeviationTests, tt.wantDeviation},
+			} {
+				if len(list.got) != 0 || len(list.want) != 0 {
+					if !reflect.DeepEqual(list.got, list.want) {
+						t.Errorf("%s %v, erwartet %v", list.name, list.got, list.want)
+					}
+				}
+			}
+		})
+	}
+}
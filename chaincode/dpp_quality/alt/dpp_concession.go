@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Sonderfreigaben (Concessions) --------------------------- //
+//
+// Ein Ergebnis außerhalb der Grenzen (DEVIATION_LOW/HIGH) gibt den DPP nicht mehr automatisch
+// frei. Der Eigentümer legt mit SetIntendedCustomer den Kunden fest und beantragt je
+// abweichendem Test eine Sonderfreigabe (RequestConcession). Der Kunde genehmigt oder lehnt
+// ab. Eine Genehmigung erfüllt auch die Pflichtprüfung des Tests. Erst wenn alle Abweichungen
+// genehmigt sind, wird der DPP ReleasedWithDeviations; eine Ablehnung blockiert ihn. Eine neue
+// Abweichung desselben Tests ersetzt die Sonderfreigabe (Superseded), sie muss neu beantragt
+// werden. Eine Sonderfreigabe gilt nur für den abweichenden Eintrag, der beim Antrag der
+// jüngste seines Tests war; ob sie ersetzt ist, ergibt sich aus dem Prüfstand (applyChecks).
+
+// ConcessionStatus: Stand einer Sonderfreigabe.
+type ConcessionStatus string
+
+const (
+	ConcessionRequested  ConcessionStatus = "Requested"
+	ConcessionApproved   ConcessionStatus = "Approved"
+	ConcessionDenied     ConcessionStatus = "Denied"
+	ConcessionSuperseded ConcessionStatus = "Superseded"
+)
+
+// Concession: Sonderfreigabe für die Abweichung eines Tests. EntryID ist der abweichende
+// Qualitätseintrag, an dem die Entscheidung des Kunden ebenfalls gespeichert wird.
+type Concession struct {
+	ConcessionID    string           `json:"concessionId"`
+	TestName        string           `json:"testName"`
+	EntryID         string           `json:"entryId"`
+	Justification   string           `json:"justification"`
+	CustomerMSP     string           `json:"customerMsp"`
+	Status          ConcessionStatus `json:"status"`
+	RequestedByMSP  string           `json:"requestedByMsp"`
+	RequestedAt     string           `json:"requestedAt"`
+	RequestedTxID   string           `json:"requestedTxId"`
+	DecidedByMSP    string           `json:"decidedByMsp,omitempty"    metadata:",optional"`
+	DecidedByID     string           `json:"decidedById,omitempty"     metadata:",optional"`
+	DecidedAt       string           `json:"decidedAt,omitempty"       metadata:",optional"`
+	DecidedTxID     string           `json:"decidedTxId,omitempty"     metadata:",optional"`
+	DecisionComment string           `json:"decisionComment,omitempty" metadata:",optional"`
+}
+
+// activeConcession: Beantragte oder genehmigte Sonderfreigabe eines Tests (höchstens eine).
+func (dpp *DPP) activeConcession(testName string) *Concession {
+	for i := len(dpp.Concessions) - 1; i >= 0; i-- {
+		c := &dpp.Concessions[i]
+		if c.TestName == testName && (c.Status == ConcessionRequested || c.Status == ConcessionApproved) {
+			return c
+		}
+	}
+	return nil
+}
+
+// deviationsConceded: Jede Abweichung hat eine genehmigte Sonderfreigabe.
+func (dpp *DPP) deviationsConceded() bool {
+	for _, testName := range dpp.DeviationTests {
+		c := dpp.activeConcession(testName)
+		if c == nil || c.Status != ConcessionApproved {
+			return false
+		}
+	}
+	return true
+}
+
+// hasDeniedConcession: Für eine noch bestehende Abweichung wurde die Sonderfreigabe abgelehnt.
+func (dpp *DPP) hasDeniedConcession() bool {
+	for _, testName := range dpp.DeviationTests {
+		for i := len(dpp.Concessions) - 1; i >= 0; i-- {
+			c := dpp.Concessions[i]
+			if c.TestName != testName || c.Status == ConcessionSuperseded {
+				continue
+			}
+			if c.Status == ConcessionDenied {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
+
+// checkConcessionCustomer: Genehmigte Sonderfreigaben gelten nur für den Kunden, der sie erteilt hat.
+func (dpp *DPP) checkConcessionCustomer(newOwnerMSP string) error {
+	for _, c := range dpp.Concessions {
+		if c.Status == ConcessionApproved && c.CustomerMSP != newOwnerMSP {
+			return fmt.Errorf("DPP %s ist nur mit Sonderfreigabe %s von %s freigegeben und kann nicht an %s versendet werden", dpp.DppID, c.ConcessionID, c.CustomerMSP, newOwnerMSP)
+		}
+	}
+	return nil
+}
+
+// latestDeviationEntry: Jüngster abweichender Qualitätseintrag eines Tests.
+func (c *DPPQualityContract) latestDeviationEntry(ctx contractapi.TransactionContextInterface, dpp *DPP, testName string) (*QualityEntry, error) {
+	var latest *QualityEntry
+	match := func(qe QualityEntry) {
+		if qe.TestName == testName && qe.EvaluationOutcome.isDeviation() && qe.SupersededBy == "" {
+			entry := qe
+			latest = &entry
+		}
+	}
+	if len(dpp.Quality) > 0 {
+		// Altes Format: Einträge wurden gerade aus dem Kopf migriert.
+		for _, qe := range dpp.Quality {
+			match(qe)
+		}
+	} else if err := forEachRecord(ctx, qualityObjectType, dpp.DppID, func(data []byte) error {
+		var qe QualityEntry
+		if err := json.Unmarshal(data, &qe); err != nil {
+			return err
+		}
+		match(qe)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen der Qualitätseinträge von DPP %s: %v", dpp.DppID, err)
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("kein abweichender Qualitätseintrag für Test '%s' in DPP %s", testName, dpp.DppID)
+	}
+	return latest, nil
+}
+
+// SetIntendedCustomer: Der Eigentümer legt den Kunden fest, der über Sonderfreigaben entscheidet.
+// Solange Sonderfreigaben beantragt oder genehmigt sind, ist der Kunde nicht mehr änderbar.
+func (c *DPPQualityContract) SetIntendedCustomer(ctx contractapi.TransactionContextInterface, dppID, customerMSP string) error {
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	ownerMSPID, err := requireOwner(ctx, dpp, "den Kunden festlegen")
+	if err != nil {
+		return err
+	}
+	if customerMSP == "" || customerMSP == ownerMSPID {
+		return fmt.Errorf("ungültiger Kunde '%s' für DPP %s", customerMSP, dppID)
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.syncStatus(ctx, clk, dpp); err != nil {
+		return err
+	}
+	if !dpp.Status.isQualityPhase() {
+		return fmt.Errorf("Kunde für DPP %s kann im Status %s nicht mehr festgelegt werden", dppID, dpp.Status)
+	}
+	for _, con := range dpp.Concessions {
+		if (con.Status == ConcessionRequested || con.Status == ConcessionApproved) && con.CustomerMSP != customerMSP {
+			return fmt.Errorf("Sonderfreigabe %s (%s) bei %s ist %s, Kunde kann nicht geändert werden", con.ConcessionID, con.TestName, con.CustomerMSP, con.Status)
+		}
+	}
+	dpp.IntendedCustomerMSP = customerMSP
+	fmt.Printf("[SetIntendedCustomer-INFO] Kunde für DPP %s: %s\n", dppID, customerMSP)
+	return c.saveDPP(ctx, dpp)
+}
+
+// RequestConcession: Der Eigentümer beantragt beim Kunden (IntendedCustomerMSP) eine
+// Sonderfreigabe für die jüngste Abweichung eines Tests.
+func (c *DPPQualityContract) RequestConcession(ctx contractapi.TransactionContextInterface, dppID, testName, justification string) (*Concession, error) {
+	fmt.Printf("[RequestConcession-DEBUG] Entry: dppID=%s, testName=%s\n", dppID, testName)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	ownerMSPID, err := requireOwner(ctx, dpp, "eine Sonderfreigabe beantragen")
+	if err != nil {
+		return nil, err
+	}
+	if justification == "" {
+		return nil, fmt.Errorf("justification darf nicht leer sein")
+	}
+	if dpp.IntendedCustomerMSP == "" {
+		return nil, fmt.Errorf("für DPP %s ist kein Kunde festgelegt (SetIntendedCustomer)", dppID)
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.syncStatus(ctx, clk, dpp); err != nil {
+		return nil, err
+	}
+	if !dpp.Status.isQualityPhase() {
+		return nil, fmt.Errorf("für DPP %s im Status %s können keine Sonderfreigaben beantragt werden", dppID, dpp.Status)
+	}
+	if !containsString(dpp.DeviationTests, testName) {
+		return nil, fmt.Errorf("Test '%s' von DPP %s hat keine Abweichung (Abweichungen: %v)", testName, dppID, dpp.DeviationTests)
+	}
+	if active := dpp.activeConcession(testName); active != nil {
+		return nil, fmt.Errorf("für Test '%s' besteht bereits Sonderfreigabe %s (%s)", testName, active.ConcessionID, active.Status)
+	}
+	check := dpp.checks[testName]
+	var entry *QualityEntry
+	if check.DeviationEntryID != "" {
+		entry, err = findQualityEntry(ctx, dpp, check.DeviationEntryID)
+	} else {
+		// Aus einem älteren Kopf übernommener Prüfstand ohne Verweis auf die Abweichung.
+		if entry, err = c.latestDeviationEntry(ctx, dpp, testName); err == nil {
+			check.DeviationEntryID = entry.EntryID
+			dpp.queueCheck(check)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	concession := Concession{
+		ConcessionID:   fmt.Sprintf("CON-%s-%d", clk.txID, len(dpp.Concessions)+1),
+		TestName:       testName,
+		EntryID:        entry.EntryID,
+		Justification:  justification,
+		CustomerMSP:    dpp.IntendedCustomerMSP,
+		Status:         ConcessionRequested,
+		RequestedByMSP: ownerMSPID,
+		RequestedAt:    clk.timestamp(),
+		RequestedTxID:  clk.txID,
+	}
+	dpp.Concessions = append(dpp.Concessions, concession)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"dppId":         dppID,
+		"concessionId":  concession.ConcessionID,
+		"testName":      testName,
+		"result":        entry.Result,
+		"outcome":       entry.EvaluationOutcome,
+		"customerMsp":   concession.CustomerMSP,
+		"justification": justification,
+	})
+	if err := ctx.GetStub().SetEvent("ConcessionRequested", payload); err != nil {
+		return nil, fmt.Errorf("Fehler beim Setzen des ConcessionRequested-Events: %v", err)
+	}
+	fmt.Printf("[RequestConcession-INFO] Sonderfreigabe %s für DPP %s, Test '%s' bei %s beantragt.\n", concession.ConcessionID, dppID, testName, concession.CustomerMSP)
+	if err := c.saveDPP(ctx, dpp); err != nil {
+		return nil, err
+	}
+	return &concession, nil
+}
+
+// ApproveConcession: Der Kunde genehmigt die beantragte Sonderfreigabe eines Tests.
+func (c *DPPQualityContract) ApproveConcession(ctx contractapi.TransactionContextInterface, dppID, testName, comment string) (*Concession, error) {
+	return c.decideConcession(ctx, dppID, testName, comment, ConcessionApproved)
+}
+
+// DenyConcession: Der Kunde lehnt die beantragte Sonderfreigabe ab; der DPP wird blockiert.
+func (c *DPPQualityContract) DenyConcession(ctx contractapi.TransactionContextInterface, dppID, testName, reason string) (*Concession, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("reason darf nicht leer sein")
+	}
+	return c.decideConcession(ctx, dppID, testName, reason, ConcessionDenied)
+}
+
+func (c *DPPQualityContract) decideConcession(ctx contractapi.TransactionContextInterface, dppID, testName, comment string, decision ConcessionStatus) (*Concession, error) {
+	fmt.Printf("[decideConcession-DEBUG] Entry: dppID=%s, testName=%s, decision=%s\n", dppID, testName, decision)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.syncStatus(ctx, clk, dpp); err != nil {
+		return nil, err
+	}
+	concession := dpp.activeConcession(testName)
+	if concession == nil || concession.Status != ConcessionRequested {
+		return nil, fmt.Errorf("für Test '%s' von DPP %s ist keine Sonderfreigabe beantragt", testName, dppID)
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if clientMSPID != concession.CustomerMSP {
+		return nil, fmt.Errorf("Zugriff verweigert: nur der Kunde %s entscheidet über Sonderfreigabe %s, Aufrufer ist %s", concession.CustomerMSP, concession.ConcessionID, clientMSPID)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client ID: %v", err)
+	}
+
+	concession.Status = decision
+	concession.DecidedByMSP = clientMSPID
+	concession.DecidedByID = clientID
+	concession.DecidedAt = clk.timestamp()
+	concession.DecidedTxID = clk.txID
+	concession.DecisionComment = comment
+
+	entry, err := readQualityEntry(ctx, dppID, concession.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	decided := *concession
+	entry.Concession = &decided
+	dpp.updateQualityEntry(*entry)
+	if decision == ConcessionApproved {
+		// Eine genehmigte Abweichung erfüllt auch die Pflichtprüfung des Tests.
+		check, err := dpp.checkFor(ctx, testName)
+		if err != nil {
+			return nil, err
+		}
+		check.Closed = true
+		dpp.queueCheck(check)
+	}
+
+	if err := dpp.recalculateOverallStatus(ctx, clk, fmt.Sprintf("Sonderfreigabe %s für Test '%s': %s durch %s", concession.ConcessionID, testName, decision, clientMSPID)); err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"dppId":        dppID,
+		"concessionId": decided.ConcessionID,
+		"testName":     testName,
+		"decision":     decision,
+		"decidedByMsp": clientMSPID,
+		"comment":      comment,
+		"status":       dpp.Status,
+	})
+	if err := ctx.GetStub().SetEvent("ConcessionDecided", payload); err != nil {
+		return nil, fmt.Errorf("Fehler beim Setzen des ConcessionDecided-Events: %v", err)
+	}
+	fmt.Printf("[decideConcession-INFO] Sonderfreigabe %s für DPP %s: %s (Status: %s)\n", decided.ConcessionID, dppID, decision, dpp.Status)
+	if err := c.saveDPP(ctx, dpp); err != nil {
+		return nil, err
+	}
+	return &decided, nil
+}
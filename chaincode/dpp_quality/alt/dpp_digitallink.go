@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+
+	"dpp_transfer_chaincode/gs1"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- GS1 Digital Link --------------------------- //
+//
+// Etiketten tragen GS1 Digital Link URIs (https://id.gs1.org/01/<gtin>/10/<charge>/21/<serial>),
+// der Chaincode arbeitet mit EPC-URNs. Jeder DPP enthält den Digital Link seines gs1Key
+// (digitalLink), und saveDPP legt dazu einen Index an:
+//
+//	DPP~digitalLink~01~<gtin>~21~<serial>~<dppId>   (sgtin)
+//	DPP~digitalLink~01~<gtin>~10~<charge>~<dppId>   (lgtin)
+//	DPP~digitalLink~00~<sscc>~<dppId>
+//	DPP~digitalLink~8003~<grai>~<dppId>
+//
+// QueryDPPByDigitalLink findet den DPP darüber ohne Kenntnis der Firmenpräfix-Länge. DPPs,
+// die vor Einführung des Index angelegt wurden, werden beim nächsten Schreiben aufgenommen.
+// DPPs, die nicht mehr geschrieben werden (z.B. Split, ConsumedInTransformation), nimmt
+// RebuildDigitalLinkIndex auf.
+
+const digitalLinkObjectType = "DPP~digitalLink"
+
+// ensureDigitalLink setzt digitalLink aus gs1Key, falls noch nicht vorhanden (neuer DPP oder
+// vor Einführung des Felds angelegt), und merkt den Index zum Schreiben vor.
+func (dpp *DPP) ensureDigitalLink() {
+	if dpp.DigitalLink != "" {
+		return
+	}
+	epc, err := gs1.ParseEPC(dpp.GS1Key)
+	if err != nil {
+		return // vor Einführung der GS1-Prüfung angelegter Schlüssel
+	}
+	if dl := epc.DigitalLink(); dl != nil {
+		dpp.DigitalLink = dl.URI()
+		dpp.indexDigitalLink = true
+	}
+}
+
+// digitalLinkIndexKeys: Indexattribute eines Digital Link (ohne dppId). Ein Link mit Charge und
+// Seriennummer kann eine sgtin oder eine lgtin bezeichnen, die sgtin steht zuerst.
+func digitalLinkIndexKeys(dl *gs1.DigitalLink) [][]string {
+	switch dl.AI {
+	case gs1.AIGTIN:
+		var keys [][]string
+		if dl.Serial != "" {
+			keys = append(keys, []string{gs1.AIGTIN, dl.Key, gs1.AISerial, dl.Serial})
+		}
+		if dl.Batch != "" {
+			keys = append(keys, []string{gs1.AIGTIN, dl.Key, gs1.AIBatch, dl.Batch})
+		}
+		return keys
+	case gs1.AISSCC, gs1.AIGRAI:
+		return [][]string{{dl.AI, dl.Key}}
+	}
+	return nil
+}
+
+// putDigitalLinkIndex schreibt den Indexeintrag des DPP (Wert wie beim Event-Index ein Null-Byte).
+func putDigitalLinkIndex(ctx contractapi.TransactionContextInterface, dpp *DPP) error {
+	dl, err := gs1.ParseDigitalLink(dpp.DigitalLink)
+	if err != nil {
+		return fmt.Errorf("digitalLink von DPP %s ungültig: %v", dpp.DppID, err)
+	}
+	keys := digitalLinkIndexKeys(dl)
+	if len(keys) == 0 {
+		return nil
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(digitalLinkObjectType, append(keys[0], dpp.DppID))
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+		return fmt.Errorf("PutState für Digital-Link-Index von DPP %s fehlgeschlagen: %v", dpp.DppID, err)
+	}
+	return nil
+}
+
+// QueryDPPByDigitalLink: Liefert den DPP zu einem gescannten GS1 Digital Link (beliebige
+// Domain). Bezeichnet der Link mehrere DPPs, wird ein Fehler mit den DPP-IDs gemeldet.
+func (c *DPPQualityContract) QueryDPPByDigitalLink(ctx contractapi.TransactionContextInterface, link string) (*DPP, error) {
+	fmt.Printf("[QueryDPPByDigitalLink-DEBUG] Entry: link=%s\n", link)
+	dl, err := gs1.ParseDigitalLink(link)
+	if err != nil {
+		return nil, err
+	}
+	keys := digitalLinkIndexKeys(dl)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("Digital Link '%s' bezeichnet kein Produkt (Seriennummer oder Charge fehlt)", link)
+	}
+	for _, attrs := range keys {
+		dppIDs, err := digitalLinkDPPs(ctx, attrs)
+		if err != nil {
+			return nil, err
+		}
+		switch len(dppIDs) {
+		case 0:
+			continue
+		case 1:
+			fmt.Printf("[QueryDPPByDigitalLink-INFO] %s -> DPP %s\n", link, dppIDs[0])
+			return c.readDPP(ctx, dppIDs[0])
+		default:
+			return nil, fmt.Errorf("Digital Link '%s' ist mehreren DPPs zugeordnet: %v", link, dppIDs)
+		}
+	}
+	return nil, fmt.Errorf("kein DPP zu Digital Link '%s' gefunden", link)
+}
+
+func digitalLinkDPPs(ctx contractapi.TransactionContextInterface, attrs []string) ([]string, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(digitalLinkObjectType, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen des Digital-Link-Index: %v", err)
+	}
+	defer iter.Close()
+	var dppIDs []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyAttrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil || len(keyAttrs) != len(attrs)+1 {
+			continue // längerer Schlüssel mit gleichem Präfix
+		}
+		dppIDs = append(dppIDs, keyAttrs[len(attrs)])
+	}
+	return dppIDs, nil
+}
+
+// RebuildDigitalLinkIndex: Nimmt einen DPP in den Digital-Link-Index auf, z.B. einen DPP, der vor
+// Einführung des Index angelegt und seitdem nicht mehr geschrieben wurde. Liefert den Digital Link.
+func (c *DPPQualityContract) RebuildDigitalLinkIndex(ctx contractapi.TransactionContextInterface, dppID string) (string, error) {
+	fmt.Printf("[RebuildDigitalLinkIndex-DEBUG] Entry: dppID=%s\n", dppID)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return "", err
+	}
+	if dpp.DigitalLink == "" {
+		return "", fmt.Errorf("DPP %s hat keinen Digital Link (gs1Key %s ist keine gültige EPC-URN)", dppID, dpp.GS1Key)
+	}
+	// Auch bei bereits gesetztem digitalLink schreiben; der Indexeintrag ist idempotent.
+	dpp.indexDigitalLink = true
+	if err := c.saveDPP(ctx, dpp); err != nil {
+		return "", err
+	}
+	fmt.Printf("[RebuildDigitalLinkIndex-INFO] DPP %s unter %s indiziert.\n", dppID, dpp.DigitalLink)
+	return dpp.DigitalLink, nil
+}
+
+// ConvertEPCToDigitalLink: EPC-URN zu einem GS1 Digital Link (https://id.gs1.org/...).
+func (c *DPPQualityContract) ConvertEPCToDigitalLink(ctx contractapi.TransactionContextInterface, epcURN string) (string, error) {
+	epc, err := gs1.ParseEPC(epcURN)
+	if err != nil {
+		return "", err
+	}
+	return epc.DigitalLink().URI(), nil
+}
+
+// ConvertDigitalLinkToEPC: GS1 Digital Link zu einer EPC-URN. Die Länge des Firmenpräfix (6 bis 12)
+// ist im Digital Link nicht enthalten und muss angegeben werden.
+func (c *DPPQualityContract) ConvertDigitalLinkToEPC(ctx contractapi.TransactionContextInterface, link string, companyPrefixLength int) (string, error) {
+	dl, err := gs1.ParseDigitalLink(link)
+	if err != nil {
+		return "", err
+	}
+	epc, err := dl.EPC(companyPrefixLength)
+	if err != nil {
+		return "", fmt.Errorf("Digital Link '%s': %v", link, err)
+	}
+	return epc.URN(), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestRebuildDigitalLinkIndex: Ein vor Einführung des Index angelegter und nicht mehr
+// geschriebener DPP wird erst nach RebuildDigitalLinkIndex über seinen Digital Link gefunden.
+func TestRebuildDigitalLinkIndex(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	legacy := DPP{DppID: "A1", GS1Key: "urn:epc:id:sgtin:4012345.011111.1001", Status: StatusConsumedInTransformation, ConsumedByDPPID: "B1"}
+	data, err := json.Marshal(legacy)
+	e.must(err)
+	e.must(e.ctx("Org1MSP").GetStub().PutState(dppPrefix+"A1", data))
+
+	const link = "https://id.gs1.org/01/04012345111118/21/1001"
+	if _, err := c.QueryDPPByDigitalLink(e.ctx("Org3MSP"), link); err == nil {
+		t.Fatalf("DPP vor RebuildDigitalLinkIndex gefunden")
+	}
+	got, err := c.RebuildDigitalLinkIndex(e.ctx("Org1MSP", "role", "admin"), "A1")
+	e.must(err)
+	if got != link {
+		t.Errorf("Digital Link %s, erwartet %s", got, link)
+	}
+	dpp, err := c.QueryDPPByDigitalLink(e.ctx("Org3MSP"), link)
+	e.must(err)
+	if dpp.DppID != "A1" || dpp.DigitalLink != link || dpp.Status != StatusConsumedInTransformation {
+		t.Errorf("DPP %s mit digitalLink %q im Status %s", dpp.DppID, dpp.DigitalLink, dpp.Status)
+	}
+
+	// Wiederholter Aufruf ist unschädlich, ein ungültiger gs1Key wird gemeldet.
+	_, err = c.RebuildDigitalLinkIndex(e.ctx("Org1MSP", "role", "admin"), "A1")
+	e.must(err)
+	data, err = json.Marshal(DPP{DppID: "A2", GS1Key: "PROD-4711"})
+	e.must(err)
+	e.must(e.ctx("Org1MSP").GetStub().PutState(dppPrefix+"A2", data))
+	if _, err := c.RebuildDigitalLinkIndex(e.ctx("Org1MSP", "role", "admin"), "A2"); err == nil {
+		t.Errorf("DPP ohne gültigen gs1Key: kein Fehler")
+	}
+}
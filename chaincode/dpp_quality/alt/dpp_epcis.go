@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dpp_transfer_chaincode/epcis"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- EPCIS 2.0 Export --------------------------- //
+//
+// Die Events eines DPP sind intern als EPCISEvent mit URN-Vokabular und freier Extensions-Map
+// gespeichert. ExportEPCISDocument setzt sie in ein EPCIS 2.0 EPCISDocument (JSON-LD) um,
+// das EPCIS-Repositories direkt importieren können. Qualitätsdaten und andere Erweiterungen
+// erscheinen als Felder im Namespace "dpp:" (siehe Paket epcis).
+
+// ExportEPCISDocument: Alle EPCIS-Events eines DPP als EPCIS 2.0 JSON-LD Dokument.
+// creationDate ist der Zeitstempel der Abfrage-Transaktion.
+func (c *DPPQualityContract) ExportEPCISDocument(ctx contractapi.TransactionContextInterface, dppID string) (string, error) {
+	fmt.Printf("[ExportEPCISDocument-DEBUG] Entry: dppID=%s\n", dppID)
+	dpp, err := c.readDPP(ctx, dppID)
+	if err != nil {
+		return "", err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	events := make([]epcis.Event, 0, len(dpp.EPCISEvents))
+	for _, evt := range dpp.EPCISEvents {
+		events = append(events, evt.toEPCIS2())
+	}
+	doc, err := json.Marshal(epcis.NewDocument(clk.timestamp(), events))
+	if err != nil {
+		return "", fmt.Errorf("Fehler beim Erzeugen des EPCIS-Dokuments für DPP %s: %v", dppID, err)
+	}
+	fmt.Printf("[ExportEPCISDocument-INFO] %d Events von DPP %s exportiert.\n", len(events), dppID)
+	return string(doc), nil
+}
+
+// toEPCIS2 bildet ein gespeichertes Event auf EPCIS 2.0 ab. ObjectEvents ohne action (vor
+// Einführung der EPCIS-Felder geschrieben) werden als OBSERVE exportiert, weil action dort
+// Pflicht ist; TransformationEvents haben keine action.
+func (evt EPCISEvent) toEPCIS2() epcis.Event {
+	out := epcis.Event{
+		Type:                evt.EventType,
+		EventID:             epcis.EventID(evt.EventID),
+		EventTime:           evt.EventTime,
+		EventTimeZoneOffset: evt.EventTimeZoneOffset,
+		EPCList:             evt.EPCList,
+		InputEPCList:        evt.InputEPCList,
+		OutputEPCList:       evt.OutputEPCList,
+		Action:              evt.Action,
+		BizStep:             epcis.BizStep(evt.BizStep),
+		Disposition:         epcis.Disposition(evt.Disposition),
+		ReadPoint:           epcis.NewLocation(siteLocation(evt.ReadPoint)),
+		BizLocation:         epcis.NewLocation(siteLocation(evt.BizLocation)),
+		Extensions:          evt.Extensions,
+	}
+	for _, se := range evt.SensorElementList {
+		out.SensorElementList = append(out.SensorElementList, se.toEPCIS2())
+	}
+	switch evt.EventType {
+	case "TransformationEvent":
+		out.Action = ""
+	case "ObjectEvent":
+		if out.Action == "" {
+			out.Action = "OBSERVE"
+		}
+	}
+	return out
+}
+
+// toEPCIS2: Sensordaten mit Standardfeldern; Verweis und Hash der Rohdatendatei sowie die
+// UCUM-Einheit werden als Erweiterungen ausgegeben.
+func (se SensorElement) toEPCIS2() epcis.SensorElement {
+	meta := se.SensorMetadata
+	out := epcis.SensorElement{
+		SensorMetadata: &epcis.SensorMetadata{
+			Time:       meta.Time,
+			RawData:    meta.RawData,
+			Extensions: nonEmpty(map[string]string{"rawDataRef": meta.RawDataRef, "rawDataHash": meta.RawDataHash, "hashAlgorithm": meta.HashAlgorithm}),
+		},
+		SensorReport: make([]epcis.SensorReport, 0, len(se.SensorReport)),
+	}
+	for _, r := range se.SensorReport {
+		out.SensorReport = append(out.SensorReport, epcis.SensorReport{
+			Type:       r.Type,
+			Exception:  r.Exception,
+			Value:      optionalFloat(r.Value, r.hasValue),
+			MinValue:   optionalFloat(r.MinValue, r.hasMin),
+			MaxValue:   optionalFloat(r.MaxValue, r.hasMax),
+			MeanValue:  optionalFloat(r.MeanValue, r.hasMean),
+			SDev:       optionalFloat(r.SDev, r.hasSDev),
+			UOM:        r.UOM,
+			Extensions: nonEmpty(map[string]string{"ucumUnit": r.UCUMUnit}),
+		})
+	}
+	return out
+}
+
+// nonEmpty: Erweiterungen ohne leere Werte; nil, wenn keine übrig bleibt.
+func nonEmpty(fields map[string]string) map[string]interface{} {
+	var out map[string]interface{}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{})
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dpp_transfer_chaincode/epcis"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// loadEPCISSchema: Offizielles EPCIS 2.0 JSON-Schema aus epcis/testdata (wie loadSchema in
+// epcis_test.go).
+func loadEPCISSchema(t *testing.T) *gojsonschema.Schema {
+	t.Helper()
+	schemaPath := filepath.Join("epcis", "testdata", "epcis-json-schema.json")
+	if _, err := os.Stat(schemaPath); err != nil {
+		t.Fatalf("offizielles Schema %s fehlt, mit epcis/testdata/fetch-epcis-schema.sh laden und einchecken: %v", schemaPath, err)
+	}
+	abs, err := filepath.Abs(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(abs)))
+	if err != nil {
+		t.Fatalf("Schema %s nicht ladbar: %v", schemaPath, err)
+	}
+	return schema
+}
+
+// TestExportEPCISDocumentLifecycle führt einen DPP durch Anlage, Qualitätsprüfung, Aufteilung,
+// Versand, Transport und Empfang und prüft die exportierten Dokumente gegen das Schema.
+func TestExportEPCISDocumentLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-E",
+		`[{"testName":"MFI","isNumeric":true,"lowerLimit":10,"upperLimit":20,"unit":"g/10min","isMandatory":true}]`,
+		`[{"testName":"temperature","isNumeric":true,"lowerLimit":0,"upperLimit":30,"unit":"Cel"}]`, "")
+	e.must(err)
+	_, err = c.CreateDPP(e.ctx("Org1MSP"), "E1", "urn:epc:id:sgtin:4012345.011111.3001", "PT-E", "4012345000016", "L1", "2025-06-01", 1000, "kg", 0)
+	e.must(err)
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "E1",
+		`{"testName":"MFI","result":"15","unit":"g/10min","readings":[14.8,15,15.2],"systemId":"LIMS","responsible":"Labor","offChainDataRef":"lab/mfi-3001.csv","offChainDataHash":"7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"}`,
+		"4012345000016"))
+	_, err = c.SplitDPP(e.ctx("Org1MSP"), "E1",
+		`[{"dppId":"E1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.3002","quantity":400},{"dppId":"E1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.3003","quantity":0.6,"quantityUnit":"t"}]`,
+		"4012345000016")
+	e.must(err)
+	e.must(c.TransferDPP(e.ctx("Org1MSP"), "E1-2", "Org2MSP", "4012345000016"))
+	e.must(c.AddTransportUpdate(e.ctx("Org1MSP"), "E1-2", `{"logType":"temperature","value":"12","unit":"Cel"}`, ""))
+	e.must(c.AddTransportUpdate(e.ctx("Org2MSP"), "E1-2", `{"logType":"temperature","value":"35","unit":"Cel","minValue":"11","maxValue":"35"}`, "4098765000010"))
+	e.must(c.AcknowledgeReceiptAndRecordInspection(e.ctx("Org2MSP"), "E1-2", "4098765000010", ""))
+
+	schema := loadEPCISSchema(t)
+	for dppID, bizSteps := range map[string][]string{
+		"E1":   {"commissioning", "inspecting", "repackaging"},
+		"E1-2": {"commissioning", "shipping", "transporting", "receiving"},
+	} {
+		doc, err := c.ExportEPCISDocument(e.ctx("Org2MSP"), dppID)
+		if err != nil {
+			t.Fatalf("ExportEPCISDocument(%s): %v", dppID, err)
+		}
+		result, err := schema.Validate(gojsonschema.NewStringLoader(doc))
+		if err != nil {
+			t.Fatalf("Validierung von %s fehlgeschlagen: %v", dppID, err)
+		}
+		if !result.Valid() {
+			var errs []string
+			for _, re := range result.Errors() {
+				errs = append(errs, re.String())
+			}
+			t.Errorf("Dokument von %s ist kein gültiges EPCIS 2.0 Dokument:\n%s\n%s", dppID, strings.Join(errs, "\n"), doc)
+		}
+
+		var parsed struct {
+			EPCISBody struct {
+				EventList []map[string]interface{} `json:"eventList"`
+			} `json:"epcisBody"`
+		}
+		if err := json.Unmarshal([]byte(doc), &parsed); err != nil {
+			t.Fatal(err)
+		}
+		found := map[string]bool{}
+		for _, event := range parsed.EPCISBody.EventList {
+			if bizStep, ok := event["bizStep"].(string); ok {
+				found[bizStep] = true
+			}
+		}
+		for _, bizStep := range bizSteps {
+			if !found[epcis.BizStep("urn:epcglobal:cbv:bizstep:"+bizStep)] {
+				t.Errorf("Dokument von %s ohne Event mit bizStep %s: %v", dppID, bizStep, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dpp_transfer_chaincode/ucum"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Spezifikationsbewertung --------------------------- //
+
+// EvaluationOutcome ist das typisierte Ergebnis der Bewertung eines QualityEntry.
+type EvaluationOutcome string
+
+const (
+	OutcomePass                   EvaluationOutcome = "PASS"
+	OutcomeFail                   EvaluationOutcome = "FAIL"
+	OutcomeDeviationLow           EvaluationOutcome = "DEVIATION_LOW"
+	OutcomeDeviationHigh          EvaluationOutcome = "DEVIATION_HIGH"
+	OutcomeInvalidFormat          EvaluationOutcome = "INVALID_FORMAT"
+	OutcomeNoSpec                 EvaluationOutcome = "NO_SPEC"
+	OutcomeIncomingInspectionData EvaluationOutcome = "INCOMING_INSPECTION_DATA"
+	OutcomeUnitMismatch           EvaluationOutcome = "UNIT_MISMATCH" // Einheit nicht in die Spezifikationseinheit umrechenbar
+)
+
+// isCritical: Ergebnis blockiert den DPP.
+func (o EvaluationOutcome) isCritical() bool {
+	return o == OutcomeFail || o == OutcomeInvalidFormat || o == OutcomeUnitMismatch
+}
+
+// isDeviation: Wert außerhalb der Grenzen, aber nicht kritisch.
+func (o EvaluationOutcome) isDeviation() bool {
+	return o == OutcomeDeviationLow || o == OutcomeDeviationHigh
+}
+
+func (o EvaluationOutcome) isNonConformant() bool {
+	return o.isCritical() || o.isDeviation()
+}
+
+// qualitySpecificationAlias vermeidet Rekursion in (Un)MarshalJSON.
+type qualitySpecificationAlias QualitySpecification
+
+// qualitySpecificationJSON überschreibt die numerischen Grenzen mit Zeigern, damit
+// "nicht gesetzt" von 0 unterschieden werden kann.
+type qualitySpecificationJSON struct {
+	qualitySpecificationAlias
+	LowerLimit   *float64 `json:"lowerLimit,omitempty"`
+	UpperLimit   *float64 `json:"upperLimit,omitempty"`
+	NominalValue *float64 `json:"nominalValue,omitempty"`
+	Tolerance    *float64 `json:"tolerance,omitempty"`
+}
+
+func optionalFloat(v float64, set bool) *float64 {
+	if !set {
+		return nil
+	}
+	return &v
+}
+
+func (s QualitySpecification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(qualitySpecificationJSON{
+		qualitySpecificationAlias: qualitySpecificationAlias(s),
+		LowerLimit:                optionalFloat(s.LowerLimit, s.hasLower),
+		UpperLimit:                optionalFloat(s.UpperLimit, s.hasUpper),
+		NominalValue:              optionalFloat(s.NominalValue, s.hasNominal),
+		Tolerance:                 optionalFloat(s.Tolerance, s.hasTolerance),
+	})
+}
+
+func (s *QualitySpecification) UnmarshalJSON(data []byte) error {
+	var aux qualitySpecificationJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*s = QualitySpecification(aux.qualitySpecificationAlias)
+	if aux.LowerLimit != nil {
+		s.LowerLimit, s.hasLower = *aux.LowerLimit, true
+	}
+	if aux.UpperLimit != nil {
+		s.UpperLimit, s.hasUpper = *aux.UpperLimit, true
+	}
+	if aux.NominalValue != nil {
+		s.NominalValue, s.hasNominal = *aux.NominalValue, true
+	}
+	if aux.Tolerance != nil {
+		s.Tolerance, s.hasTolerance = *aux.Tolerance, true
+	}
+	return nil
+}
+
+// bounds liefert die effektiven Grenzen. Nennwert ± Toleranz ergibt zwei inklusive Grenzen.
+func (s *QualitySpecification) bounds() (lower, upper float64, hasLower, hasUpper bool) {
+	if s.hasNominal {
+		return s.NominalValue - s.Tolerance, s.NominalValue + s.Tolerance, true, true
+	}
+	return s.LowerLimit, s.UpperLimit, s.hasLower, s.hasUpper
+}
+
+// validate prüft eine Spezifikation auf Widersprüche, bevor sie in einen DPP übernommen wird.
+func (s *QualitySpecification) validate() error {
+	if s.TestName == "" {
+		return fmt.Errorf("Spezifikation ohne testName")
+	}
+	if !s.IsNumeric {
+		if s.Statistics != nil {
+			return fmt.Errorf("Spezifikation '%s': statistische Kriterien nur für numerische Tests", s.TestName)
+		}
+		return nil
+	}
+	if s.hasNominal != s.hasTolerance {
+		return fmt.Errorf("Spezifikation '%s': nominalValue und tolerance müssen gemeinsam angegeben werden", s.TestName)
+	}
+	if s.hasNominal && (s.hasLower || s.hasUpper) {
+		return fmt.Errorf("Spezifikation '%s': nominalValue/tolerance und lowerLimit/upperLimit schließen sich aus", s.TestName)
+	}
+	if s.hasTolerance && s.Tolerance < 0 {
+		return fmt.Errorf("Spezifikation '%s': tolerance darf nicht negativ sein", s.TestName)
+	}
+	if err := s.Statistics.validate(s); err != nil {
+		return err
+	}
+	lower, upper, hasLower, hasUpper := s.bounds()
+	if !hasLower && !hasUpper {
+		return fmt.Errorf("Spezifikation '%s': numerischer Test ohne lowerLimit, upperLimit oder nominalValue", s.TestName)
+	}
+	if hasLower && hasUpper && lower > upper {
+		return fmt.Errorf("Spezifikation '%s': lowerLimit %.4f größer als upperLimit %.4f", s.TestName, lower, upper)
+	}
+	return nil
+}
+
+func validateSpecifications(specs []QualitySpecification) error {
+	seen := make(map[string]bool, len(specs))
+	for i := range specs {
+		if err := specs[i].validate(); err != nil {
+			return err
+		}
+		if seen[specs[i].TestName] {
+			return fmt.Errorf("Spezifikation für Test '%s' ist mehrfach vorhanden", specs[i].TestName)
+		}
+		seen[specs[i].TestName] = true
+	}
+	return nil
+}
+
+// Evaluation ist das Ergebnis von evaluateResult. NormalizedResult/-Unit sind gesetzt, wenn
+// das Ergebnis in die Einheit der Spezifikation umgerechnet wurde.
+type Evaluation struct {
+	Outcome          EvaluationOutcome
+	Comment          string
+	NormalizedResult string
+	NormalizedUnit   string
+	Statistics       *ReadingStatistics // nur bei Messreihen
+}
+
+// evaluateResult bewertet ein Ergebnis gegen eine Spezifikation. Einzige Bewertungslogik
+// im Contract; alle Aufrufer (RecordQualityData, RecordTransformation, …) nutzen sie.
+func evaluateResult(spec *QualitySpecification, qe QualityEntry) Evaluation {
+	if spec == nil {
+		return Evaluation{
+			Outcome: OutcomeNoSpec,
+			Comment: fmt.Sprintf("Keine Spezifikation für Test '%s' im DPP hinterlegt. Daten werden als informativ gespeichert.", qe.TestName),
+		}
+	}
+
+	var ev Evaluation
+	if spec.IsNumeric {
+		ev = evaluateNumeric(spec, qe)
+	} else if strings.EqualFold(qe.Result, spec.ExpectedValue) {
+		ev = Evaluation{Outcome: OutcomePass}
+	} else {
+		ev = Evaluation{Outcome: OutcomeFail, Comment: fmt.Sprintf("Erwartet: '%s', Erhalten: '%s'.", spec.ExpectedValue, qe.Result)}
+	}
+
+	if !spec.IsNumeric && len(qe.Readings) > 0 {
+		return Evaluation{Outcome: OutcomeInvalidFormat, Comment: fmt.Sprintf("Test '%s' ist nicht numerisch, Messreihen (readings) sind nicht zulässig.", qe.TestName)}
+	}
+	if !spec.IsNumeric && !sameUnit(spec.Unit, qe.Unit) {
+		if ev.Comment != "" {
+			ev.Comment += " "
+		}
+		ev.Comment += fmt.Sprintf("Einheit für '%s' passt nicht: Spezifikation '%s', Eintrag '%s'.", qe.TestName, spec.Unit, qe.Unit)
+	}
+	return ev
+}
+
+// evaluateNumeric rechnet das Ergebnis bei abweichender Einheit (UCUM) in die Einheit der
+// Spezifikation um und prüft den umgerechneten Wert. Messreihen wertet evaluateSeries aus.
+func evaluateNumeric(spec *QualitySpecification, qe QualityEntry) Evaluation {
+	if len(qe.Readings) > 0 || spec.Statistics != nil {
+		return evaluateSeries(spec, qe)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(qe.Result), 64)
+	if err != nil {
+		return Evaluation{Outcome: OutcomeInvalidFormat, Comment: fmt.Sprintf("Ergebnis '%s' für Test '%s' ist nicht numerisch.", qe.Result, qe.TestName)}
+	}
+	convert, converted, mismatch := unitConversion(spec, qe)
+	if mismatch != nil {
+		return *mismatch
+	}
+	if !converted {
+		return evaluateValue(spec, value)
+	}
+
+	normalized, err := convert(value)
+	if err != nil {
+		return Evaluation{Outcome: OutcomeUnitMismatch, Comment: err.Error()}
+	}
+	ev := evaluateValue(spec, normalized)
+	ev.NormalizedResult = strconv.FormatFloat(normalized, 'g', -1, 64)
+	ev.NormalizedUnit = spec.Unit
+	ev.Comment = joinComments(fmt.Sprintf("Umgerechnet: %s %s = %s %s.", strings.TrimSpace(qe.Result), qe.Unit, ev.NormalizedResult, spec.Unit), ev.Comment)
+	return ev
+}
+
+// unitConversion liefert die Umrechnung der Eintragseinheit in die Spezifikationseinheit.
+// converted ist false, wenn keine Umrechnung nötig ist; mismatch ist bei unvereinbaren
+// Einheiten gesetzt (UNIT_MISMATCH).
+func unitConversion(spec *QualitySpecification, qe QualityEntry) (convert func(float64) (float64, error), converted bool, mismatch *Evaluation) {
+	identity := func(v float64) (float64, error) { return v, nil }
+	if spec.Unit != "" && strings.TrimSpace(qe.Unit) == "" {
+		// Ohne Einheit ist nicht erkennbar, ob der Wert in der Spezifikationseinheit vorliegt.
+		return nil, false, &Evaluation{Outcome: OutcomeUnitMismatch, Comment: fmt.Sprintf("Ergebnis für Test '%s' ohne Einheit, die Spezifikation verlangt '%s'.", qe.TestName, spec.Unit)}
+	}
+	if sameUnit(spec.Unit, qe.Unit) {
+		return identity, false, nil
+	}
+	if _, err := ucum.Convert(1, qe.Unit, spec.Unit); err != nil {
+		if !errors.Is(err, ucum.ErrIncompatible) && strings.EqualFold(spec.Unit, qe.Unit) {
+			// Kein UCUM-Code, nur abweichende Schreibweise (z.B. "wt-%"/"WT-%"): wie bisher vergleichen.
+			return identity, false, nil
+		}
+		return nil, false, &Evaluation{Outcome: OutcomeUnitMismatch, Comment: fmt.Sprintf("Ergebnis für Test '%s' in '%s' lässt sich nicht in die Spezifikationseinheit '%s' umrechnen: %v.", qe.TestName, qe.Unit, spec.Unit, err)}
+	}
+	return func(v float64) (float64, error) { return ucum.Convert(v, qe.Unit, spec.Unit) }, true, nil
+}
+
+func joinComments(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
+// sameUnit: Keine Umrechnung nötig (gleicher Code oder Spezifikation ohne Einheit).
+func sameUnit(specUnit, entryUnit string) bool {
+	if specUnit == "" {
+		return true
+	}
+	return strings.ReplaceAll(specUnit, " ", "") == strings.ReplaceAll(entryUnit, " ", "")
+}
+
+// evaluateValue prüft einen numerischen Wert gegen die (ein- oder zweiseitigen) Grenzen.
+func evaluateValue(spec *QualitySpecification, value float64) Evaluation {
+	lower, upper, hasLower, hasUpper := spec.bounds()
+	lowerExclusive := spec.LowerLimitExclusive && !spec.hasNominal
+	upperExclusive := spec.UpperLimitExclusive && !spec.hasNominal
+
+	if hasLower && (value < lower || (lowerExclusive && value == lower)) {
+		return Evaluation{Outcome: OutcomeDeviationLow, Comment: fmt.Sprintf("Wert %.4f unter Grenzwert %s%.4f %s.", value, exclusiveMarker(lowerExclusive), lower, spec.Unit)}
+	}
+	if hasUpper && (value > upper || (upperExclusive && value == upper)) {
+		return Evaluation{Outcome: OutcomeDeviationHigh, Comment: fmt.Sprintf("Wert %.4f über Grenzwert %s%.4f %s.", value, exclusiveMarker(upperExclusive), upper, spec.Unit)}
+	}
+	return Evaluation{Outcome: OutcomePass}
+}
+
+func exclusiveMarker(exclusive bool) string {
+	if exclusive {
+		return "(exklusiv) "
+	}
+	return ""
+}
+
+// specFor liefert die Spezifikation zu einem Testnamen oder nil.
+func (dpp *DPP) specFor(testName string) *QualitySpecification {
+	for i := range dpp.Specifications {
+		if dpp.Specifications[i].TestName == testName {
+			return &dpp.Specifications[i]
+		}
+	}
+	return nil
+}
+
+// applyQualityEntry bewertet einen Eintrag gegen die Spezifikationen des DPP, merkt ihn zum
+// Schreiben vor und führt den Prüfstand des Tests nach (siehe dpp_checks.go). Der Status wird
+// hier nicht berechnet.
+func (dpp *DPP) applyQualityEntry(ctx contractapi.TransactionContextInterface, clk *txClock, qe *QualityEntry) (*QualitySpecification, error) {
+	spec := dpp.evaluateQualityEntry(qe)
+	dpp.addQualityEntry(clk, qe)
+	return spec, dpp.recordEvaluatedEntry(ctx, qe, spec)
+}
+
+// evaluateQualityEntry setzt EvaluationOutcome/-Comment, ohne den Eintrag zu speichern.
+func (dpp *DPP) evaluateQualityEntry(qe *QualityEntry) *QualitySpecification {
+	spec := dpp.specFor(qe.TestName)
+	ev := evaluateResult(spec, *qe)
+	qe.EvaluationOutcome = ev.Outcome
+	qe.EvaluationComment = ev.Comment
+	qe.NormalizedResult = ev.NormalizedResult
+	qe.NormalizedUnit = ev.NormalizedUnit
+	qe.Statistics = ev.Statistics
+	return spec
+}
+
+func addUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// disposition: CBV-Disposition für das Inspektions-Event zu diesem Ergebnis.
+func (o EvaluationOutcome) disposition() string {
+	switch {
+	case o == OutcomePass:
+		return "urn:epcglobal:cbv:disp:conformant"
+	case o.isNonConformant():
+		return "urn:epcglobal:cbv:disp:non_conformant"
+	}
+	return "urn:epcglobal:cbv:disp:active"
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testSpec liest eine Spezifikation wie PublishSpecificationSet (UnmarshalJSON setzt die Grenzen).
+func testSpec(t *testing.T, specJSON string) *QualitySpecification {
+	t.Helper()
+	var spec QualitySpecification
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		t.Fatalf("Spezifikation fehlerhaft: %v", err)
+	}
+	if err := spec.validate(); err != nil {
+		t.Fatalf("Spezifikation ungültig: %v", err)
+	}
+	return &spec
+}
+
+func TestEvaluateNumericUnits(t *testing.T) {
+	density := `{"testName":"Dichte","isNumeric":true,"lowerLimit":0.9,"upperLimit":1.1,"unit":"g/cm3"}`
+	tests := []struct {
+		name           string
+		spec           string
+		result, unit   string
+		want           EvaluationOutcome
+		wantNormalized string
+	}{
+		{"gleiche Einheit", density, "1.0", "g/cm3", OutcomePass, ""},
+		{"ohne Einheit", density, "1.0", "", OutcomeUnitMismatch, ""},
+		{"ohne Einheit gegen Cel", `{"testName":"T","isNumeric":true,"upperLimit":8,"unit":"Cel"}`, "12", "", OutcomeUnitMismatch, ""},
+		{"Spezifikation ohne Einheit", `{"testName":"Anteil","isNumeric":true,"upperLimit":8}`, "5", "", OutcomePass, ""},
+		{"umgerechnet innerhalb", density, "1000", "kg/m3", OutcomePass, "1"},
+		{"umgerechnet außerhalb", density, "1200", "kg/m3", OutcomeDeviationHigh, "1.2"},
+		{"andere Dimension", density, "1.0", "g/10min", OutcomeUnitMismatch, ""},
+		{"kein UCUM-Code", density, "1.0", "Gramm pro Liter", OutcomeUnitMismatch, ""},
+		{"Temperatur", `{"testName":"T","isNumeric":true,"lowerLimit":20,"upperLimit":30,"unit":"Cel"}`, "300", "K", OutcomePass, "26.85"},
+		{"Schreibweise ohne UCUM", `{"testName":"Gehalt","isNumeric":true,"lowerLimit":1,"upperLimit":2,"unit":"wt-%"}`, "1.5", "WT-%", OutcomePass, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := testSpec(t, tt.spec)
+			ev := evaluateNumeric(spec, QualityEntry{TestName: spec.TestName, Result: tt.result, Unit: tt.unit})
+			if ev.Outcome != tt.want {
+				t.Errorf("Ergebnis %s, erwartet %s (%s)", ev.Outcome, tt.want, ev.Comment)
+			}
+			if ev.NormalizedResult != tt.wantNormalized {
+				t.Errorf("NormalizedResult %q, erwartet %q", ev.NormalizedResult, tt.wantNormalized)
+			}
+			if tt.wantNormalized != "" && ev.NormalizedUnit != spec.Unit {
+				t.Errorf("NormalizedUnit %q, erwartet %q", ev.NormalizedUnit, spec.Unit)
+			}
+		})
+	}
+}
+
+func TestUnitMismatchIsCritical(t *testing.T) {
+	if !OutcomeUnitMismatch.isCritical() {
+		t.Errorf("UNIT_MISMATCH muss kritisch sein")
+	}
+	spec := testSpec(t, `{"testName":"MFI","isNumeric":true,"lowerLimit":10,"upperLimit":20,"unit":"g/10min","isMandatory":true}`)
+	e := newTestEnv(t)
+	dpp := &DPP{DppID: "U1", Specifications: []QualitySpecification{*spec}}
+	dpp.initChecks()
+	qe := QualityEntry{TestName: "MFI", Result: "15", Unit: "kg/m3"}
+	e.must(dpp.recordEvaluatedEntry(e.ctx("Org1MSP"), &qe, dpp.evaluateQualityEntry(&qe)))
+	if qe.EvaluationOutcome != OutcomeUnitMismatch {
+		t.Fatalf("Ergebnis %s, erwartet UNIT_MISMATCH", qe.EvaluationOutcome)
+	}
+	dpp.applyChecks()
+	if got := dpp.derivedQualityStatus(); got != StatusBlocked {
+		t.Errorf("Status %s, erwartet Blocked", got)
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- EPCIS-Abfragen über alle DPPs --------------------------- //
+//
+// Events liegen je DPP unter DPP~event~<dppId>~<seq>. Damit sie auch DPP-übergreifend
+// gefunden werden ("alle shipping-Events an GLN X", "alle non_conformant-Prüfungen"),
+// schreibt saveDPP zu jedem Event Indexeinträge unter eigenen Composite Keys:
+//
+//	DPP~eventIndex~bizStep~<bizStep>~<eventTime>~<dppId>~<seq>
+//	DPP~eventIndex~disposition~<disposition>~<eventTime>~<dppId>~<seq>
+//	DPP~eventIndex~bizLocation~<bizLocation>~<eventTime>~<dppId>~<seq>
+//	DPP~eventIndex~epc~<EPC-Klasse>~<epc>~<eventTime>~<dppId>~<seq>
+//	DPP~eventIndex~eventTime~<eventTime>~<dppId>~<seq>
+//
+// QueryEvents wählt anhand der Filter den engsten Index, liest die Events und prüft die
+// übrigen Filter. Die Indizes funktionieren mit LevelDB und CouchDB. Events, die vor
+// Einführung der Indizes geschrieben wurden, nimmt RebuildEventIndex auf.
+//
+// bizLocation steht im Index immer als Digital Link (siteLocation), auch bei älteren Events mit
+// SGLN-URN. Wurden solche Events schon unter der URN indiziert, schreibt RebuildEventIndex sie
+// unter dem Digital Link neu.
+
+const eventIndexObjectType = "DPP~eventIndex"
+
+// eventIndexTimeLayout: Zeit im Indexschlüssel (UTC, feste Länge, damit lexikografisch sortierbar).
+const eventIndexTimeLayout = "2006-01-02T15:04:05Z"
+
+// EventQueryRecord: Ein gefundenes Event mit dem DPP, zu dem es gehört.
+type EventQueryRecord struct {
+	DppID string     `json:"dppId"`
+	Event EPCISEvent `json:"event"`
+}
+
+// maxEventScan: Höchstzahl gelesener Indexeinträge je QueryEvents-Aufruf. Trifft ein Filter
+// nur wenige der gelesenen Events, endet die Seite vorher mit Bookmark.
+const maxEventScan = 1000
+
+// EventQueryResult: Eine Seite von QueryEvents in Indexreihenfolge (innerhalb eines
+// Indexwerts chronologisch). Bookmark an die nächste Abfrage mit denselben Filtern übergeben;
+// ist es leer, gibt es keine weiteren Treffer. Eine Seite kann weniger als pageSize Events
+// enthalten, wenn maxEventScan Indexeinträge gelesen wurden.
+type EventQueryResult struct {
+	Records             []EventQueryRecord `json:"records"`
+	FetchedRecordsCount int32              `json:"fetchedRecordsCount"`
+	Bookmark            string             `json:"bookmark"`
+}
+
+// eventQuery: Geprüfte Filter einer Abfrage. Listen sind ODER-verknüpft, Parameter UND-verknüpft.
+type eventQuery struct {
+	eventTypes, actions            []string
+	bizSteps, dispositions         []string
+	readPoints, bizLocations       []string
+	matchEPC, matchAnyEPC          []string
+	matchInputEPC, matchOutputEPC  []string
+	geEventTime, ltEventTime       time.Time
+	hasGEEventTime, hasLTEventTime bool
+}
+
+// QueryEvents: EPCIS-Events aller DPPs nach Filtern im Stil der EPCIS-Query-Schnittstelle.
+// filtersJSON ist ein Objekt mit Parametern, Werte als String oder Liste von Strings:
+//
+//	eventType, EQ_action, EQ_bizStep, EQ_disposition, EQ_readPoint, EQ_bizLocation,
+//	GE_eventTime, LT_eventTime (RFC3339),
+//	MATCH_epc (epcList), MATCH_inputEPC, MATCH_outputEPC, MATCH_anyEPC (alle EPC-Listen)
+//
+// bizStep und disposition als URN, CBV-Web-URI oder Kurzform ("shipping"). MATCH_*-Werte
+// sind EPCs oder Muster wie "urn:epc:idpat:sgtin:4012345.011111.*". Orte als URI oder GLN.
+// Gelesen wird ab Bookmark bzw. GE_eventTime und höchstens maxEventScan Indexeinträge; wie
+// alle paginierten Abfragen nur in evaluateTransaction.
+func (c *DPPQualityContract) QueryEvents(ctx contractapi.TransactionContextInterface, filtersJSON string, pageSize int32, bookmark string) (*EventQueryResult, error) {
+	fmt.Printf("[QueryEvents-DEBUG] Entry: filters=%s, pageSize=%d, bookmark=%s\n", filtersJSON, pageSize, bookmark)
+	if pageSize <= 0 || pageSize > maxQueryPageSize {
+		return nil, fmt.Errorf("pageSize muss zwischen 1 und %d liegen, erhalten: %d", maxQueryPageSize, pageSize)
+	}
+	query, err := parseEventQuery(filtersJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	partial, timeSorted := query.indexKey()
+	prefix, err := ctx.GetStub().CreateCompositeKey(eventIndexObjectType, partial)
+	if err != nil {
+		return nil, err
+	}
+	startKey := ""
+	if bookmark != "" {
+		// Das Bookmark ist der zuletzt gelesene Indexschlüssel; weiter geht es direkt dahinter.
+		decoded, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err != nil || !strings.HasPrefix(string(decoded), prefix) {
+			return nil, fmt.Errorf("ungültiges Bookmark '%s' (Filter geändert?)", bookmark)
+		}
+		startKey = string(decoded) + "\x00"
+	} else if query.hasGEEventTime && timeSorted {
+		// Zeitsortierter Index: erst ab GE_eventTime lesen.
+		if startKey, err = ctx.GetStub().CreateCompositeKey(eventIndexObjectType, append(partial, query.geEventTime.UTC().Format(eventIndexTimeLayout))); err != nil {
+			return nil, err
+		}
+	}
+	upper := ""
+	if query.hasLTEventTime && timeSorted {
+		upper = query.ltEventTime.UTC().Format(eventIndexTimeLayout)
+	}
+
+	result := &EventQueryResult{Records: []EventQueryRecord{}}
+	scanned, last := 0, ""
+	err = scanCompositeKeys(ctx, eventIndexObjectType, partial, startKey, func(key string, _ []byte) (bool, error) {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(key)
+		if err != nil || len(attrs) < 4 {
+			return false, fmt.Errorf("ungültiger Indexschlüssel %s: %v", key, err)
+		}
+		if upper != "" && attrs[len(attrs)-3] > upper {
+			return false, nil // Index ist innerhalb des Werts nach Zeit sortiert
+		}
+		if len(result.Records) == int(pageSize) || scanned == maxEventScan {
+			result.Bookmark = base64.RawURLEncoding.EncodeToString([]byte(last))
+			return false, nil
+		}
+		scanned, last = scanned+1, key
+		dppID, seq := attrs[len(attrs)-2], attrs[len(attrs)-1]
+		evt, err := readEvent(ctx, dppID, seq)
+		if err != nil {
+			return false, err
+		}
+		if query.matches(evt) {
+			result.Records = append(result.Records, EventQueryRecord{DppID: dppID, Event: *evt})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen des Event-Index: %v", err)
+	}
+	result.FetchedRecordsCount = int32(len(result.Records))
+	fmt.Printf("[QueryEvents-INFO] Index %v: %d Einträge gelesen, %d Events gefunden.\n", partial, scanned, len(result.Records))
+	return result, nil
+}
+
+// RebuildEventIndex: Nimmt die Events eines DPP in den Event-Index auf, z.B. für DPPs, die vor
+// Einführung von QueryEvents angelegt wurden. Liefert die Anzahl der Events.
+func (c *DPPQualityContract) RebuildEventIndex(ctx contractapi.TransactionContextInterface, dppID string) (int, error) {
+	fmt.Printf("[RebuildEventIndex-DEBUG] Entry: dppID=%s\n", dppID)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return 0, err
+	}
+	if dpp.embedded {
+		// Altes Format: saveDPP überführt die Events in eigene Schlüssel und indiziert sie dabei.
+		count := len(dpp.EPCISEvents)
+		if err := c.saveDPP(ctx, dpp); err != nil {
+			return 0, err
+		}
+		return count, nil
+	}
+	count := 0
+	if err := forEachRecordKey(ctx, eventObjectType, dppID, func(seq string, data []byte) error {
+		var evt EPCISEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return err
+		}
+		count++
+		return putEventIndex(ctx, dppID, seq, evt)
+	}); err != nil {
+		return 0, fmt.Errorf("Fehler beim Indizieren der Events von DPP %s: %v", dppID, err)
+	}
+	fmt.Printf("[RebuildEventIndex-INFO] %d Events von DPP %s indiziert.\n", count, dppID)
+	return count, nil
+}
+
+// putEventIndex schreibt die Indexeinträge eines Events. Wert ist wie bei Fabric-Indizes üblich
+// ein einzelnes Null-Byte; der Schlüssel enthält alles Nötige.
+func putEventIndex(ctx contractapi.TransactionContextInterface, dppID, seq string, evt EPCISEvent) error {
+	for _, attrs := range eventIndexAttributes(evt) {
+		key, err := ctx.GetStub().CreateCompositeKey(eventIndexObjectType, append(attrs, dppID, seq))
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("PutState für Event-Index %s fehlgeschlagen: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// eventIndexAttributes: Attribute der Indexeinträge eines Events (ohne dppId und seq).
+func eventIndexAttributes(evt EPCISEvent) [][]string {
+	ts := eventIndexTime(evt.EventTime)
+	indexes := [][]string{{"eventTime", ts}}
+	for _, f := range []struct{ name, value string }{
+		{"bizStep", evt.BizStep},
+		{"disposition", evt.Disposition},
+		{"bizLocation", siteLocation(evt.BizLocation)},
+	} {
+		if f.value != "" {
+			indexes = append(indexes, []string{f.name, f.value, ts})
+		}
+	}
+	seen := map[string]bool{}
+	for _, epc := range evt.allEPCs() {
+		if epc == "" || seen[epc] {
+			continue
+		}
+		seen[epc] = true
+		indexes = append(indexes, []string{"epc", epcClass(epc), epc, ts})
+	}
+	return indexes
+}
+
+// eventIndexTime: Eventzeit im Indexformat; nicht lesbare Zeiten bleiben unverändert.
+func eventIndexTime(eventTime string) string {
+	t, err := time.Parse(time.RFC3339, eventTime)
+	if err != nil {
+		return eventTime
+	}
+	return t.UTC().Format(eventIndexTimeLayout)
+}
+
+// epcClass: Muster der Klasse einer EPC-URN, die letzte Komponente wird durch "*" ersetzt
+// ("urn:epc:id:sgtin:4012345.011111.1001" -> "urn:epc:idpat:sgtin:4012345.011111.*").
+// Andere Kennungen sind ihre eigene Klasse.
+func epcClass(epc string) string {
+	rest := strings.TrimPrefix(epc, "urn:epc:id:")
+	dot := strings.LastIndex(rest, ".")
+	if rest == epc || dot < 0 {
+		return epc
+	}
+	return "urn:epc:idpat:" + rest[:dot] + ".*"
+}
+
+// epcMatches: EPC gegen eine EPC oder ein idpat-Muster ("*" steht für eine Komponente).
+func epcMatches(pattern, epc string) bool {
+	if !strings.HasPrefix(pattern, "urn:epc:idpat:") {
+		return pattern == epc
+	}
+	if !strings.HasPrefix(epc, "urn:epc:id:") {
+		return false
+	}
+	pScheme, pParts := splitEPC(strings.TrimPrefix(pattern, "urn:epc:idpat:"))
+	eScheme, eParts := splitEPC(strings.TrimPrefix(epc, "urn:epc:id:"))
+	if pScheme != eScheme || len(pParts) != len(eParts) {
+		return false
+	}
+	for i := range pParts {
+		if pParts[i] != "*" && pParts[i] != eParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitEPC(s string) (string, []string) {
+	colon := strings.Index(s, ":")
+	if colon < 0 {
+		return "", nil
+	}
+	return s[:colon], strings.Split(s[colon+1:], ".")
+}
+
+func (evt EPCISEvent) allEPCs() []string {
+	all := append([]string{}, evt.EPCList...)
+	all = append(all, evt.InputEPCList...)
+	return append(all, evt.OutputEPCList...)
+}
+
+// readEvent liest ein einzelnes Event aus seinem Schlüssel.
+func readEvent(ctx contractapi.TransactionContextInterface, dppID, seq string) (*EPCISEvent, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(eventObjectType, []string{dppID, seq})
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen von Event %s: %v", seq, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("Event %s von DPP %s nicht gefunden (Index veraltet)", seq, dppID)
+	}
+	var evt EPCISEvent
+	if err := json.Unmarshal(data, &evt); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling von Event %s: %v", seq, err)
+	}
+	return &evt, nil
+}
+
+// parseEventQuery prüft die Filter. Unbekannte Parameter werden abgelehnt, damit Tippfehler
+// nicht stillschweigend alle Events liefern.
+func parseEventQuery(filtersJSON string) (*eventQuery, error) {
+	var raw map[string]json.RawMessage
+	if strings.TrimSpace(filtersJSON) == "" {
+		filtersJSON = "{}"
+	}
+	if err := json.Unmarshal([]byte(filtersJSON), &raw); err != nil {
+		return nil, fmt.Errorf("filtersJSON fehlerhaft (erwartet JSON-Objekt): %v", err)
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	q := &eventQuery{}
+	for _, name := range names {
+		var values []string
+		if err := json.Unmarshal(raw[name], &values); err != nil {
+			var single string
+			if err := json.Unmarshal(raw[name], &single); err != nil {
+				return nil, fmt.Errorf("Abfrageparameter %s: erwartet String oder Liste von Strings", name)
+			}
+			values = []string{single}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("Abfrageparameter %s ohne Wert", name)
+		}
+		switch name {
+		case "eventType":
+			q.eventTypes = values
+		case "EQ_action":
+			q.actions = values
+		case "EQ_bizStep":
+			q.bizSteps = cbvURNs(values, "urn:epcglobal:cbv:bizstep:", "BizStep-")
+		case "EQ_disposition":
+			q.dispositions = cbvURNs(values, "urn:epcglobal:cbv:disp:", "Disp-")
+		case "EQ_readPoint":
+			q.readPoints = siteLocations(values)
+		case "EQ_bizLocation":
+			q.bizLocations = siteLocations(values)
+		case "MATCH_epc":
+			q.matchEPC = values
+		case "MATCH_anyEPC":
+			q.matchAnyEPC = values
+		case "MATCH_inputEPC":
+			q.matchInputEPC = values
+		case "MATCH_outputEPC":
+			q.matchOutputEPC = values
+		case "GE_eventTime", "LT_eventTime":
+			if len(values) != 1 {
+				return nil, fmt.Errorf("%s erwartet genau einen Zeitpunkt", name)
+			}
+			t, err := time.Parse(time.RFC3339, values[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s '%s' ist kein RFC3339-Zeitpunkt", name, values[0])
+			}
+			if name == "GE_eventTime" {
+				q.geEventTime, q.hasGEEventTime = t, true
+			} else {
+				q.ltEventTime, q.hasLTEventTime = t, true
+			}
+		default:
+			return nil, fmt.Errorf("unbekannter Abfrageparameter '%s'", name)
+		}
+	}
+	if q.hasGEEventTime && q.hasLTEventTime && !q.geEventTime.Before(q.ltEventTime) {
+		return nil, fmt.Errorf("GE_eventTime %s liegt nicht vor LT_eventTime %s", q.geEventTime.Format(time.RFC3339), q.ltEventTime.Format(time.RFC3339))
+	}
+	return q, nil
+}
+
+// cbvURNs bringt bizStep/disposition in die gespeicherte URN-Form.
+func cbvURNs(values []string, urnPrefix, webPrefix string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		switch {
+		case strings.HasPrefix(v, "https://ref.gs1.org/cbv/"+webPrefix):
+			v = urnPrefix + strings.TrimPrefix(v, "https://ref.gs1.org/cbv/"+webPrefix)
+		case !strings.Contains(v, ":"):
+			v = urnPrefix + v
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// siteLocations: Orte als GLN oder SGLN-URN werden wie beim Schreiben in den Digital Link
+// umgesetzt (siehe siteLocation).
+func siteLocations(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, siteLocation(v))
+	}
+	return out
+}
+
+// indexKey wählt den Index: EPC, bizLocation, bizStep, disposition (jeweils nur bei genau
+// einem Wert), sonst den Zeitindex. timeSorted gibt an, ob die Treffer nach Zeit sortiert sind.
+func (q *eventQuery) indexKey() ([]string, bool) {
+	for _, patterns := range [][]string{q.matchEPC, q.matchAnyEPC, q.matchInputEPC, q.matchOutputEPC} {
+		if len(patterns) != 1 {
+			continue
+		}
+		p := patterns[0]
+		switch {
+		case !strings.HasPrefix(p, "urn:epc:idpat:"):
+			return []string{"epc", epcClass(p), p}, true
+		case strings.Count(p, "*") == 1 && strings.HasSuffix(p, ".*"):
+			return []string{"epc", p}, false
+		}
+	}
+	for _, f := range []struct {
+		name   string
+		values []string
+	}{{"bizLocation", q.bizLocations}, {"bizStep", q.bizSteps}, {"disposition", q.dispositions}} {
+		if len(f.values) == 1 {
+			return []string{f.name, f.values[0]}, true
+		}
+	}
+	return []string{"eventTime"}, true
+}
+
+// matches prüft alle Filter gegen ein Event.
+func (q *eventQuery) matches(evt *EPCISEvent) bool {
+	if !matchAny(q.eventTypes, evt.EventType) || !matchAny(q.actions, evt.Action) ||
+		!matchAny(q.bizSteps, evt.BizStep) || !matchAny(q.dispositions, evt.Disposition) ||
+		!matchAny(q.readPoints, siteLocation(evt.ReadPoint)) || !matchAny(q.bizLocations, siteLocation(evt.BizLocation)) {
+		return false
+	}
+	if q.hasGEEventTime || q.hasLTEventTime {
+		t, err := time.Parse(time.RFC3339, evt.EventTime)
+		if err != nil || (q.hasGEEventTime && t.Before(q.geEventTime)) || (q.hasLTEventTime && !t.Before(q.ltEventTime)) {
+			return false
+		}
+	}
+	return matchEPCs(q.matchEPC, evt.EPCList) && matchEPCs(q.matchInputEPC, evt.InputEPCList) &&
+		matchEPCs(q.matchOutputEPC, evt.OutputEPCList) && matchEPCs(q.matchAnyEPC, evt.allEPCs())
+}
+
+// matchAny: leere Filterliste trifft immer zu.
+func matchAny(values []string, v string) bool {
+	return len(values) == 0 || containsString(values, v)
+}
+
+func matchEPCs(patterns, epcs []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		for _, epc := range epcs {
+			if epcMatches(p, epc) {
+				return true
+			}
+		}
+	}
+	return false
+}
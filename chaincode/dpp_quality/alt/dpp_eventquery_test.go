@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventQueryEnv: Drei DPPs mit Anlage, Prüfung und (für E2) Versand.
+func eventQueryEnv(t *testing.T) (*testEnv, *DPPQualityContract) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-Q", retestSpecs, "", "")
+	e.must(err)
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("E%d", i)
+		_, err := c.CreateDPP(e.ctx("Org1MSP"), id, fmt.Sprintf("urn:epc:id:sgtin:4012345.011111.%d", 4000+i), "PT-Q", "4012345000016", "L1", "2025-06-01", 0, "", 0)
+		e.must(err)
+		e.must(c.RecordQualityData(e.ctx("Org1MSP"), id, qualityJSON("MFI", "15", "g/10min"), "4012345000016"))
+	}
+	e.must(c.TransferDPP(e.ctx("Org1MSP"), "E2", "Org2MSP", "4012345000016"))
+	return e, c
+}
+
+// queryAll liest alle Seiten und liefert die Event-IDs und die Anzahl der Aufrufe.
+func queryAll(t *testing.T, e *testEnv, c *DPPQualityContract, filters string, pageSize int32) ([]string, int) {
+	t.Helper()
+	var ids []string
+	bookmark := ""
+	for calls := 1; ; calls++ {
+		r, err := c.QueryEvents(e.ctx("Org3MSP"), filters, pageSize, bookmark)
+		e.must(err)
+		if int(r.FetchedRecordsCount) != len(r.Records) || len(r.Records) > int(pageSize) {
+			t.Fatalf("Seite mit %d Events, fetchedRecordsCount %d, pageSize %d", len(r.Records), r.FetchedRecordsCount, pageSize)
+		}
+		for _, rec := range r.Records {
+			ids = append(ids, rec.DppID+"/"+rec.Event.EventID)
+		}
+		if r.Bookmark == "" {
+			return ids, calls
+		}
+		if calls > 100 {
+			t.Fatalf("Bookmark endet nicht")
+		}
+		bookmark = r.Bookmark
+	}
+}
+
+func TestQueryEventsPaging(t *testing.T) {
+	e, c := eventQueryEnv(t)
+	for _, filters := range []string{
+		`{}`,
+		`{"MATCH_epc":"urn:epc:idpat:sgtin:4012345.011111.*"}`,
+		`{"EQ_bizStep":"inspecting"}`,
+		`{"MATCH_anyEPC":"urn:epc:id:sgtin:4012345.011111.4002"}`,
+	} {
+		all, calls := queryAll(t, e, c, filters, maxQueryPageSize)
+		if len(all) == 0 || calls != 1 {
+			t.Fatalf("%s: %d Events in %d Aufrufen", filters, len(all), calls)
+		}
+		for _, pageSize := range []int32{1, 2, 3} {
+			paged, _ := queryAll(t, e, c, filters, pageSize)
+			if !reflect.DeepEqual(paged, all) {
+				t.Errorf("%s, pageSize %d: %v, erwartet %v", filters, pageSize, paged, all)
+			}
+		}
+	}
+}
+
+func TestQueryEventsTimeRange(t *testing.T) {
+	e, c := eventQueryEnv(t)
+	r, err := c.QueryEvents(e.ctx("Org3MSP"), `{}`, maxQueryPageSize, "")
+	e.must(err)
+	all := r.Records
+
+	ge, lt := e.base.Add(4*time.Minute), e.base.Add(8*time.Minute)
+	var want []string
+	for _, rec := range all {
+		if ts, _ := time.Parse(time.RFC3339, rec.Event.EventTime); !ts.Before(ge) && ts.Before(lt) {
+			want = append(want, rec.DppID+"/"+rec.Event.EventID)
+		}
+	}
+	if len(want) == 0 || len(want) == len(all) {
+		t.Fatalf("Zeitraum trifft %d von %d Events", len(want), len(all))
+	}
+	filters := fmt.Sprintf(`{"GE_eventTime":%q,"LT_eventTime":%q}`, ge.Format(time.RFC3339), lt.Format(time.RFC3339))
+	for _, pageSize := range []int32{1, maxQueryPageSize} {
+		got, _ := queryAll(t, e, c, filters, pageSize)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pageSize %d: %v, erwartet %v", pageSize, got, want)
+		}
+	}
+}
+
+func TestQueryEventsScanLimit(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	ctx := e.ctx("Org1MSP")
+	total := maxEventScan + 5
+	for i := 0; i < total; i++ {
+		seq := fmt.Sprintf("%06d", i)
+		evt := EPCISEvent{EventID: "evt-" + seq, EventType: "ObjectEvent", Action: "OBSERVE", EventTime: e.base.Add(time.Duration(i) * time.Second).Format(time.RFC3339)}
+		data, err := json.Marshal(evt)
+		e.must(err)
+		key, err := ctx.GetStub().CreateCompositeKey(eventObjectType, []string{"S1", seq})
+		e.must(err)
+		e.must(ctx.GetStub().PutState(key, data))
+		e.must(putEventIndex(ctx, "S1", seq, evt))
+	}
+
+	// Kein Treffer: Der erste Aufruf endet nach maxEventScan Einträgen mit Bookmark.
+	r, err := c.QueryEvents(e.ctx("Org3MSP"), `{"eventType":"AggregationEvent"}`, 10, "")
+	e.must(err)
+	if len(r.Records) != 0 || r.Bookmark == "" {
+		t.Fatalf("erster Aufruf: %d Events, Bookmark %q", len(r.Records), r.Bookmark)
+	}
+	r, err = c.QueryEvents(e.ctx("Org3MSP"), `{"eventType":"AggregationEvent"}`, 10, r.Bookmark)
+	e.must(err)
+	if len(r.Records) != 0 || r.Bookmark != "" {
+		t.Fatalf("zweiter Aufruf: %d Events, Bookmark %q", len(r.Records), r.Bookmark)
+	}
+
+	all, calls := queryAll(t, e, c, `{"eventType":"ObjectEvent"}`, maxQueryPageSize)
+	if len(all) != total || calls != (total+maxQueryPageSize-1)/maxQueryPageSize {
+		t.Errorf("%d Events in %d Aufrufen, erwartet %d", len(all), calls, total)
+	}
+
+	// Ein Bookmark gilt nur für den Index, aus dem es stammt.
+	r, err = c.QueryEvents(e.ctx("Org3MSP"), `{}`, 1, "")
+	e.must(err)
+	if _, err := c.QueryEvents(e.ctx("Org3MSP"), `{"EQ_bizStep":"shipping"}`, 1, r.Bookmark); err == nil || !strings.Contains(err.Error(), "ungültiges Bookmark") {
+		t.Errorf("Bookmark eines anderen Index: Fehler %v", err)
+	}
+}
+
+func TestSiteLocation(t *testing.T) {
+	const want = "https://id.gs1.org/414/4012345000016"
+	for in, out := range map[string]string{
+		"4012345000016":                        want,
+		"urn:epc:id:sgln:4012345.00001.0":      want,
+		"urn:epc:id:sgln:4012345.00001.7":      want,
+		"urn:epc:id:sgln:4012345000016.0.0":    want, // frühere Form
+		want:                                   want,
+		"":                                     "",
+		"urn:epc:id:sgtin:4012345.011111.1001": "urn:epc:id:sgtin:4012345.011111.1001",
+	} {
+		if got := siteLocation(in); got != out {
+			t.Errorf("siteLocation(%q) = %q, erwartet %q", in, got, out)
+		}
+	}
+}
+
+// TestQueryEventsLegacyLocation: Events mit älteren Ortsangaben werden über GLN, SGLN-URN und
+// Digital Link gleichermaßen gefunden.
+func TestQueryEventsLegacyLocation(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	ctx := e.ctx("Org1MSP")
+	for i, loc := range []string{"urn:epc:id:sgln:4012345000016.0.0", "urn:epc:id:sgln:4012345.00001.0", "https://id.gs1.org/414/4012345000016", "https://id.gs1.org/414/4098765000010"} {
+		seq := fmt.Sprintf("%06d", i)
+		evt := EPCISEvent{EventID: "evt-" + seq, EventType: "ObjectEvent", Action: "OBSERVE", EventTime: e.base.Add(time.Duration(i) * time.Second).Format(time.RFC3339), ReadPoint: loc, BizLocation: loc}
+		data, err := json.Marshal(evt)
+		e.must(err)
+		key, err := ctx.GetStub().CreateCompositeKey(eventObjectType, []string{"L1", seq})
+		e.must(err)
+		e.must(ctx.GetStub().PutState(key, data))
+		e.must(putEventIndex(ctx, "L1", seq, evt))
+	}
+
+	want := []string{"L1/evt-000000", "L1/evt-000001", "L1/evt-000002"}
+	for _, filters := range []string{
+		`{"EQ_bizLocation":"4012345000016"}`,
+		`{"EQ_bizLocation":"urn:epc:id:sgln:4012345.00001.0"}`,
+		`{"EQ_bizLocation":"https://id.gs1.org/414/4012345000016"}`,
+		`{"EQ_readPoint":"4012345000016"}`,
+		`{"EQ_bizLocation":["4012345000016","urn:epc:id:sgln:4012345000016.0.0"]}`,
+	} {
+		if got, _ := queryAll(t, e, c, filters, maxQueryPageSize); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %v, erwartet %v", filters, got, want)
+		}
+	}
+}
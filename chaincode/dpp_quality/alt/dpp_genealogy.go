@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Genealogie (Rückverfolgung) --------------------------- //
+
+const maxTraceDepth = 25
+
+// GenealogyNode: Ein DPP im Produktbaum. Depth ist der Abstand zum Start-DPP.
+type GenealogyNode struct {
+	DppID    string    `json:"dppId"`
+	GS1Key   string    `json:"gs1Key"`
+	Status   DPPStatus `json:"status"`
+	OwnerOrg string    `json:"ownerOrg"`
+	Depth    int       `json:"depth"`
+}
+
+// GenealogyEdge: Input -> Output, verbunden durch das TransformationEvent des Outputs.
+type GenealogyEdge struct {
+	FromDPPID             string `json:"fromDppId"`
+	ToDPPID               string `json:"toDppId"`
+	TransformationEventID string `json:"transformationEventId,omitempty" metadata:",optional"` // leer bei DPPs aus älteren Versionen
+}
+
+// GenealogyGraph: Ergebnis von TraceUpstream/TraceDownstream. Truncated ist true, wenn
+// der Baum über MaxDepth hinaus weitergeht.
+type GenealogyGraph struct {
+	RootDPPID string          `json:"rootDppId"`
+	Direction string          `json:"direction"` // "upstream" oder "downstream"
+	MaxDepth  int             `json:"maxDepth"`
+	Nodes     []GenealogyNode `json:"nodes"`
+	Edges     []GenealogyEdge `json:"edges"`
+	Truncated bool            `json:"truncated"`
+}
+
+// TraceUpstream: Alle Vorprodukte eines DPP über beliebig viele Transformationsstufen
+// (begrenzt durch maxDepth).
+func (c *DPPQualityContract) TraceUpstream(ctx contractapi.TransactionContextInterface, dppID string, maxDepth int) (*GenealogyGraph, error) {
+	return c.traceGenealogy(ctx, dppID, maxDepth, "upstream")
+}
+
+// TraceDownstream: Alle Folgeprodukte, in die ein DPP (auch mittelbar) eingegangen ist.
+func (c *DPPQualityContract) TraceDownstream(ctx contractapi.TransactionContextInterface, dppID string, maxDepth int) (*GenealogyGraph, error) {
+	return c.traceGenealogy(ctx, dppID, maxDepth, "downstream")
+}
+
+// traceGenealogy: Breitensuche über InputDPPIDs bzw. OutputDPPIDs. Jeder DPP erscheint
+// genau einmal als Knoten, auch wenn er über mehrere Pfade erreichbar ist.
+func (c *DPPQualityContract) traceGenealogy(ctx contractapi.TransactionContextInterface, rootID string, maxDepth int, direction string) (*GenealogyGraph, error) {
+	fmt.Printf("[traceGenealogy-DEBUG] Entry: dppID=%s, maxDepth=%d, direction=%s\n", rootID, maxDepth, direction)
+	if maxDepth < 1 || maxDepth > maxTraceDepth {
+		return nil, fmt.Errorf("maxDepth muss zwischen 1 und %d liegen, erhalten: %d", maxTraceDepth, maxDepth)
+	}
+
+	graph := &GenealogyGraph{RootDPPID: rootID, Direction: direction, MaxDepth: maxDepth, Nodes: []GenealogyNode{}, Edges: []GenealogyEdge{}}
+	cache := map[string]*DPP{}
+	load := func(id string) (*DPP, error) {
+		if dpp, ok := cache[id]; ok {
+			return dpp, nil
+		}
+		dpp, err := c.readDPPHeader(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.deriveStatus(ctx, dpp); err != nil {
+			return nil, err
+		}
+		cache[id] = dpp
+		return dpp, nil
+	}
+
+	root, err := load(rootID)
+	if err != nil {
+		return nil, err
+	}
+	visited := map[string]bool{rootID: true}
+	graph.Nodes = append(graph.Nodes, genealogyNode(root, 0))
+
+	frontier := []*DPP{root}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []*DPP
+		for _, current := range frontier {
+			neighbours := current.InputDPPIDs
+			if direction == "downstream" {
+				neighbours = current.outputDPPIDs()
+			}
+			if len(neighbours) > 0 && depth > maxDepth {
+				graph.Truncated = true
+				continue
+			}
+			for _, id := range neighbours {
+				neighbour, err := load(id)
+				if err != nil {
+					return nil, fmt.Errorf("Genealogie von DPP %s unvollständig: %v", current.DppID, err)
+				}
+				if direction == "downstream" {
+					graph.Edges = append(graph.Edges, GenealogyEdge{FromDPPID: current.DppID, ToDPPID: id, TransformationEventID: neighbour.TransformationEventID})
+				} else {
+					graph.Edges = append(graph.Edges, GenealogyEdge{FromDPPID: id, ToDPPID: current.DppID, TransformationEventID: current.TransformationEventID})
+				}
+				if visited[id] {
+					continue
+				}
+				visited[id] = true
+				graph.Nodes = append(graph.Nodes, genealogyNode(neighbour, depth))
+				next = append(next, neighbour)
+			}
+		}
+		frontier = next
+	}
+	fmt.Printf("[traceGenealogy-INFO] %s von %s: %d Knoten, %d Kanten, truncated=%t\n", direction, rootID, len(graph.Nodes), len(graph.Edges), graph.Truncated)
+	return graph, nil
+}
+
+func genealogyNode(dpp *DPP, depth int) GenealogyNode {
+	return GenealogyNode{DppID: dpp.DppID, GS1Key: dpp.GS1Key, Status: dpp.Status, OwnerOrg: dpp.OwnerOrg, Depth: depth}
+}
+
+// outputDPPIDs: Folgeprodukte dieses DPP. DPPs aus älteren Versionen haben nur
+// ConsumedByDPPID (bzw. den Status "ConsumedInTransformation_<id>", siehe migrateLegacyStatus).
+func (dpp *DPP) outputDPPIDs() []string {
+	if len(dpp.OutputDPPIDs) > 0 {
+		return dpp.OutputDPPIDs
+	}
+	if dpp.ConsumedByDPPID != "" {
+		return []string{dpp.ConsumedByDPPID}
+	}
+	return nil
+}
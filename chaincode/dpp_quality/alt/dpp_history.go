@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Versionshistorie --------------------------- //
+//
+// GetDPPHistory setzt die Historie eines DPP aus zwei Quellen zusammen:
+//   - GetHistoryForKey auf dem DPP-Kopf liefert jede committete Kopf-Version
+//     (Status, Eigentümer, Sonderfreigaben, …). Die Prüfstände je Test (DPP~check) gehören
+//     nicht zum Kopf; der Status einer Version ist der zuletzt übernommene.
+//   - Qualitätseinträge, Events und Transport-Einträge werden je einmal unter eigenem Schlüssel geschrieben;
+//     ihre Sequenz enthält die TxID (siehe nextRecordSeq) und wird der Version zugeordnet.
+// Eine Transaktion, die nur einen Qualitätseintrag hinzufügt, erscheint daher als Version
+// ohne Kopf-Änderung.
+
+// FieldChange: Geändertes Feld des DPP-Kopfs gegenüber der vorherigen Version.
+type FieldChange struct {
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"oldValue,omitempty" metadata:",optional"`
+	NewValue interface{} `json:"newValue,omitempty" metadata:",optional"`
+}
+
+// DPPVersion: Eine Transaktion, die den DPP verändert hat.
+type DPPVersion struct {
+	TxID                string         `json:"txId"`
+	Timestamp           string         `json:"timestamp"`
+	IsDelete            bool           `json:"isDelete"`
+	HeaderChanged       bool           `json:"headerChanged"`
+	Header              *DPP           `json:"header,omitempty"              metadata:",optional"` // Kopf nach dieser Transaktion
+	Changes             []FieldChange  `json:"changes,omitempty"             metadata:",optional"`
+	AddedQualityEntries []QualityEntry `json:"addedQualityEntries,omitempty" metadata:",optional"`
+	AddedEvents         []EPCISEvent   `json:"addedEvents,omitempty"         metadata:",optional"`
+
+	AddedTransportEntries []TransportConditionLogEntry `json:"addedTransportEntries,omitempty" metadata:",optional"`
+
+	unixNano  int64
+	rawHeader []byte
+}
+
+// DPPHistoryResult: Eine Seite der Historie, chronologisch aufsteigend. Bookmark an die
+// nächste Abfrage übergeben; leer, wenn keine weiteren Versionen vorhanden sind.
+type DPPHistoryResult struct {
+	Versions            []*DPPVersion `json:"versions"`
+	FetchedRecordsCount int32         `json:"fetchedRecordsCount"`
+	Bookmark            string        `json:"bookmark"`
+}
+
+// GetDPPHistory: Alle Versionen eines DPP mit TxID, Zeitstempel, Lösch-Flag und Diff.
+// pageSize begrenzt die Anzahl Versionen pro Aufruf, bookmark ist der Wert aus dem
+// vorherigen Ergebnis (leer für die erste Seite).
+func (c *DPPQualityContract) GetDPPHistory(ctx contractapi.TransactionContextInterface, dppID string, pageSize int32, bookmark string) (*DPPHistoryResult, error) {
+	fmt.Printf("[GetDPPHistory-DEBUG] Entry: dppID=%s, pageSize=%d, bookmark=%s\n", dppID, pageSize, bookmark)
+	if pageSize <= 0 || pageSize > maxQueryPageSize {
+		return nil, fmt.Errorf("pageSize muss zwischen 1 und %d liegen, erhalten: %d", maxQueryPageSize, pageSize)
+	}
+	after := ""
+	if bookmark != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err != nil || len(decoded) < 22 || decoded[20] != '-' {
+			return nil, fmt.Errorf("ungültiges Bookmark '%s'", bookmark)
+		}
+		if _, err := strconv.ParseInt(string(decoded[:20]), 10, 64); err != nil {
+			return nil, fmt.Errorf("ungültiges Bookmark '%s'", bookmark)
+		}
+		after = string(decoded)
+	}
+
+	versions, more, err := c.collectDPPVersions(ctx, dppID, after, int(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 && after == "" {
+		return nil, fmt.Errorf("keine Historie für DPP %s gefunden", dppID)
+	}
+
+	result := &DPPHistoryResult{Versions: versions, FetchedRecordsCount: int32(len(versions))}
+	if more {
+		last := versions[len(versions)-1]
+		result.Bookmark = base64.RawURLEncoding.EncodeToString([]byte(versionKey(last.unixNano, last.TxID)))
+	}
+	return result, nil
+}
+
+// versionKey: Sortierschlüssel einer Version, entspricht der Sequenz ihrer Einzel-Datensätze
+// ohne laufende Nummer (siehe nextRecordSeq).
+func versionKey(unixNano int64, txID string) string {
+	return fmt.Sprintf("%020d-%s", unixNano, txID)
+}
+
+// collectDPPVersions liefert bis zu limit Versionen nach der Version after, chronologisch
+// geordnet, und ob weitere folgen.
+//
+// Die Einzel-Datensätze werden ab after gelesen, je Schlüsseltyp höchstens für limit
+// Transaktionen. GetHistoryForKey lässt sich nicht ab einer Position lesen, liefert aber ab
+// Fabric 2.0 die neueste Version zuerst; gelesen werden daher die Kopf-Versionen nach after
+// und die letzte davor, die Basis für den Diff der ersten Version der Seite ist.
+func (c *DPPQualityContract) collectDPPVersions(ctx contractapi.TransactionContextInterface, dppID, after string, limit int) ([]*DPPVersion, bool, error) {
+	byTx := map[string]*DPPVersion{}
+	versionFor := func(txID string, unixNano int64) *DPPVersion {
+		v, ok := byTx[txID]
+		if !ok {
+			v = &DPPVersion{TxID: txID, Timestamp: time.Unix(0, unixNano).UTC().Format(time.RFC3339), unixNano: unixNano}
+			byTx[txID] = v
+		}
+		return v
+	}
+
+	var previous *DPP
+	histIter, err := ctx.GetStub().GetHistoryForKey(dppPrefix + dppID)
+	if err != nil {
+		return nil, false, fmt.Errorf("Fehler beim Lesen der Historie von DPP %s: %v", dppID, err)
+	}
+	defer histIter.Close()
+	for histIter.HasNext() {
+		km, err := histIter.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		unixNano := km.Timestamp.AsTime().UnixNano()
+		if after != "" && versionKey(unixNano, km.TxId) <= after {
+			if !km.IsDelete {
+				previous = &DPP{}
+				if err := json.Unmarshal(km.Value, previous); err != nil {
+					return nil, false, fmt.Errorf("Fehler beim Unmarshalling der DPP-Version %s: %v", km.TxId, err)
+				}
+			}
+			break
+		}
+		v := versionFor(km.TxId, unixNano)
+		v.HeaderChanged = true
+		v.IsDelete = km.IsDelete
+		v.rawHeader = km.Value
+	}
+
+	for _, records := range []struct {
+		objectType, label string
+		add               func(v *DPPVersion, data []byte) error
+	}{
+		{qualityObjectType, "der Qualitätseinträge", func(v *DPPVersion, data []byte) error {
+			var qe QualityEntry
+			if err := json.Unmarshal(data, &qe); err != nil {
+				return err
+			}
+			v.AddedQualityEntries = append(v.AddedQualityEntries, qe)
+			return nil
+		}},
+		{eventObjectType, "der EPCIS-Events", func(v *DPPVersion, data []byte) error {
+			var evt EPCISEvent
+			if err := json.Unmarshal(data, &evt); err != nil {
+				return err
+			}
+			v.AddedEvents = append(v.AddedEvents, evt)
+			return nil
+		}},
+		{transportObjectType, "des Transportprotokolls", func(v *DPPVersion, data []byte) error {
+			var entry TransportConditionLogEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			v.AddedTransportEntries = append(v.AddedTransportEntries, entry)
+			return nil
+		}},
+	} {
+		if err := scanRecordVersions(ctx, records.objectType, dppID, after, limit, func(unixNano int64, txID string, data []byte) error {
+			v := versionFor(txID, unixNano)
+			if data == nil {
+				return nil // erste Transaktion nach der Seite, zeigt nur an, dass weitere folgen
+			}
+			return records.add(v, data)
+		}); err != nil {
+			return nil, false, fmt.Errorf("Fehler beim Lesen %s von DPP %s: %v", records.label, dppID, err)
+		}
+	}
+
+	versions := make([]*DPPVersion, 0, len(byTx))
+	for _, v := range byTx {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].unixNano != versions[j].unixNano {
+			return versions[i].unixNano < versions[j].unixNano
+		}
+		return versions[i].TxID < versions[j].TxID
+	})
+	more := len(versions) > limit
+	if more {
+		versions = versions[:limit]
+	}
+
+	prevQuality, prevEvents, prevTransport := 0, 0, 0
+	if previous != nil {
+		prevQuality, prevEvents, prevTransport = len(previous.Quality), len(previous.EPCISEvents), len(previous.TransportLog)
+		previous.Quality, previous.EPCISEvents, previous.TransportLog = nil, nil, nil
+	}
+	for _, v := range versions {
+		if !v.HeaderChanged {
+			continue
+		}
+		if !v.IsDelete {
+			v.Header = &DPP{}
+			if err := json.Unmarshal(v.rawHeader, v.Header); err != nil {
+				return nil, false, fmt.Errorf("Fehler beim Unmarshalling der DPP-Version %s: %v", v.TxID, err)
+			}
+		}
+		v.Changes = diffDPPHeaders(previous, v.Header)
+		if v.Header != nil {
+			// DPPs im alten Format hatten Einträge und Events im Kopf eingebettet.
+			if len(v.Header.Quality) > prevQuality {
+				v.AddedQualityEntries = append(v.AddedQualityEntries, v.Header.Quality[prevQuality:]...)
+			}
+			if len(v.Header.EPCISEvents) > prevEvents {
+				v.AddedEvents = append(v.AddedEvents, v.Header.EPCISEvents[prevEvents:]...)
+			}
+			if len(v.Header.TransportLog) > prevTransport {
+				v.AddedTransportEntries = append(v.AddedTransportEntries, v.Header.TransportLog[prevTransport:]...)
+			}
+			prevQuality, prevEvents, prevTransport = len(v.Header.Quality), len(v.Header.EPCISEvents), len(v.Header.TransportLog)
+			v.Header.Quality = nil
+			v.Header.EPCISEvents = nil
+			v.Header.TransportLog = nil
+		}
+		previous = v.Header
+	}
+	return versions, more, nil
+}
+
+// scanRecordVersions liest die Einzel-Datensätze eines DPP nach der Version after in
+// Schlüsselreihenfolge, bis die Datensätze von limit Transaktionen übergeben sind. Von der
+// nächsten Transaktion wird nur die Version (data nil) übergeben.
+func scanRecordVersions(ctx contractapi.TransactionContextInterface, objectType, dppID, after string, limit int, fn func(unixNano int64, txID string, data []byte) error) error {
+	startKey := ""
+	if after != "" {
+		// "." folgt auf "-": der Startschlüssel liegt hinter allen Datensätzen der Transaktion after.
+		key, err := ctx.GetStub().CreateCompositeKey(objectType, []string{dppID, after + "."})
+		if err != nil {
+			return err
+		}
+		startKey = key
+	}
+	lastTx, count := "", 0
+	return scanCompositeKeys(ctx, objectType, []string{dppID}, startKey, func(key string, value []byte) (bool, error) {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(key)
+		if err != nil || len(attrs) != 2 {
+			return false, fmt.Errorf("ungültiger Schlüssel %s: %v", key, err)
+		}
+		unixNano, txID, ok := parseRecordSeq(attrs[1])
+		if !ok {
+			return true, nil // migrierte Alt-Einträge stecken in der Kopf-Historie
+		}
+		if txID != lastTx {
+			lastTx = txID
+			count++
+		}
+		if count > limit {
+			return false, fn(unixNano, txID, nil)
+		}
+		return true, fn(unixNano, txID, value)
+	})
+}
+
+// diffDPPHeaders vergleicht zwei Kopf-Versionen feldweise (JSON-Ebene, sortiert nach Feldname).
+// Quality, EPCISEvents und TransportLog sind nicht Teil des Diffs, sie erscheinen als hinzugefügte Einträge.
+func diffDPPHeaders(previous, current *DPP) []FieldChange {
+	oldFields := headerFields(previous)
+	newFields := headerFields(current)
+
+	names := make([]string, 0, len(oldFields)+len(newFields))
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if name == "quality" || name == "epcisEvents" || name == "transportLog" {
+			continue
+		}
+		if !reflect.DeepEqual(oldFields[name], newFields[name]) {
+			changes = append(changes, FieldChange{Field: name, OldValue: oldFields[name], NewValue: newFields[name]})
+		}
+	}
+	return changes
+}
+
+func headerFields(dpp *DPP) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if dpp == nil {
+		return fields
+	}
+	data, err := json.Marshal(dpp)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// parseRecordSeq zerlegt eine Sequenz "<unixNano, 20-stellig>-<txID>-<nnnn>".
+// Für migrierte Alt-Einträge ("…-legacy-…") ist ok false.
+func parseRecordSeq(seq string) (unixNano int64, txID string, ok bool) {
+	if len(seq) < 27 || seq[20] != '-' || seq[len(seq)-5] != '-' {
+		return 0, "", false
+	}
+	unixNano, err := strconv.ParseInt(seq[:20], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	txID = seq[21 : len(seq)-5]
+	if txID == "legacy" || strings.TrimSpace(txID) == "" {
+		return 0, "", false
+	}
+	return unixNano, txID, true
+}
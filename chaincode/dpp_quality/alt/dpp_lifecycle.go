@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Lebenszyklus (Statusmaschine) --------------------------- //
+
+// DPPStatus ist der typisierte Lebenszyklus-Status eines DPP. Empfänger und
+// verbrauchender DPP stehen in eigenen Feldern (IntendedRecipientMSP, ConsumedByDPPID).
+type DPPStatus string
+
+const (
+	StatusDraft                    DPPStatus = "Draft"
+	StatusAwaitingMandatoryChecks  DPPStatus = "AwaitingMandatoryChecks"
+	StatusAwaitingConcession       DPPStatus = "AwaitingConcession" // Abweichungen ohne genehmigte Sonderfreigabe
+	StatusReleased                 DPPStatus = "Released"
+	StatusReleasedWithDeviations   DPPStatus = "ReleasedWithDeviations"
+	StatusBlocked                  DPPStatus = "Blocked"
+	StatusInTransit                DPPStatus = "InTransit"
+	StatusAcceptedAtRecipient      DPPStatus = "AcceptedAtRecipient"
+	StatusConsumedInTransformation DPPStatus = "ConsumedInTransformation"
+	StatusReturnInTransit          DPPStatus = "ReturnInTransit" // vom Empfänger abgelehnt, unterwegs zurück zum Versender
+	StatusReturned                 DPPStatus = "Returned"        // Rücksendung vom Versender bestätigt, danach gesperrt
+	StatusSplit                    DPPStatus = "Split"           // in Teil-DPPs aufgeteilt (SplitDPP)
+)
+
+// StatusChange protokolliert einen Statusübergang: wer, wann, warum.
+type StatusChange struct {
+	From         DPPStatus `json:"from,omitempty" metadata:",optional"`
+	To           DPPStatus `json:"to"`
+	ChangedByMSP string    `json:"changedByMsp"`
+	ChangedByID  string    `json:"changedById"`
+	Reason       string    `json:"reason"`
+	Timestamp    string    `json:"timestamp"`
+	TxID         string    `json:"txId"`
+}
+
+// allowedTransitions: Einzige Quelle für zulässige Statusübergänge.
+// Jede Statusänderung im Contract läuft über transitionTo und damit über diese Tabelle.
+var allowedTransitions = map[DPPStatus][]DPPStatus{
+	"":                             {StatusDraft},
+	StatusDraft:                    {StatusAwaitingMandatoryChecks, StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations, StatusBlocked},
+	StatusAwaitingMandatoryChecks:  {StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations, StatusBlocked},
+	StatusAwaitingConcession:       {StatusAwaitingMandatoryChecks, StatusReleased, StatusReleasedWithDeviations, StatusBlocked},
+	StatusReleased:                 {StatusAwaitingConcession, StatusReleasedWithDeviations, StatusBlocked, StatusInTransit, StatusConsumedInTransformation, StatusSplit},
+	StatusReleasedWithDeviations:   {StatusAwaitingConcession, StatusReleased, StatusBlocked, StatusInTransit, StatusConsumedInTransformation, StatusSplit},
+	StatusBlocked:                  {StatusAwaitingMandatoryChecks, StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations}, // nur über ReleaseBlockedDPP
+	StatusInTransit:                {StatusAcceptedAtRecipient, StatusReturnInTransit},
+	StatusAcceptedAtRecipient:      {StatusConsumedInTransformation, StatusSplit},
+	StatusConsumedInTransformation: {},
+	StatusReturnInTransit:          {StatusReturned},
+	StatusReturned:                 {StatusBlocked},
+	StatusSplit:                    {},
+}
+
+// legacyStatusPrefixes: Status älterer Chaincode-Versionen mit Zusatz im Statustext.
+const (
+	legacyAwaitingPrefix  = "AwaitingMandatoryChecks (" // "AwaitingMandatoryChecks (<n> open)"
+	legacyInTransitPrefix = "InTransitTo_"              // "InTransitTo_<Empfänger-MSP>"
+	legacyConsumedPrefix  = "ConsumedInTransformation_" // "ConsumedInTransformation_<Output-DPP>"
+)
+
+// migrateLegacyStatus bildet Status älterer Versionen auf den typisierten Status ab; Empfänger
+// und verbrauchender DPP wandern in IntendedRecipientMSP bzw. ConsumedByDPPID. Wird beim Lesen
+// aufgerufen und beim nächsten Schreiben gespeichert, ohne Eintrag in der StatusHistory.
+func (dpp *DPP) migrateLegacyStatus() {
+	status := string(dpp.Status)
+	switch {
+	case strings.HasPrefix(status, legacyAwaitingPrefix):
+		dpp.Status = StatusAwaitingMandatoryChecks
+	case strings.HasPrefix(status, legacyInTransitPrefix):
+		dpp.Status = StatusInTransit
+		if dpp.IntendedRecipientMSP == "" {
+			dpp.IntendedRecipientMSP = strings.TrimPrefix(status, legacyInTransitPrefix)
+		}
+	case strings.HasPrefix(status, legacyConsumedPrefix):
+		dpp.Status = StatusConsumedInTransformation
+		if dpp.ConsumedByDPPID == "" {
+			dpp.ConsumedByDPPID = strings.TrimPrefix(status, legacyConsumedPrefix)
+		}
+	default:
+		return
+	}
+	fmt.Printf("[Lifecycle-INFO] DPP %s: Status '%s' älterer Version als %s übernommen.\n", dpp.DppID, status, dpp.Status)
+}
+
+func canTransition(from, to DPPStatus) bool {
+	for _, s := range allowedTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isQualityPhase: In diesen Status wird der Status aus den Qualitätsdaten abgeleitet.
+func (s DPPStatus) isQualityPhase() bool {
+	switch s {
+	case StatusDraft, StatusAwaitingMandatoryChecks, StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations:
+		return true
+	}
+	return false
+}
+
+// isTerminal: Abgeschlossen (aufgeteilt oder verbraucht), keine Übergänge und keine
+// Qualitätsdaten mehr; Prüfungen gehören an die Folge-DPPs.
+func (s DPPStatus) isTerminal() bool {
+	return s != "" && len(allowedTransitions[s]) == 0
+}
+
+// isInTransit: Unterwegs, als Lieferung oder als Rücksendung.
+func (s DPPStatus) isInTransit() bool {
+	return s == StatusInTransit || s == StatusReturnInTransit
+}
+
+// isReleased: Freigegeben (mit oder ohne Abweichungen).
+func (s DPPStatus) isReleased() bool {
+	return s == StatusReleased || s == StatusReleasedWithDeviations
+}
+
+// transitionTo führt einen Statusübergang gemäß allowedTransitions aus und protokolliert ihn.
+// Ein Übergang in den aktuellen Status ist ein No-Op.
+func (dpp *DPP) transitionTo(ctx contractapi.TransactionContextInterface, clk *txClock, to DPPStatus, reason string) error {
+	if dpp.Status == to {
+		return nil
+	}
+	if !canTransition(dpp.Status, to) {
+		allowed := make([]string, 0, len(allowedTransitions[dpp.Status]))
+		for _, s := range allowedTransitions[dpp.Status] {
+			allowed = append(allowed, string(s))
+		}
+		return fmt.Errorf("unzulässiger Statusübergang für DPP %s: %s -> %s (erlaubt: [%s])", dpp.DppID, dpp.Status, to, strings.Join(allowed, ", "))
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID für Statusübergang: %v", err)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client ID für Statusübergang: %v", err)
+	}
+
+	dpp.StatusHistory = append(dpp.StatusHistory, StatusChange{
+		From:         dpp.Status,
+		To:           to,
+		ChangedByMSP: mspID,
+		ChangedByID:  clientID,
+		Reason:       reason,
+		Timestamp:    clk.timestamp(),
+		TxID:         clk.txID,
+	})
+	fmt.Printf("[Lifecycle-INFO] DPP %s: %s -> %s (%s)\n", dpp.DppID, dpp.Status, to, reason)
+	dpp.Status = to
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// putLegacyDPP legt einen DPP-Kopf ab, wie ihn ältere Chaincode-Versionen geschrieben haben.
+func putLegacyDPP(t *testing.T, e *testEnv, dpp DPP) {
+	t.Helper()
+	data, err := json.Marshal(dpp)
+	e.must(err)
+	e.must(e.ctx("Org1MSP").GetStub().PutState(dppPrefix+dpp.DppID, data))
+}
+
+func TestLegacyStatusMigration(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	mfi := testSpec(t, `{"testName":"MFI","isNumeric":true,"lowerLimit":10,"upperLimit":20,"unit":"g/10min","isMandatory":true}`)
+	putLegacyDPP(t, e, DPP{DppID: "L1", GS1Key: "urn:epc:id:sgtin:4012345.011111.6001", OwnerOrg: "Org1MSP",
+		Status: "AwaitingMandatoryChecks (1 open)", Specifications: []QualitySpecification{*mfi}, OpenMandatoryChecks: []string{"MFI"}})
+	putLegacyDPP(t, e, DPP{DppID: "L2", GS1Key: "urn:epc:id:sgtin:4012345.011111.6002", OwnerOrg: "Org2MSP", Status: "InTransitTo_Org2MSP"})
+	putLegacyDPP(t, e, DPP{DppID: "L3", GS1Key: "urn:epc:id:sgtin:4012345.011111.6003", OwnerOrg: "Org1MSP", Status: "ConsumedInTransformation_C9"})
+
+	for id, want := range map[string]DPP{
+		"L1": {Status: StatusAwaitingMandatoryChecks},
+		"L2": {Status: StatusInTransit, IntendedRecipientMSP: "Org2MSP"},
+		"L3": {Status: StatusConsumedInTransformation, ConsumedByDPPID: "C9"},
+	} {
+		dpp, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+		e.must(err)
+		if dpp.Status != want.Status || dpp.IntendedRecipientMSP != want.IntendedRecipientMSP || dpp.ConsumedByDPPID != want.ConsumedByDPPID {
+			t.Errorf("%s: Status %s, Empfänger %q, verbraucht durch %q; erwartet %s, %q, %q", id, dpp.Status, dpp.IntendedRecipientMSP, dpp.ConsumedByDPPID, want.Status, want.IntendedRecipientMSP, want.ConsumedByDPPID)
+		}
+	}
+
+	// Aus den übernommenen Status sind wieder Übergänge möglich. Den Qualitätsstatus von L1
+	// leitet QueryDPP aus den Prüfständen ab; in die StatusHistory kommt er erst mit der
+	// nächsten Transaktion, die den Kopf schreibt.
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "L1", qualityJSON("MFI", "15", "g/10min"), ""))
+	e.must(c.AcknowledgeReceiptAndRecordInspection(e.ctx("Org2MSP"), "L2", "4098765000010", ""))
+	for id, want := range map[string]DPPStatus{"L1": StatusReleased, "L2": StatusAcceptedAtRecipient} {
+		dpp, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+		e.must(err)
+		if dpp.Status != want {
+			t.Errorf("%s: Status %s, erwartet %s", id, dpp.Status, want)
+		}
+		if id == "L1" {
+			continue
+		}
+		if last := dpp.StatusHistory[len(dpp.StatusHistory)-1]; last.To != want || last.From == "" {
+			t.Errorf("%s: letzter Übergang %s -> %s", id, last.From, last.To)
+		}
+	}
+	l3, err := c.QueryDPP(e.ctx("Org1MSP"), "L3")
+	e.must(err)
+	if out := l3.outputDPPIDs(); len(out) != 1 || out[0] != "C9" {
+		t.Errorf("Folgeprodukte von L3 %v, erwartet [C9]", out)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"dpp_transfer_chaincode/offchain"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Off-Chain-Daten verifizieren --------------------------- //
+//
+// Oracles verankern zu einem Qualitätseintrag den Hash der zugehörigen Datei (Sensor-Log,
+// Prüfprotokoll) in offChainDataHash, Transport-Logger zu einem Transport-Eintrag den Hash
+// der Log-Datei in offChainLogHash. Wer die Datei besitzt, berechnet den Hash lokal mit
+// dem Paket offchain bzw. cmd/offchainhash und lässt ihn mit VerifyOffChainData prüfen.
+
+// OffChainAnchor: Ein auf dem Ledger verankerter Hash einer Off-Chain-Datei.
+type OffChainAnchor struct {
+	Source        string `json:"source"` // "qualityEntry" oder "transportLog"
+	EntryID       string `json:"entryId"`
+	TestName      string `json:"testName,omitempty"      metadata:",optional"` // testName bzw. logType
+	ReferenceID   string `json:"referenceId,omitempty"   metadata:",optional"` // offChainDataRef bzw. offChainLogRef des Eintrags
+	AnchoredHash  string `json:"anchoredHash"`
+	HashAlgorithm string `json:"hashAlgorithm"`
+	AnchoredAt    string `json:"anchoredAt"`
+	AnchoredTxID  string `json:"anchoredTxId,omitempty"  metadata:",optional"`
+	HashMatches   bool   `json:"hashMatches"`
+}
+
+// OffChainVerificationReport: Ergebnis von VerifyOffChainData. Anchors enthält alle
+// Verankerungen zur Referenz (z.B. mehrere Versionen einer Datei), MatchingAnchor die
+// erste, deren Hash übereinstimmt.
+type OffChainVerificationReport struct {
+	DppID          string           `json:"dppId"`
+	ReferenceID    string           `json:"referenceId"`
+	ProvidedHash   string           `json:"providedHash"`
+	HashAlgorithm  string           `json:"hashAlgorithm"`
+	Verified       bool             `json:"verified"`
+	MatchingAnchor *OffChainAnchor  `json:"matchingAnchor,omitempty" metadata:",optional"`
+	Anchors        []OffChainAnchor `json:"anchors"`
+	Message        string           `json:"message"`
+}
+
+// anchorOffChainHash prüft einen mitgelieferten Datei-Hash und normalisiert ihn. Ohne Hash
+// bleibt der Eintrag unverändert.
+func anchorOffChainHash(qe *QualityEntry) error {
+	hash, algorithm, err := normalizeAnchoredHash(qe.OffChainDataHash, qe.HashAlgorithm, "offChainDataHash")
+	if err != nil {
+		return fmt.Errorf("Test '%s': %v", qe.TestName, err)
+	}
+	qe.OffChainDataHash, qe.HashAlgorithm = hash, algorithm
+	return nil
+}
+
+// anchorTransportLogHash: wie anchorOffChainHash für den Hash der Log-Datei eines Transport-Eintrags.
+func anchorTransportLogHash(entry *TransportConditionLogEntry) error {
+	hash, algorithm, err := normalizeAnchoredHash(entry.OffChainLogHash, entry.HashAlgorithm, "offChainLogHash")
+	if err != nil {
+		return fmt.Errorf("Transport-Eintrag '%s': %v", entry.LogType, err)
+	}
+	entry.OffChainLogHash, entry.HashAlgorithm = hash, algorithm
+	return nil
+}
+
+// normalizeAnchoredHash prüft Hash und Algorithmus; field ist der JSON-Name des Hash-Felds
+// für Fehlermeldungen.
+func normalizeAnchoredHash(hash, algorithm, field string) (string, string, error) {
+	if hash == "" {
+		if algorithm != "" {
+			return "", "", fmt.Errorf("hashAlgorithm '%s' angegeben, aber kein %s", algorithm, field)
+		}
+		return "", "", nil
+	}
+	if algorithm == "" {
+		algorithm = offchain.Algorithm
+	}
+	if algorithm != offchain.Algorithm {
+		return "", "", fmt.Errorf("Hash-Algorithmus '%s' wird nicht unterstützt, erlaubt ist %s", algorithm, offchain.Algorithm)
+	}
+	normalized, err := offchain.NormalizeHash(hash)
+	if err != nil {
+		return "", "", fmt.Errorf("%s ungültig: %v", field, err)
+	}
+	return normalized, algorithm, nil
+}
+
+// VerifyOffChainData: Prüft den lokal berechneten Hash einer Datei gegen die auf DPP dppID
+// verankerten Hashes. referenceID ist die offChainDataRef oder die entryId des Eintrags.
+func (c *DPPQualityContract) VerifyOffChainData(ctx contractapi.TransactionContextInterface, dppID, referenceID, sha256 string) (*OffChainVerificationReport, error) {
+	fmt.Printf("[VerifyOffChainData-DEBUG] Entry: dppID=%s, referenceID=%s\n", dppID, referenceID)
+	if referenceID == "" {
+		return nil, fmt.Errorf("referenceID darf nicht leer sein")
+	}
+	provided, err := offchain.NormalizeHash(sha256)
+	if err != nil {
+		return nil, err
+	}
+	dpp, err := c.readDPP(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OffChainVerificationReport{
+		DppID:         dppID,
+		ReferenceID:   referenceID,
+		ProvidedHash:  provided,
+		HashAlgorithm: offchain.Algorithm,
+		Anchors:       []OffChainAnchor{},
+	}
+	for _, anchor := range dpp.offChainAnchors() {
+		if anchor.ReferenceID != referenceID && anchor.EntryID != referenceID {
+			continue
+		}
+		anchor.HashMatches = anchor.AnchoredHash == provided
+		report.Anchors = append(report.Anchors, anchor)
+		if anchor.HashMatches && report.MatchingAnchor == nil {
+			matched := anchor
+			report.MatchingAnchor = &matched
+		}
+	}
+
+	switch {
+	case len(report.Anchors) == 0:
+		report.Message = fmt.Sprintf("Für Referenz '%s' ist auf DPP %s kein Hash verankert", referenceID, dppID)
+	case report.MatchingAnchor != nil:
+		report.Verified = true
+		report.Message = fmt.Sprintf("Hash stimmt mit Eintrag %s überein (verankert am %s)", report.MatchingAnchor.EntryID, report.MatchingAnchor.AnchoredAt)
+	default:
+		report.Message = fmt.Sprintf("Hash stimmt mit keiner der %d Verankerungen zu '%s' überein, die Datei wurde verändert", len(report.Anchors), referenceID)
+	}
+	fmt.Printf("[VerifyOffChainData-INFO] DPP %s, Referenz %s: verified=%t (%d Verankerungen)\n", dppID, referenceID, report.Verified, len(report.Anchors))
+	return report, nil
+}
+
+// offChainAnchors: Alle verankerten Datei-Hashes des DPP, Qualitätseinträge und
+// Transport-Logs jeweils in chronologischer Reihenfolge.
+func (dpp *DPP) offChainAnchors() []OffChainAnchor {
+	var anchors []OffChainAnchor
+	for _, qe := range append(dpp.InheritedQuality, dpp.Quality...) {
+		if qe.OffChainDataHash == "" {
+			continue
+		}
+		anchors = append(anchors, OffChainAnchor{
+			Source:        "qualityEntry",
+			EntryID:       qe.EntryID,
+			TestName:      qe.TestName,
+			ReferenceID:   qe.OffChainDataRef,
+			AnchoredHash:  qe.OffChainDataHash,
+			HashAlgorithm: qe.HashAlgorithm,
+			AnchoredAt:    anchoredAt(qe.EntryID, qe.Timestamp),
+			AnchoredTxID:  qe.RecordedTxID,
+		})
+	}
+	for _, entry := range dpp.TransportLog {
+		if entry.OffChainLogHash == "" {
+			continue
+		}
+		anchors = append(anchors, OffChainAnchor{
+			Source:        "transportLog",
+			EntryID:       entry.EntryID,
+			TestName:      entry.LogType,
+			ReferenceID:   entry.OffChainLogRef,
+			AnchoredHash:  entry.OffChainLogHash,
+			HashAlgorithm: entry.HashAlgorithm,
+			AnchoredAt:    anchoredAt(entry.EntryID, entry.Timestamp),
+			AnchoredTxID:  entry.RecordedTxID,
+		})
+	}
+	return anchors
+}
+
+// anchoredAt: Commit-Zeit der Transaktion aus der Sequenz; für migrierte Einträge der
+// Zeitstempel des Eintrags.
+func anchoredAt(seq, fallback string) string {
+	if unixNano, _, ok := parseRecordSeq(seq); ok {
+		return time.Unix(0, unixNano).UTC().Format(time.RFC3339)
+	}
+	return fallback
+}
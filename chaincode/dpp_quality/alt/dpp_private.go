@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Vertrauliche Prüfergebnisse (Private Data) --------------------------- //
+//
+// RecordQualityData mit leerem qualityEntryJSON liest den Eintrag aus der Transient Map:
+//
+//	"qualityEntry" – QualityEntry als JSON (Pflicht)
+//	"salt"         – zufälliger Salt des Clients, mind. 16 Zeichen (Pflicht)
+//	"customerMSP"  – MSP des direkten Kunden (Pflicht); muss der mit SetIntendedCustomer
+//	                 festgelegte Kunde bzw. der Empfänger eines laufenden Transfers sein
+//
+// Der vollständige Eintrag wird in der Collection des Paars Eigentümer/Kunde gespeichert
+// (siehe collections_config.json). Im öffentlichen DPP bleiben nur Testname, Bewertung,
+// Off-Chain-Referenz und -Hash sowie der gesalzene Hash sha256(salt || JSON des privaten
+// Eintrags). Der Salt muss vom Client kommen, da zufällige Werte im Chaincode auf den Peers
+// unterschiedlich wären.
+
+const (
+	privateQualityObjectType = "DPP~privateQuality"
+	minSaltLength            = 16
+)
+
+// PrivateQualityRecord: Inhalt der Private Data Collection je Eintrag.
+type PrivateQualityRecord struct {
+	DppID      string       `json:"dppId"`
+	EntryID    string       `json:"entryId"`
+	Collection string       `json:"collection"`
+	Salt       string       `json:"salt"`
+	Entry      QualityEntry `json:"entry"`
+}
+
+// PrivateQualityEntryResult: Ergebnis von ReadPrivateQualityEntry inkl. Abgleich mit dem
+// öffentlich gespeicherten Hash.
+type PrivateQualityEntryResult struct {
+	Record      PrivateQualityRecord `json:"record"`
+	PublicHash  string               `json:"publicHash"`
+	HashMatches bool                 `json:"hashMatches"`
+}
+
+// privateSubmission: Aus der Transient Map gelesener Eintrag.
+type privateSubmission struct {
+	entry       QualityEntry
+	salt        string
+	customerMSP string
+}
+
+// pairCollection: Name der Collection für zwei Organisationen, unabhängig von der Reihenfolge.
+func pairCollection(mspA, mspB string) string {
+	pair := []string{mspA, mspB}
+	sort.Strings(pair)
+	return "pdc_" + pair[0] + "_" + pair[1]
+}
+
+func saltedHash(salt string, qe QualityEntry) (string, error) {
+	data, err := json.Marshal(qe)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(salt), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// expectedCustomer: Kunde, mit dem der Eigentümer vertrauliche Einträge teilen darf.
+// Der festgelegte Kunde hat Vorrang vor dem Empfänger eines laufenden Transfers.
+func (dpp *DPP) expectedCustomer() string {
+	if dpp.IntendedCustomerMSP != "" {
+		return dpp.IntendedCustomerMSP
+	}
+	return dpp.IntendedRecipientMSP
+}
+
+func readPrivateSubmission(ctx contractapi.TransactionContextInterface, dpp *DPP, ownerMSP string) (*privateSubmission, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen der Transient Map: %v", err)
+	}
+	entryJSON, ok := transient["qualityEntry"]
+	if !ok {
+		return nil, fmt.Errorf("qualityEntryJSON ist leer und die Transient Map enthält keinen Schlüssel 'qualityEntry'")
+	}
+	sub := &privateSubmission{salt: string(transient["salt"]), customerMSP: string(transient["customerMSP"])}
+	if err := json.Unmarshal(entryJSON, &sub.entry); err != nil {
+		return nil, fmt.Errorf("QualityEntry JSON (Transient) fehlerhaft: %v", err)
+	}
+	if len(sub.salt) < minSaltLength {
+		return nil, fmt.Errorf("Transient 'salt' fehlt oder ist kürzer als %d Zeichen", minSaltLength)
+	}
+	if sub.customerMSP == "" {
+		return nil, fmt.Errorf("Transient 'customerMSP' fehlt")
+	}
+	if sub.customerMSP == ownerMSP {
+		return nil, fmt.Errorf("customerMSP %s ist identisch mit dem Eigentümer", sub.customerMSP)
+	}
+	// Sonst könnte der Eigentümer den Eintrag mit einer beliebigen Organisation teilen.
+	expected := dpp.expectedCustomer()
+	if expected == "" {
+		return nil, fmt.Errorf("für DPP %s ist kein Kunde festgelegt (SetIntendedCustomer), vertrauliche Einträge sind nicht möglich", dpp.DppID)
+	}
+	if sub.customerMSP != expected {
+		return nil, fmt.Errorf("customerMSP %s ist nicht der Kunde %s von DPP %s", sub.customerMSP, expected, dpp.DppID)
+	}
+	return sub, nil
+}
+
+// recordPrivateQualityEntry bewertet den vollständigen Eintrag, legt ihn in der Collection ab
+// und merkt im DPP nur die öffentliche Fassung vor. qe enthält danach die öffentliche Fassung.
+func (c *DPPQualityContract) recordPrivateQualityEntry(ctx contractapi.TransactionContextInterface, clk *txClock, dpp *DPP, sub *privateSubmission, qe *QualityEntry) error {
+	full := *qe
+	full.EntryID = clk.nextRecordSeq()
+	full.RecordedTxID = clk.txID
+	spec := dpp.evaluateQualityEntry(&full)
+
+	collection := pairCollection(dpp.OwnerOrg, sub.customerMSP)
+	hash, err := saltedHash(sub.salt, full)
+	if err != nil {
+		return fmt.Errorf("Fehler beim Hashen des privaten Eintrags: %v", err)
+	}
+	record := PrivateQualityRecord{DppID: dpp.DppID, EntryID: full.EntryID, Collection: collection, Salt: sub.salt, Entry: full}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Fehler beim Marshalling des privaten Eintrags: %v", err)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(privateQualityObjectType, []string{dpp.DppID, full.EntryID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, data); err != nil {
+		return fmt.Errorf("PutPrivateData in %s fehlgeschlagen: %v", collection, err)
+	}
+
+	public := QualityEntry{
+		EntryID:           full.EntryID,
+		TestName:          full.TestName,
+		EvaluationOutcome: full.EvaluationOutcome,
+		OffChainDataRef:   full.OffChainDataRef,  // Referenz und Hash verraten nichts über den Inhalt,
+		OffChainDataHash:  full.OffChainDataHash, // VerifyOffChainData findet die Verankerung darüber
+		HashAlgorithm:     full.HashAlgorithm,
+		Supersedes:        full.Supersedes,
+		RetestReason:      full.RetestReason,
+		PrivateDataHash:   hash,
+		PrivateCollection: collection,
+	}
+	dpp.queueQualityEntry(clk, &public)
+	if err := dpp.recordEvaluatedEntry(ctx, &public, spec); err != nil {
+		return err
+	}
+	*qe = public
+	fmt.Printf("[RecordQualityData-INFO] Vertraulicher Eintrag %s für Test '%s' in %s gespeichert.\n", full.EntryID, full.TestName, collection)
+	return nil
+}
+
+// ReadPrivateQualityEntry: Liefert einen vertraulichen Eintrag an Mitglieder der Collection
+// und prüft ihn gegen den öffentlichen Hash im DPP.
+func (c *DPPQualityContract) ReadPrivateQualityEntry(ctx contractapi.TransactionContextInterface, dppID, entryID string) (*PrivateQualityEntryResult, error) {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	public, err := readQualityEntry(ctx, dppID, entryID)
+	if err != nil {
+		return nil, err
+	}
+	if public.PrivateCollection == "" {
+		return nil, fmt.Errorf("Qualitätseintrag %s von DPP %s ist nicht vertraulich, bitte QueryDPP verwenden", entryID, dppID)
+	}
+	if !collectionHasMember(public.PrivateCollection, clientMSPID) {
+		return nil, fmt.Errorf("Zugriff verweigert: %s ist nicht Mitglied von %s", clientMSPID, public.PrivateCollection)
+	}
+
+	privateKey, err := ctx.GetStub().CreateCompositeKey(privateQualityObjectType, []string{dppID, entryID})
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctx.GetStub().GetPrivateData(public.PrivateCollection, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("GetPrivateData aus %s fehlgeschlagen: %v", public.PrivateCollection, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("privater Eintrag %s nicht in %s auf diesem Peer vorhanden", entryID, public.PrivateCollection)
+	}
+	var record PrivateQualityRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling des privaten Eintrags %s: %v", entryID, err)
+	}
+	hash, err := saltedHash(record.Salt, record.Entry)
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateQualityEntryResult{Record: record, PublicHash: public.PrivateDataHash, HashMatches: hash == public.PrivateDataHash}, nil
+}
+
+// collectionHasMember: Mitgliedschaft anhand des Namensschemas pdc_<MSP>_<MSP>.
+func collectionHasMember(collection, mspID string) bool {
+	return strings.HasPrefix(collection, "pdc_"+mspID+"_") || strings.HasSuffix(collection, "_"+mspID)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrivateQualityEntry(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-P", retestSpecs, "", "")
+	e.must(err)
+	_, err = c.CreateDPP(e.ctx("Org1MSP"), "P1", "urn:epc:id:sgtin:4012345.011111.7001", "PT-P", "4012345000016", "L1", "2025-06-01", 1000, "kg", 0)
+	e.must(err)
+
+	fileHash := strings.Repeat("ab", 32)
+	submit := func(customerMSP string) error {
+		e.stub.TransientMap = map[string][]byte{
+			"qualityEntry": []byte(`{"testName":"MFI","result":"15","unit":"g/10min","offChainDataRef":"lab/mfi-P1.csv","offChainDataHash":"` + fileHash + `"}`),
+			"salt":         []byte("0123456789abcdef"),
+			"customerMSP":  []byte(customerMSP),
+		}
+		defer func() { e.stub.TransientMap = nil }()
+		return c.RecordQualityData(e.ctx("Org1MSP"), "P1", "", "")
+	}
+
+	if err := submit("Org2MSP"); err == nil || !strings.Contains(err.Error(), "kein Kunde festgelegt") {
+		t.Errorf("ohne festgelegten Kunden: Fehler %v", err)
+	}
+	e.must(c.SetIntendedCustomer(e.ctx("Org1MSP"), "P1", "Org2MSP"))
+	if err := submit("Org3MSP"); err == nil || !strings.Contains(err.Error(), "nicht der Kunde Org2MSP") {
+		t.Errorf("fremder Kunde: Fehler %v", err)
+	}
+	e.must(submit("Org2MSP"))
+
+	dpp, err := c.QueryDPP(e.ctx("Org1MSP"), "P1")
+	e.must(err)
+	public := latestEntry(t, dpp, "MFI")
+	if public.PrivateCollection != "pdc_Org1MSP_Org2MSP" || public.Result != "" || public.OffChainDataRef != "lab/mfi-P1.csv" {
+		t.Errorf("öffentlicher Eintrag %+v", public)
+	}
+	report, err := c.VerifyOffChainData(e.ctx("Org3MSP"), "P1", "lab/mfi-P1.csv", fileHash)
+	e.must(err)
+	if !report.Verified {
+		t.Errorf("Off-Chain-Datei nicht über die Referenz gefunden: %s", report.Message)
+	}
+
+	private, err := c.ReadPrivateQualityEntry(e.ctx("Org2MSP"), "P1", public.EntryID)
+	e.must(err)
+	if !private.HashMatches || private.Record.Entry.Result != "15" {
+		t.Errorf("privater Eintrag %+v", private)
+	}
+	if _, err := c.ReadPrivateQualityEntry(e.ctx("Org3MSP"), "P1", public.EntryID); err == nil {
+		t.Errorf("Org3MSP darf den privaten Eintrag nicht lesen")
+	}
+}
+
+func TestCollectionsConfigRequiresPeer(t *testing.T) {
+	data, err := os.ReadFile("collections_config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collections []struct {
+		Name              string `json:"name"`
+		RequiredPeerCount int    `json:"requiredPeerCount"`
+		MaxPeerCount      int    `json:"maxPeerCount"`
+	}
+	if err := json.Unmarshal(data, &collections); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range collections {
+		// Mit 0 kann der Endorser die Transaktion bestätigen, ohne dass ein weiterer Peer die
+		// privaten Daten hat; fällt er aus, sind sie verloren.
+		if col.RequiredPeerCount < 1 || col.MaxPeerCount < col.RequiredPeerCount {
+			t.Errorf("%s: requiredPeerCount %d, maxPeerCount %d", col.Name, col.RequiredPeerCount, col.MaxPeerCount)
+		}
+	}
+}
@@ -14,27 +14,40 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"dpp_transfer_chaincode/gs1"
+
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 // --------------------------- Datenstrukturen (Erweitert) --------------------------- //
 
+// QualitySpecification: Numerische Grenzen sind einzeln optional (nur unten, nur oben,
+// beidseitig oder Nennwert ± Toleranz). Ob eine Grenze gesetzt ist, wird beim JSON-Decoding
+// erkannt (siehe UnmarshalJSON in dpp_evaluation.go), 0 ist damit ein gültiger Grenzwert.
 type QualitySpecification struct {
-	TestName      string  `json:"testName"`                                         // Eindeutiger Name des Tests
-	IsNumeric     bool    `json:"isNumeric"`                                        // True, wenn das Ergebnis eine Zahl ist
-	LowerLimit    float64 `json:"lowerLimit,omitempty"    metadata:",optional"`     // Untere Toleranzgrenze
-	UpperLimit    float64 `json:"upperLimit,omitempty"    metadata:",optional"`     // Obere Toleranzgrenze
-	ExpectedValue string  `json:"expectedValue,omitempty" metadata:",optional"`     // Erwarteter String-Wert
-	Unit          string  `json:"unit,omitempty"          metadata:",optional"`     // Erwartete Einheit
-	IsMandatory   bool    `json:"isMandatory"`                                      // Zwingend für Freigabe?
+	TestName            string  `json:"testName"`                                         // Eindeutiger Name des Tests
+	IsNumeric           bool    `json:"isNumeric"`                                        // True, wenn das Ergebnis eine Zahl ist
+	LowerLimit          float64 `json:"lowerLimit,omitempty"          metadata:",optional"` // Untere Toleranzgrenze
+	UpperLimit          float64 `json:"upperLimit,omitempty"          metadata:",optional"` // Obere Toleranzgrenze
+	LowerLimitExclusive bool    `json:"lowerLimitExclusive,omitempty" metadata:",optional"` // Wert muss echt größer als LowerLimit sein
+	UpperLimitExclusive bool    `json:"upperLimitExclusive,omitempty" metadata:",optional"` // Wert muss echt kleiner als UpperLimit sein
+	NominalValue        float64 `json:"nominalValue,omitempty"        metadata:",optional"` // Nennwert (nur mit Tolerance)
+	Tolerance           float64 `json:"tolerance,omitempty"           metadata:",optional"` // Zulässige Abweichung ± vom Nennwert
+	ExpectedValue       string  `json:"expectedValue,omitempty"       metadata:",optional"` // Erwarteter String-Wert
+	Unit                string  `json:"unit,omitempty"                metadata:",optional"` // Erwartete Einheit
+	IsMandatory         bool    `json:"isMandatory"`                                      // Zwingend für Freigabe?
+
+	Statistics *StatisticalCriteria `json:"statistics,omitempty" metadata:",optional"` // Kriterien für Messreihen (readings)
+
+	hasLower, hasUpper, hasNominal, hasTolerance bool
 }
 
 type QualityEntry struct {
+	EntryID           string `json:"entryId,omitempty"           metadata:",optional"` // Sortierschlüssel des Eintrags (DPP~quality~<dppId>~<entryId>)
+	RecordedTxID      string `json:"recordedTxId,omitempty"      metadata:",optional"`
 	TestName          string `json:"testName"`
 	Result            string `json:"result"`
 	Unit              string `json:"unit"`
@@ -43,8 +56,20 @@ type QualityEntry struct {
 	Responsible       string `json:"responsible"`
 	PerformingOrg     string `json:"performingOrg"`
 	OffChainDataRef   string `json:"offChainDataRef,omitempty"   metadata:",optional"`
-	EvaluationOutcome string `json:"evaluationOutcome,omitempty" metadata:",optional"`
+	OffChainDataHash  string `json:"offChainDataHash,omitempty"  metadata:",optional"` // Hash der Off-Chain-Datei (hex), siehe VerifyOffChainData
+	HashAlgorithm     string `json:"hashAlgorithm,omitempty"     metadata:",optional"`
+	EvaluationOutcome EvaluationOutcome `json:"evaluationOutcome,omitempty" metadata:",optional"`
 	EvaluationComment string `json:"evaluationComment,omitempty" metadata:",optional"`
+	NormalizedResult  string `json:"normalizedResult,omitempty"  metadata:",optional"` // Result umgerechnet in die Einheit der Spezifikation (UCUM)
+	NormalizedUnit    string `json:"normalizedUnit,omitempty"    metadata:",optional"`
+	Readings          []float64 `json:"readings,omitempty"          metadata:",optional"` // Einzelwerte einer Messreihe (Einheit wie Unit)
+	Statistics        *ReadingStatistics `json:"statistics,omitempty"        metadata:",optional"` // im Chaincode berechnet, Einheit der Spezifikation
+	PrivateDataHash   string `json:"privateDataHash,omitempty"   metadata:",optional"` // sha256(salt || JSON) des vertraulichen Eintrags
+	PrivateCollection string `json:"privateCollection,omitempty" metadata:",optional"` // Collection mit dem vollständigen Eintrag
+	Concession        *Concession `json:"concession,omitempty"        metadata:",optional"` // Entscheidung des Kunden zur Abweichung
+	Supersedes        string `json:"supersedes,omitempty"        metadata:",optional"` // EntryID des durch diesen Retest ersetzten Eintrags
+	SupersededBy      string `json:"supersededBy,omitempty"      metadata:",optional"` // EntryID des Retests, der diesen Eintrag ersetzt
+	RetestReason      string `json:"retestReason,omitempty"      metadata:",optional"`
 }
 
 type EPCISEvent struct {
@@ -60,23 +85,59 @@ type EPCISEvent struct {
 	OutputEPCList       []string               `json:"outputEPCList,omitempty"     metadata:",optional"`
 	ReadPoint           string                 `json:"readPoint,omitempty"         metadata:",optional"`
 	BizLocation         string                 `json:"bizLocation,omitempty"       metadata:",optional"`
+	SensorElementList   []SensorElement        `json:"sensorElementList,omitempty" metadata:",optional"` // Messwerte nach EPCIS 2.0 (siehe dpp_sensor.go)
 	Extensions          map[string]interface{} `json:"extensions"` // immer vorhanden
 }
 
 type DPP struct {
-	DppID               string                 `json:"dppId"`
-	GS1Key              string                 `json:"gs1Key"`
-	ProductTypeID       string                 `json:"productTypeId,omitempty"     metadata:",optional"`
-	ManufacturerGLN     string                 `json:"manufacturerGln"`
-	Batch               string                 `json:"batch"`
-	ProductionDate      string                 `json:"productionDate"`
-	OwnerOrg            string                 `json:"ownerOrg"`
-	Status              string                 `json:"status"`
-	Specifications      []QualitySpecification `json:"specifications,omitempty"      metadata:",optional"`
-	OpenMandatoryChecks []string               `json:"openMandatoryChecks,omitempty" metadata:",optional"`
-	Quality             []QualityEntry         `json:"quality"`
-	InputDPPIDs         []string               `json:"inputDppIds,omitempty"         metadata:",optional"`
-	EPCISEvents         []EPCISEvent           `json:"epcisEvents"`
+	DocType                 string                 `json:"docType,omitempty"              metadata:",optional"` // immer "dpp", für CouchDB-Selektoren
+	DppID                   string                 `json:"dppId"`
+	GS1Key                  string                 `json:"gs1Key"`
+	DigitalLink             string                 `json:"digitalLink,omitempty"          metadata:",optional"` // GS1 Digital Link zu gs1Key (für Etiketten)
+	ProductTypeID           string                 `json:"productTypeId,omitempty"        metadata:",optional"`
+	ManufacturerGLN         string                 `json:"manufacturerGln"`
+	Batch                   string                 `json:"batch"`
+	ProductionDate          string                 `json:"productionDate"`
+	OwnerOrg                string                 `json:"ownerOrg"`
+	Status                  DPPStatus              `json:"status"`
+	IntendedRecipientMSP    string                 `json:"intendedRecipientMsp,omitempty" metadata:",optional"` // Nur im Status InTransit
+	ConsumedByDPPID         string                 `json:"consumedByDppId,omitempty"      metadata:",optional"` // Nur im Status ConsumedInTransformation
+	StatusHistory           []StatusChange         `json:"statusHistory,omitempty"        metadata:",optional"`
+	SpecificationVersion    int                    `json:"specificationVersion,omitempty" metadata:",optional"` // Version im Spezifikationskatalog (ProductTypeID)
+	Specifications          []QualitySpecification `json:"specifications,omitempty"       metadata:",optional"`
+	OpenMandatoryChecks     []string               `json:"openMandatoryChecks,omitempty"  metadata:",optional"` // abgeleitet aus den Prüfständen, nicht im Kopf gespeichert
+	FailedTests             []string               `json:"failedTests,omitempty"          metadata:",optional"` // Tests mit kritischem Ergebnis (FAIL, INVALID_FORMAT), abgeleitet
+	DeviationTests          []string               `json:"deviationTests,omitempty"       metadata:",optional"` // Tests mit Abweichung (DEVIATION_LOW/HIGH), abgeleitet
+	CheckKeys               bool                   `json:"checkKeys,omitempty"            metadata:",optional"` // Prüfstände liegen unter DPP~check (siehe dpp_checks.go)
+	InputDPPIDs             []string               `json:"inputDppIds,omitempty"          metadata:",optional"`
+	OutputDPPIDs            []string               `json:"outputDppIds,omitempty"         metadata:",optional"`    // DPPs, die aus diesem DPP entstanden sind
+	TransformationEventID   string                 `json:"transformationEventId,omitempty" metadata:",optional"`   // Event, durch das dieser DPP aus InputDPPIDs entstand
+	SplitSeq                string                 `json:"splitSeq,omitempty"             metadata:",optional"`    // Sortierschlüssel der Aufteilung; spätere Einträge werden nicht vererbt
+	Recalls                 []DPPRecallState       `json:"recalls,omitempty"              metadata:",optional"`    // Rückrufe, die diesen DPP betreffen (offen und geschlossen)
+	TransportSpecifications []QualitySpecification `json:"transportSpecifications,omitempty" metadata:",optional"` // Grenzwerte je logType aus dem Spezifikationssatz
+	TransportAlert          bool                   `json:"transportAlert,omitempty"          metadata:",optional"` // Grenzwert beim Transport überschritten, unabhängig vom Status
+	TransportExcursions     []string               `json:"transportExcursions,omitempty"     metadata:",optional"` // logTypes mit Grenzwertüberschreitung
+	TransportClearances     []TransportClearance   `json:"transportClearances,omitempty"     metadata:",optional"` // aufgehobene Transport-Alerts (ClearTransportAlert)
+	Rejections              []DeliveryRejection    `json:"rejections,omitempty"              metadata:",optional"` // abgelehnte Lieferungen (RejectDelivery)
+	IntendedCustomerMSP     string                 `json:"intendedCustomerMsp,omitempty"     metadata:",optional"` // Kunde, der über Sonderfreigaben entscheidet
+	Concessions             []Concession           `json:"concessions,omitempty"             metadata:",optional"` // Sonderfreigaben je abweichendem Test
+	SplitFromDPPID          string                 `json:"splitFromDppId,omitempty"          metadata:",optional"` // Eltern-DPP, aus dessen Aufteilung dieser DPP entstand
+	Quantity                float64                `json:"quantity,omitempty"                metadata:",optional"` // Menge der (Teil-)Charge
+	QuantityUnit            string                 `json:"quantityUnit,omitempty"            metadata:",optional"` // UCUM-Code der Menge, z.B. "kg"
+	// Quality, EPCISEvents und TransportLog liegen unter eigenen Composite Keys (siehe
+	// dpp_storage.go) und werden nur von QueryDPP zusammengesetzt. InheritedQuality sind die
+	// Einträge der Eltern-DPPs (SplitFromDPPID), auf die ein Teil-DPP verweist.
+	Quality          []QualityEntry               `json:"quality,omitempty"          metadata:",optional"`
+	EPCISEvents      []EPCISEvent                 `json:"epcisEvents,omitempty"      metadata:",optional"`
+	TransportLog     []TransportConditionLogEntry `json:"transportLog,omitempty"     metadata:",optional"`
+	InheritedQuality []QualityEntry               `json:"inheritedQuality,omitempty" metadata:",optional"`
+
+	pending          []pendingRecord       // noch zu schreibende Einträge/Events
+	embedded         bool                  // altes Format: Einträge/Events stehen noch im Kopf-Dokument
+	checks           map[string]*TestCheck // gelesene oder geänderte Prüfstände je Test
+	checksLoaded     bool                  // alle Prüfstände gelesen (loadChecks)
+	storedHeader     []byte                // Kopf-JSON wie gelesen, um unnötige PutState zu vermeiden
+	indexDigitalLink bool                  // Digital-Link-Index noch zu schreiben (siehe dpp_digitallink.go)
 }
 
 // --------------------------- Contract --------------------------- //
@@ -89,15 +150,45 @@ const dppPrefix = "DPP-"
 
 // --------------------------- Utils --------------------------- //
 
-var gs1URNRegexp = regexp.MustCompile(`^urn:epc:id:([a-zA-Z0-9_]+):([a-zA-Z0-9\.\-]+)(\.[\w\.\-]+)*$`)
-
-func validateGS1Key(gs1 string) error {
-	if !gs1URNRegexp.MatchString(gs1) {
-		return fmt.Errorf("ungültiger GS1 EPC URN-Schlüssel: %s. Erwartet Format wie urn:epc:id:sgtin:...", gs1)
+// validateGS1Key prüft den Produktschlüssel eines DPP (sgtin, lgtin, sscc oder grai, siehe Paket gs1).
+func validateGS1Key(gs1Key string) error {
+	epc, err := gs1.ParseEPC(gs1Key)
+	if err != nil {
+		return fmt.Errorf("ungültiger GS1-Schlüssel: %v", err)
+	}
+	if epc.Scheme == gs1.SGLN {
+		return fmt.Errorf("ungültiger GS1-Schlüssel %s: eine SGLN bezeichnet einen Ort, kein Produkt", gs1Key)
 	}
 	return nil
 }
 
+// normalizeGLN prüft einen Ort (13-stellige GLN mit Prüfziffer oder SGLN-URN) und liefert die GLN.
+func normalizeGLN(field, gln string) (string, error) {
+	if !strings.HasPrefix(gln, "urn:") {
+		if err := gs1.ValidateGLN(gln); err != nil {
+			return "", fmt.Errorf("%s ungültig: %v", field, err)
+		}
+		return gln, nil
+	}
+	epc, err := gs1.ParseEPC(gln)
+	if err == nil && epc.Scheme != gs1.SGLN {
+		err = fmt.Errorf("'%s' ist keine SGLN", gln)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s ungültig: %v", field, err)
+	}
+	return epc.GLN(), nil
+}
+
+// validateSiteGLN: Ort eines Events wie normalizeGLN; leer ist erlaubt (Ort nicht angegeben).
+func validateSiteGLN(field, gln string) error {
+	if gln == "" {
+		return nil
+	}
+	_, err := normalizeGLN(field, gln)
+	return err
+}
+
 func (c *DPPQualityContract) dppExists(ctx contractapi.TransactionContextInterface, dppID string) (bool, error) {
 	data, err := ctx.GetStub().GetState(dppPrefix + dppID)
 	if err != nil {
@@ -106,61 +197,109 @@ func (c *DPPQualityContract) dppExists(ctx contractapi.TransactionContextInterfa
 	return data != nil, nil
 }
 
-func tzOffset() string { return time.Now().Format("-07:00") }
+// txClock liefert Zeitstempel und Event-IDs, die ausschließlich aus der Transaktion
+// abgeleitet sind (GetTxTimestamp, GetTxID). Dadurch erzeugen alle endorsenden Peers
+// identische Write-Sets. Eine Instanz pro Transaktion anlegen und an Hilfsfunktionen
+// weiterreichen, damit die Event-Sequenz innerhalb der Transaktion fortläuft.
+type txClock struct {
+	txID      string
+	now       time.Time
+	seq       int
+	recordSeq int
+}
 
-func sgln(gln string) string {
-	if gln == "" {
-		return ""
+func newTxClock(ctx contractapi.TransactionContextInterface) (*txClock, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen des Transaktionszeitstempels: %v", err)
 	}
-	return "urn:epc:id:sgln:" + gln + ".0.0"
+	return &txClock{
+		txID: ctx.GetStub().GetTxID(),
+		now:  ts.AsTime().UTC(),
+	}, nil
 }
 
-// --------------------------- recalculateOverallStatus --------------------------- //
+// timestamp: Transaktionszeit im RFC3339-Format (UTC).
+func (clk *txClock) timestamp() string { return clk.now.Format(time.RFC3339) }
 
-func (dpp *DPP) recalculateOverallStatus() {
-	if dpp.Status == "Blocked" ||
-		strings.HasPrefix(dpp.Status, "ConsumedInTransformation") ||
-		strings.HasPrefix(dpp.Status, "RejectedBy") ||
-		strings.HasPrefix(dpp.Status, "InTransitTo") ||
-		dpp.Status == "AcceptedAtRecipient" {
-		return
-	}
+// tzOffset: Der Transaktionszeitstempel ist immer UTC.
+func (clk *txClock) tzOffset() string { return "+00:00" }
 
-	hasCriticalFailures := false
-	hasDeviations := false
+// nextEventID: Eindeutige Event-ID aus Art, TxID und laufender Nummer innerhalb der Transaktion.
+func (clk *txClock) nextEventID(kind string) string {
+	clk.seq++
+	return fmt.Sprintf("evt-%s-%s-%d", kind, clk.txID, clk.seq)
+}
 
-	for _, qe := range dpp.Quality {
-		if qe.EvaluationOutcome == "FAIL" || qe.EvaluationOutcome == "INVALID_FORMAT" {
-			hasCriticalFailures = true
-			break
-		}
-		if strings.HasPrefix(qe.EvaluationOutcome, "DEVIATION") {
-			hasDeviations = true
+// siteLocation: readPoint/bizLocation zu einem Ort, immer als GS1 Digital Link der GLN, wie es
+// EPCIS 2.0 für Orte zulässt. GLN und SGLN-URN desselben Ortes ergeben über normalizeGLN denselben
+// Wert; aus einer GLN allein ließe sich keine SGLN bilden (die Länge des Firmenpräfix ist nicht
+// bekannt). Ältere Events speichern den Ort noch als SGLN-URN oder als urn:epc:id:sgln:<GLN>.0.0,
+// deshalb gehen auch gespeicherte Orte beim Indizieren, Filtern und Export hier durch.
+// Leere und unbekannte Werte bleiben unverändert.
+func siteLocation(loc string) string {
+	gln := strings.TrimSuffix(strings.TrimPrefix(loc, "urn:epc:id:sgln:"), ".0.0")
+	if gs1.ValidateGLN(gln) != nil {
+		var err error
+		if gln, err = normalizeGLN("Ort", loc); err != nil {
+			return loc
 		}
 	}
+	return "https://id.gs1.org/414/" + gln
+}
 
-	if hasCriticalFailures {
-		dpp.Status = "Blocked"
-		return
-	}
+// --------------------------- recalculateOverallStatus --------------------------- //
 
-	if len(dpp.OpenMandatoryChecks) == 0 {
-		if hasDeviations {
-			dpp.Status = "ReleasedWithDeviations"
-		} else {
-			dpp.Status = "Released"
-		}
-	} else {
-		dpp.Status = fmt.Sprintf("AwaitingMandatoryChecks (%d open)", len(dpp.OpenMandatoryChecks))
+// recalculateOverallStatus leitet in den Qualitätsphasen (Draft bis Released*) den Status
+// aus Qualitätsdaten und offenen Pflichtprüfungen ab. Blocked, InTransit, AcceptedAtRecipient
+// und ConsumedInTransformation werden nur durch explizite Contract-Funktionen verlassen
+// (Blocked nur mit QA-Freigabe, siehe ReleaseBlockedDPP).
+func (dpp *DPP) recalculateOverallStatus(ctx contractapi.TransactionContextInterface, clk *txClock, reason string) error {
+	if dpp.checksLoaded {
+		dpp.applyChecks()
 	}
+	return dpp.transitionTo(ctx, clk, dpp.currentStatus(), reason)
+}
+
+// derivedQualityStatus: Status, der sich aus den Zusammenfassungen ergibt.
+func (dpp *DPP) derivedQualityStatus() DPPStatus {
+	// Quality wird in schreibenden Transaktionen nicht geladen; maßgeblich sind die aus den
+	// Prüfständen abgeleiteten Zusammenfassungen (siehe applyChecks). Eine Sperre durch ein
+	// kritisches Ergebnis bleibt auch nach dem Retest bis ReleaseBlockedDPP bestehen.
+	hasCriticalFailures := len(dpp.FailedTests) > 0 || dpp.hasBlockingCheck()
+	hasDeviations := len(dpp.DeviationTests) > 0
+
+	switch {
+	case hasCriticalFailures || dpp.hasDeniedConcession():
+		return StatusBlocked
+	case len(dpp.OpenMandatoryChecks) == 0 && hasDeviations && dpp.deviationsConceded():
+		return StatusReleasedWithDeviations
+	case len(dpp.OpenMandatoryChecks) == 0 && hasDeviations:
+		// Abweichungen brauchen eine vom Kunden genehmigte Sonderfreigabe (siehe dpp_concession.go).
+		return StatusAwaitingConcession
+	case len(dpp.OpenMandatoryChecks) == 0:
+		return StatusReleased
+	}
+	return StatusAwaitingMandatoryChecks
 }
 
 // --------------------------- Chaincode-APIs (Überarbeitet und Erweitert) --------------------------- //
 
-// CreateDPP: Legt einen neuen DPP an, initialisiert mit Spezifikationen.
+// CreateDPP: Legt einen neuen DPP an, initialisiert mit Spezifikationen aus dem Katalog.
 // ÄNDERUNG: Gibt jetzt (*DPP, error) zurück
-func (c *DPPQualityContract) CreateDPP(ctx contractapi.TransactionContextInterface, dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate string, specificationsJSON string) (*DPP, error) { // <-- Geänderter Rückgabetyp
-    fmt.Printf("[CreateDPP-DEBUG] Entry: dppID=%s, gs1Key=%s, productTypeID=%s, manufacturerGLN=%s, batch=%s, productionDate=%s\n", dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate)
+// specVersion referenziert einen aktiven Spezifikationssatz des Produkttyps (0 = neueste aktive Version).
+// quantity/quantityUnit: Menge der Charge mit UCUM-Einheit (z.B. 25000 "kg"); 0 und "" = ohne Menge.
+func (c *DPPQualityContract) CreateDPP(ctx contractapi.TransactionContextInterface, dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate string, quantity float64, quantityUnit string, specVersion int) (*DPP, error) { // <-- Geänderter Rückgabetyp
+    clk, err := newTxClock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return c.createDPP(ctx, clk, dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate, quantity, quantityUnit, specVersion)
+}
+
+// createDPP: Gemeinsame Implementierung für CreateDPP und RecordTransformation (teilt sich den txClock).
+func (c *DPPQualityContract) createDPP(ctx contractapi.TransactionContextInterface, clk *txClock, dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate string, quantity float64, quantityUnit string, specVersion int) (*DPP, error) {
+    fmt.Printf("[CreateDPP-DEBUG] Entry: dppID=%s, gs1Key=%s, productTypeID=%s, manufacturerGLN=%s, batch=%s, productionDate=%s, quantity=%v %s\n", dppID, gs1Key, productTypeID, manufacturerGLN, batch, productionDate, quantity, quantityUnit)
 
     exists, err := c.dppExists(ctx, dppID)
     if err != nil {
@@ -175,45 +314,45 @@ func (c *DPPQualityContract) CreateDPP(ctx contractapi.TransactionContextInterfa
         fmt.Printf("[CreateDPP-ERROR] Ungültiger GS1 Key %s: %v\n", gs1Key, err)
         return nil, err // <-- Geänderte Rückgabe
     }
-
-    var specs []QualitySpecification
-    if specificationsJSON != "" {
-        if err := json.Unmarshal([]byte(specificationsJSON), &specs); err != nil {
-            fmt.Printf("[CreateDPP-ERROR] Spezifikationen JSON fehlerhaft für DPP %s: %v\n", dppID, err)
-            return nil, fmt.Errorf("Spezifikationen JSON fehlerhaft: %v", err) // <-- Geänderte Rückgabe
-        }
+    manufacturerSite := manufacturerGLN // SGLN-URN bleibt als Ort des Events erhalten
+    manufacturerGLN, err = normalizeGLN("manufacturerGLN", manufacturerGLN)
+    if err != nil {
+        fmt.Printf("[CreateDPP-ERROR] Ungültige Hersteller-GLN %s: %v\n", manufacturerSite, err)
+        return nil, err
+    }
+    if err := validateQuantity(quantity, quantityUnit); err != nil {
+        fmt.Printf("[CreateDPP-ERROR] DPP %s: %v\n", dppID, err)
+        return nil, err
     }
 
-    var openMandatory []string
-    for _, s := range specs {
-        if s.IsMandatory {
-            openMandatory = append(openMandatory, s.TestName)
-        }
+    specSet, err := c.resolveSpecificationSet(ctx, productTypeID, specVersion)
+    if err != nil {
+        fmt.Printf("[CreateDPP-ERROR] Spezifikationssatz für DPP %s nicht auflösbar: %v\n", dppID, err)
+        return nil, err
     }
+    specs := specSet.Specifications
 
     clientMSPID, errClientMSPID := ctx.GetClientIdentity().GetMSPID()
     if errClientMSPID != nil {
         fmt.Printf("[CreateDPP-ERROR] Fehler beim Ermitteln der Client MSPID: %v\n", errClientMSPID)
         return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", errClientMSPID) // <-- Geänderte Rückgabe
     }
-    now := time.Now()
-    initialStatus := "Draft"
-
     evt := EPCISEvent{
-        EventID:             fmt.Sprintf("evt-create-%d", now.UnixNano()),
+        EventID:             clk.nextEventID("create"),
         EventType:           "ObjectEvent",
-        EventTime:           now.UTC().Format(time.RFC3339),
-        EventTimeZoneOffset: tzOffset(),
+        EventTime:           clk.timestamp(),
+        EventTimeZoneOffset: clk.tzOffset(),
         BizStep:             "urn:epcglobal:cbv:bizstep:commissioning",
         Action:              "ADD",
         EPCList:             []string{gs1Key},
         Disposition:         "urn:epcglobal:cbv:disp:active",
-        ReadPoint:           sgln(manufacturerGLN),
-        BizLocation:         sgln(manufacturerGLN),
-        Extensions:          make(map[string]interface{}),
+        ReadPoint:           siteLocation(manufacturerSite),
+        BizLocation:         siteLocation(manufacturerSite),
+        Extensions:          map[string]interface{}{"specificationSet": specSetRef(specSet)},
     }
 
     dpp := DPP{ // Erzeuge das DPP-Objekt
+        DocType:             dppDocType,
         DppID:               dppID,
         GS1Key:              gs1Key,
         ProductTypeID:       productTypeID,
@@ -221,166 +360,145 @@ func (c *DPPQualityContract) CreateDPP(ctx contractapi.TransactionContextInterfa
         Batch:               batch,
         ProductionDate:      productionDate,
         OwnerOrg:            clientMSPID,    // Der Aufrufer von CreateDPP, also Unternehmen C
-        Status:              initialStatus,
+        SpecificationVersion: specSet.Version,
         Specifications:      specs,
-        OpenMandatoryChecks: openMandatory,
-        Quality:             []QualityEntry{},
+        TransportSpecifications: specSet.TransportSpecifications,
         InputDPPIDs:         []string{},
-        EPCISEvents:         []EPCISEvent{evt},
+        Quantity:            quantity,
+        QuantityUnit:        quantityUnit,
     }
+    dpp.initChecks()
+    dpp.ensureDigitalLink()
+    dpp.addEvent(clk, evt)
 
-    dpp.recalculateOverallStatus() // Status anpassen
-
-    dppBytes, errMarshal := json.Marshal(dpp)
-    if errMarshal != nil {
-        fmt.Printf("[CreateDPP-ERROR] Fehler beim Marshalling von DPP %s: %v\n", dppID, errMarshal)
-        return nil, fmt.Errorf("Fehler beim Marshalling von DPP %s: %v", dppID, errMarshal) // <-- Geänderte Rückgabe
+    if err := dpp.transitionTo(ctx, clk, StatusDraft, "DPP angelegt"); err != nil {
+        return nil, err
+    }
+    if err := dpp.recalculateOverallStatus(ctx, clk, "Initiale Statusermittlung nach Anlage"); err != nil { // Status anpassen
+        return nil, err
     }
-    logKey := dppPrefix + dppID
-
-    logBytesSample := string(dppBytes)
-    if len(logBytesSample) > 200 { logBytesSample = logBytesSample[:200] + "..." }
-    fmt.Printf("[CreateDPP-DEBUG] Vor PutState für Key: %s, DPP JSON (Ausschnitt): %s\n", logKey, logBytesSample)
 
-    errPut := ctx.GetStub().PutState(logKey, dppBytes)
-    if errPut != nil {
-        fmt.Printf("[CreateDPP-ERROR] PutState für Key %s fehlgeschlagen: %v\n", logKey, errPut)
+    if errPut := c.saveDPP(ctx, &dpp); errPut != nil {
+        fmt.Printf("[CreateDPP-ERROR] Speichern von DPP %s fehlgeschlagen: %v\n", dppID, errPut)
         return nil, errPut // <-- Geänderte Rückgabe
     }
-    fmt.Printf("[CreateDPP-DEBUG] PutState für Key %s anscheinend erfolgreich.\n", logKey)
+    fmt.Printf("[CreateDPP-DEBUG] DPP %s gespeichert (Status %s).\n", dppID, dpp.Status)
     return &dpp, nil // <-- Geänderte Rückgabe: Gib das erstellte Objekt und nil Fehler zurück
 }
 
-// RecordQualityData: Erfasst Qualitätsdaten, bewertet sie gegen Spezifikationen und aktualisiert den DPP-Status.
+// RecordQualityData: Erfasst Qualitätsdaten, bewertet sie gegen Spezifikationen und führt den Prüfstand des Tests nach.
 // Erzeugt ein EPCIS Event für die Qualitätsprüfung.
+// Eintrag, Event und Prüfstand werden unter eigenen Schlüsseln abgelegt; der DPP-Kopf wird
+// nicht geschrieben, der Status ergibt sich beim Lesen aus den Prüfständen (dpp_checks.go).
+// Ist qualityEntryJSON leer, wird der Eintrag vertraulich über die Transient Map übergeben
+// (siehe dpp_private.go); öffentlich bleiben dann nur Testname, Bewertung und Hash.
 func (c *DPPQualityContract) RecordQualityData(ctx contractapi.TransactionContextInterface, dppID string, qualityEntryJSON string, recordingSiteGLN string) error {
-	dppBytes, err := ctx.GetStub().GetState(dppPrefix + dppID)
+	if err := validateSiteGLN("recordingSiteGLN", recordingSiteGLN); err != nil {
+		return err
+	}
+	dpp, err := c.readDPPHeader(ctx, dppID)
 	if err != nil {
 		return err
 	}
-	if dppBytes == nil {
-		return fmt.Errorf("DPP %s nicht gefunden", dppID)
+	clientMSPID, err := requireOwner(ctx, dpp, "Qualitätsdaten erfassen")
+	if err != nil {
+		return err
 	}
-
-	var dpp DPP
-	if err := json.Unmarshal(dppBytes, &dpp); err != nil {
-		return fmt.Errorf("Fehler beim Unmarshalling von DPP %s: %v", dppID, err)
+	if err := dpp.checkNotTerminal("Qualitätsdaten erfasst"); err != nil {
+		return err
 	}
 
 	var qe QualityEntry
-	if err := json.Unmarshal([]byte(qualityEntryJSON), &qe); err != nil {
+	var private *privateSubmission
+	if qualityEntryJSON == "" {
+		if private, err = readPrivateSubmission(ctx, dpp, clientMSPID); err != nil {
+			return err
+		}
+		qe = private.entry
+	} else if err := json.Unmarshal([]byte(qualityEntryJSON), &qe); err != nil {
 		return fmt.Errorf("QualityEntry JSON fehlerhaft: %v", err)
 	}
+	// Vom Contract verwaltete Felder werden nicht vom Client übernommen.
+	qe.clearManagedFields()
+	if err := anchorOffChainHash(&qe); err != nil {
+		return err
+	}
 
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
 	if qe.Timestamp == "" {
-		qe.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		qe.Timestamp = clk.timestamp()
 	}
 	if qe.PerformingOrg == "" {
-		clientMSPID, errClientMSPID := ctx.GetClientIdentity().GetMSPID()
-		if errClientMSPID != nil {
-			return fmt.Errorf("Fehler beim Ermitteln der Client MSPID für QualityEntry: %v", errClientMSPID)
-		}
 		qe.PerformingOrg = clientMSPID
 	}
 
-	qe.EvaluationOutcome = "NO_SPEC"
-	qe.EvaluationComment = ""
-	var currentSpec *QualitySpecification
-	for i := range dpp.Specifications {
-		if dpp.Specifications[i].TestName == qe.TestName {
-			currentSpec = &dpp.Specifications[i]
-			break
-		}
-	}
-
-	if currentSpec != nil {
-		if currentSpec.IsNumeric {
-			resultVal, convErr := strconv.ParseFloat(qe.Result, 64)
-			if convErr != nil {
-				qe.EvaluationOutcome = "INVALID_FORMAT"
-				qe.EvaluationComment = fmt.Sprintf("Ergebnis '%s' für Test '%s' ist nicht numerisch.", qe.Result, qe.TestName)
-			} else {
-				if resultVal < currentSpec.LowerLimit {
-					qe.EvaluationOutcome = "DEVIATION_LOW"
-					qe.EvaluationComment = fmt.Sprintf("Wert %.4f unter Grenzwert %.4f %s.", resultVal, currentSpec.LowerLimit, currentSpec.Unit)
-				} else if resultVal > currentSpec.UpperLimit {
-					qe.EvaluationOutcome = "DEVIATION_HIGH"
-					qe.EvaluationComment = fmt.Sprintf("Wert %.4f über Grenzwert %.4f %s.", resultVal, currentSpec.UpperLimit, currentSpec.Unit)
-				} else {
-					qe.EvaluationOutcome = "PASS"
-				}
-			}
-		} else { // String-basierter Test
-			if strings.EqualFold(qe.Result, currentSpec.ExpectedValue) {
-				qe.EvaluationOutcome = "PASS"
-			} else {
-				qe.EvaluationOutcome = "FAIL"
-				qe.EvaluationComment = fmt.Sprintf("Erwartet: '%s', Erhalten: '%s'.", currentSpec.ExpectedValue, qe.Result)
-			}
-		}
-		if currentSpec.Unit != "" && qe.Unit != "" && !strings.EqualFold(currentSpec.Unit, qe.Unit) {
-			qe.EvaluationComment += fmt.Sprintf(" Einheit für '%s' passt nicht: Spezifikation '%s', Eintrag '%s'.", qe.TestName, currentSpec.Unit, qe.Unit)
+	if private != nil {
+		if err := c.recordPrivateQualityEntry(ctx, clk, dpp, private, &qe); err != nil {
+			return err
 		}
-	} else if qe.TestName != "" {
-		qe.EvaluationComment = fmt.Sprintf("Keine Spezifikation für Test '%s' im DPP hinterlegt. Daten werden als informativ gespeichert.", qe.TestName)
-	}
-
-	dpp.Quality = append(dpp.Quality, qe)
-
-	now := time.Now()
-	epcisDisposition := "urn:epcglobal:cbv:disp:active"
-	if qe.EvaluationOutcome == "PASS" {
-		epcisDisposition = "urn:epcglobal:cbv:disp:conformant"
-	} else if strings.HasPrefix(qe.EvaluationOutcome, "FAIL") || strings.HasPrefix(qe.EvaluationOutcome, "DEVIATION") || qe.EvaluationOutcome == "INVALID_FORMAT" {
-		epcisDisposition = "urn:epcglobal:cbv:disp:non_conformant"
+	} else if _, err := dpp.applyQualityEntry(ctx, clk, &qe); err != nil {
+		return err
 	}
 
 	qcEvent := EPCISEvent{
-		EventID:             fmt.Sprintf("evt-qc-%s-%d", strings.ReplaceAll(strings.ReplaceAll(qe.TestName, " ", "_"), "/", "_"), now.UnixNano()),
+		EventID:             clk.nextEventID("qc-" + strings.ReplaceAll(strings.ReplaceAll(qe.TestName, " ", "_"), "/", "_")),
 		EventType:           "ObjectEvent",
-		EventTime:           now.UTC().Format(time.RFC3339),
-		EventTimeZoneOffset: tzOffset(),
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
 		BizStep:             "urn:epcglobal:cbv:bizstep:inspecting",
 		Action:              "OBSERVE",
 		EPCList:             []string{dpp.GS1Key},
-		Disposition:         epcisDisposition,
-		ReadPoint:           sgln(recordingSiteGLN),
-		BizLocation:         sgln(recordingSiteGLN),
+		Disposition:         qe.EvaluationOutcome.disposition(),
+		ReadPoint:           siteLocation(recordingSiteGLN),
+		BizLocation:         siteLocation(recordingSiteGLN),
+		SensorElementList:   sensorElements(qualitySensorElement(dpp.specFor(qe.TestName), qe)),
 		Extensions:          map[string]interface{}{"recordedQualityData": qe},
 	}
-	dpp.EPCISEvents = append(dpp.EPCISEvents, qcEvent)
+	dpp.addEvent(clk, qcEvent)
 
-	if currentSpec != nil && currentSpec.IsMandatory && qe.EvaluationOutcome == "PASS" {
-		var newOpenChecks []string
-		for _, checkName := range dpp.OpenMandatoryChecks {
-			if checkName != qe.TestName {
-				newOpenChecks = append(newOpenChecks, checkName)
-			}
-		}
-		dpp.OpenMandatoryChecks = newOpenChecks
-	}
-	dpp.recalculateOverallStatus()
-
-	if qe.EvaluationOutcome == "FAIL" || strings.HasPrefix(qe.EvaluationOutcome, "DEVIATION") || qe.EvaluationOutcome == "INVALID_FORMAT" {
-		alertPayload := map[string]interface{}{
-			"dppId":             dppID,
-			"gs1Key":            dpp.GS1Key,
-			"batch":             dpp.Batch,
-			"productTypeId":     dpp.ProductTypeID,
-			"testName":          qe.TestName,
-			"result":            qe.Result,
-			"evaluationOutcome": qe.EvaluationOutcome,
-			"evaluationComment": qe.EvaluationComment,
-			"timestamp":         qe.Timestamp,
-			"systemId":          qe.SystemID,
-			"performingOrg":     qe.PerformingOrg,
-		}
-		alertBytes, _ := json.Marshal(alertPayload)
-		ctx.GetStub().SetEvent("QualityAlert", alertBytes)
+	if qe.EvaluationOutcome.isNonConformant() {
+		emitQualityAlert(ctx, dpp, qe)
+	}
+
+	return c.saveDPP(ctx, dpp)
+}
+
+// emitQualityAlert setzt das Event "QualityAlert" für ein nicht konformes Ergebnis.
+func emitQualityAlert(ctx contractapi.TransactionContextInterface, dpp *DPP, qe QualityEntry) {
+	alertPayload := qualityAlertHeader(dpp)
+	for k, v := range qualityAlertFields(qe) {
+		alertPayload[k] = v
+	}
+	alertBytes, _ := json.Marshal(alertPayload)
+	ctx.GetStub().SetEvent("QualityAlert", alertBytes)
+}
+
+func qualityAlertHeader(dpp *DPP) map[string]interface{} {
+	return map[string]interface{}{
+		"dppId":         dpp.DppID,
+		"gs1Key":        dpp.GS1Key,
+		"batch":         dpp.Batch,
+		"productTypeId": dpp.ProductTypeID,
 	}
+}
 
-	updatedDppBytes, _ := json.Marshal(dpp)
-	return ctx.GetStub().PutState(dppPrefix+dppID, updatedDppBytes)
+func qualityAlertFields(qe QualityEntry) map[string]interface{} {
+	fields := map[string]interface{}{
+		"testName":          qe.TestName,
+		"result":            qe.Result,
+		"evaluationOutcome": qe.EvaluationOutcome,
+		"evaluationComment": qe.EvaluationComment,
+		"timestamp":         qe.Timestamp,
+		"systemId":          qe.SystemID,
+		"performingOrg":     qe.PerformingOrg,
+	}
+	if qe.Supersedes != "" {
+		fields["supersedes"] = qe.Supersedes
+	}
+	return fields
 }
 
 // RecordTransformation: Erstellt neuen DPP für Compound (C), verknüpft Inputs (A,B)
@@ -388,8 +506,9 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
     outputDppID, outputGS1Key, outputProductTypeID string, // Für neuen DPP von C
     currentGLN string, // GLN von Unternehmen C (Ort der Transformation)
     batch, productionDate string, // Für neuen DPP von C
+    quantity float64, quantityUnit string, // Menge des Compounds (UCUM-Einheit), 0 und "" = ohne Menge
     inputDPPIDsJSON string, // JSON Array der Ledger-IDs der Input-DPPs (von A, B)
-    outputSpecificationsJSON string, // Spezifikationen für das Compound-Produkt
+    outputSpecVersion int, // Version des Spezifikationssatzes für das Compound-Produkt (0 = neueste aktive)
     initialQualityEntryJSON string) error { // Optionale initiale Q-Prüfung des Compounds
 
     fmt.Printf("[RecordTransformation-DEBUG] Entry: outputDppID=%s, outputGS1Key=%s, outputProductTypeID=%s, currentGLN=%s, batch=%s, productionDate=%s\n", outputDppID, outputGS1Key, outputProductTypeID, currentGLN, batch, productionDate)
@@ -424,6 +543,10 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
     */
 
     fmt.Printf("[RecordTransformation-DEBUG] inputDPPIDsJSON: %s\n", inputDPPIDsJSON)
+    clk, err := newTxClock(ctx)
+    if err != nil {
+        return err
+    }
     // ... (Validierungen für outputDppID, outputGS1Key etc. bleiben gleich) ...
     exists, err := c.dppExists(ctx, outputDppID) // dppExists prüft ja den World State, das ist OK.
     if err != nil {
@@ -438,6 +561,14 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
         fmt.Printf("[RecordTransformation-ERROR] Ungültiger GS1 Key %s für OutputDPP: %v\n", outputGS1Key, err)
         return fmt.Errorf("Ungültiger GS1 Key '%s' für OutputDPP: %v", outputGS1Key, err)
     }
+    if _, err := normalizeGLN("currentGLN", currentGLN); err != nil {
+        fmt.Printf("[RecordTransformation-ERROR] %v\n", err)
+        return err
+    }
+    if err := validateQuantity(quantity, quantityUnit); err != nil {
+        fmt.Printf("[RecordTransformation-ERROR] Output-DPP %s: %v\n", outputDppID, err)
+        return err
+    }
 
 
     var inputDPPIDs []string
@@ -447,48 +578,44 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
     }
     fmt.Printf("[RecordTransformation-DEBUG] Parsed inputDPPIDs: %v\n", inputDPPIDs)
 
+    tfEventID := clk.nextEventID("tf")
     var inputGS1KeysForEvent []string
     for _, inputID := range inputDPPIDs {
         // ... (Logik zum Verarbeiten und Aktualisieren der Input-DPPs bleibt gleich) ...
          fmt.Printf("[RecordTransformation-DEBUG] Verarbeite InputDPP ID: %s\n", inputID)
-	    inputDppBytes, errGet := ctx.GetStub().GetState(dppPrefix + inputID)
+	    inputDPP, errGet := c.readDPPHeader(ctx, inputID)
 	    if errGet != nil {
-	        fmt.Printf("[RecordTransformation-ERROR] Fehler beim Lesen von Input-DPP %s: %v\n", inputID, errGet)
-	        return fmt.Errorf("Fehler beim Lesen von Input-DPP %s: %v", inputID, errGet)
+	        fmt.Printf("[RecordTransformation-ERROR] Input-DPP %s: %v\n", inputID, errGet)
+	        return fmt.Errorf("Input-DPP %s: %v", inputID, errGet)
+	    }
+
+	    if _, errOwner := requireOwner(ctx, inputDPP, "als Input verbrauchen"); errOwner != nil {
+	        return errOwner
 	    }
-	    if inputDppBytes == nil {
-	        fmt.Printf("[RecordTransformation-ERROR] Input-DPP %s nicht gefunden.\n", inputID)
-	        return fmt.Errorf("Input-DPP %s nicht gefunden", inputID)
+	    if errRecall := inputDPP.checkNoOpenRecall("verbraucht"); errRecall != nil {
+	        return errRecall
 	    }
-	    var inputDPP DPP
-	    if errUnmarshalInput := json.Unmarshal(inputDppBytes, &inputDPP); errUnmarshalInput != nil {
-	        fmt.Printf("[RecordTransformation-ERROR] Fehler beim Unmarshalling von Input-DPP %s: %v\n", inputID, errUnmarshalInput)
-	        return fmt.Errorf("Fehler beim Unmarshalling von Input-DPP %s: %v", inputID, errUnmarshalInput)
+	    if errSync := c.syncStatus(ctx, clk, inputDPP); errSync != nil {
+	        return errSync
 	    }
-
-	    if inputDPP.Status != "Released" && inputDPP.Status != "ReleasedWithDeviations" && inputDPP.Status != "AcceptedAtRecipient" {
-	        msg := fmt.Sprintf("Input DPP %s (GS1 %s) hat ungültigen Status '%s' für Transformation. Erlaubt sind 'Released', 'ReleasedWithDeviations', 'AcceptedAtRecipient'.", inputID, inputDPP.GS1Key, inputDPP.Status)
-	        fmt.Printf("[RecordTransformation-ERROR] %s\n", msg)
-	        fmt.Printf("[RecordTransformation-WARN] Transformation wird trotz Status '%s' durchgeführt (ursprüngliche Logik beibehalten).\n", inputDPP.Status)
+	    if errTransition := inputDPP.transitionTo(ctx, clk, StatusConsumedInTransformation, fmt.Sprintf("Verbraucht in Transformation zu DPP %s", outputDppID)); errTransition != nil {
+	        fmt.Printf("[RecordTransformation-ERROR] Input DPP %s (GS1 %s): %v\n", inputID, inputDPP.GS1Key, errTransition)
+	        return fmt.Errorf("Input DPP %s kann nicht in Transformation verbraucht werden: %v", inputID, errTransition)
 	    }
+	    inputDPP.ConsumedByDPPID = outputDppID
+	    inputDPP.OutputDPPIDs = addUnique(inputDPP.OutputDPPIDs, outputDppID)
 	    inputGS1KeysForEvent = append(inputGS1KeysForEvent, inputDPP.GS1Key)
 
-	    inputDPP.Status = fmt.Sprintf("ConsumedInTransformation_%s", outputDppID)
-	    updatedInputBytes, errMarshalInput := json.Marshal(inputDPP)
-	    if errMarshalInput != nil {
-	        fmt.Printf("[RecordTransformation-ERROR] Fehler beim Marshalling des aktualisierten Input-DPP %s: %v\n", inputID, errMarshalInput)
-	        return fmt.Errorf("Fehler beim Marshalling des aktualisierten Input-DPP %s: %v", inputID, errMarshalInput)
-	    }
-	    if errPutInput := ctx.GetStub().PutState(dppPrefix+inputID, updatedInputBytes); errPutInput != nil {
+	    if errPutInput := c.saveDPP(ctx, inputDPP); errPutInput != nil {
 	        fmt.Printf("[RecordTransformation-ERROR] Fehler beim Aktualisieren des Input-DPP %s: %v\n", inputID, errPutInput)
 	        return fmt.Errorf("Fehler beim Aktualisieren des Input-DPP %s: %v", inputID, errPutInput)
 	    }
-	    fmt.Printf("[RecordTransformation-DEBUG] InputDPP ID %s als 'ConsumedInTransformation_%s' markiert und gespeichert.\n", inputID, outputDppID)
+	    fmt.Printf("[RecordTransformation-DEBUG] InputDPP ID %s als '%s' (durch %s) markiert und gespeichert.\n", inputID, StatusConsumedInTransformation, outputDppID)
     }
 
     fmt.Printf("[RecordTransformation-DEBUG] Rufe modifiziertes CreateDPP auf für outputDppID: %s\n", outputDppID)
     // HIER DIE ÄNDERUNG: outputDPP ist jetzt das direkt zurückgegebene Objekt
-    outputDPP, errCreate := c.CreateDPP(ctx, outputDppID, outputGS1Key, outputProductTypeID, currentGLN, batch, productionDate, outputSpecificationsJSON)
+    outputDPP, errCreate := c.createDPP(ctx, clk, outputDppID, outputGS1Key, outputProductTypeID, currentGLN, batch, productionDate, quantity, quantityUnit, outputSpecVersion)
     if errCreate != nil {
         fmt.Printf("[RecordTransformation-ERROR] CreateDPP für outputDppID %s ist fehlgeschlagen: %v\n", outputDppID, errCreate)
         // Wichtig: Da CreateDPP bei Fehlern nil zurückgibt, müssen wir hier abbrechen.
@@ -502,18 +629,18 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
 
     // Jetzt outputDPP direkt modifizieren (das Objekt, das von CreateDPP zurückgegeben wurde)
     outputDPP.InputDPPIDs = inputDPPIDs
+    outputDPP.TransformationEventID = tfEventID
 
-    now := time.Now() // 'now' neu definieren oder die von CreateDPP verwenden, je nach Bedarf
     tfEvent := EPCISEvent{
-        EventID:             fmt.Sprintf("evt-tf-%s-%d", strings.ReplaceAll(outputGS1Key, ":", "_"), now.UnixNano()),
+        EventID:             tfEventID,
         EventType:           "TransformationEvent",
-        EventTime:           now.UTC().Format(time.RFC3339),
-        EventTimeZoneOffset: tzOffset(),
+        EventTime:           clk.timestamp(),
+        EventTimeZoneOffset: clk.tzOffset(),
         BizStep:             "urn:epcglobal:cbv:bizstep:transforming",
         InputEPCList:        inputGS1KeysForEvent,
         OutputEPCList:       []string{outputGS1Key},
-        ReadPoint:           sgln(currentGLN),
-        BizLocation:         sgln(currentGLN),
+        ReadPoint:           siteLocation(currentGLN),
+        BizLocation:         siteLocation(currentGLN),
         Extensions:          make(map[string]interface{}),
     }
 
@@ -521,7 +648,8 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
     if initialQualityEntryJSON != "" && initialQualityEntryJSON != "{}" {
          var initialQE QualityEntry
 	    if errQE := json.Unmarshal([]byte(initialQualityEntryJSON), &initialQE); errQE == nil {
-	        if initialQE.Timestamp == "" { initialQE.Timestamp = now.UTC().Format(time.RFC3339) }
+	        initialQE.clearManagedFields()
+	        if initialQE.Timestamp == "" { initialQE.Timestamp = clk.timestamp() }
 	        if initialQE.PerformingOrg == "" {
 	            clientMSPID, errClientMSPID := ctx.GetClientIdentity().GetMSPID()
 	            if errClientMSPID != nil {
@@ -530,91 +658,34 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
 	            }
 	            initialQE.PerformingOrg = clientMSPID
 	        }
+	        if errHash := anchorOffChainHash(&initialQE); errHash != nil {
+	            return errHash
+	        }
 	        tfEvent.Extensions["initialCompoundQuality"] = initialQE
 	        fmt.Printf("[RecordTransformation-DEBUG] InitialQE für Extension vorbereitet: %+v\n", initialQE)
 
-	        initialQE.EvaluationOutcome = "NO_SPEC"
-	        initialQE.EvaluationComment = ""
-	        var currentSpecForInitialQE *QualitySpecification
-	        for i := range outputDPP.Specifications {
-	            if outputDPP.Specifications[i].TestName == initialQE.TestName {
-	                currentSpecForInitialQE = &outputDPP.Specifications[i]
-	                break
-	            }
-	        }
-	        if currentSpecForInitialQE != nil {
-	            if currentSpecForInitialQE.IsNumeric {
-	                // ... (Rest der numerischen Bewertung)
-                    resVal, convErr := strconv.ParseFloat(initialQE.Result, 64)
-                    if convErr != nil {
-                        initialQE.EvaluationOutcome = "INVALID_FORMAT"
-                        initialQE.EvaluationComment = fmt.Sprintf("Initiales Ergebnis '%s' für Test '%s' ist nicht numerisch.", initialQE.Result, initialQE.TestName)
-                    } else {
-                        if resVal < currentSpecForInitialQE.LowerLimit {
-                            initialQE.EvaluationOutcome = "DEVIATION_LOW_INITIAL"
-                            initialQE.EvaluationComment = fmt.Sprintf("Initialer Wert %.4f unter Grenzwert %.4f %s.", resVal, currentSpecForInitialQE.LowerLimit, currentSpecForInitialQE.Unit)
-                        } else if resVal > currentSpecForInitialQE.UpperLimit {
-                            initialQE.EvaluationOutcome = "DEVIATION_HIGH_INITIAL"
-                            initialQE.EvaluationComment = fmt.Sprintf("Initialer Wert %.4f über Grenzwert %.4f %s.", resVal, currentSpecForInitialQE.UpperLimit, currentSpecForInitialQE.Unit)
-                        } else {
-                            initialQE.EvaluationOutcome = "PASS"
-                        }
-                    }
-	            } else {
-	                // ... (Rest der String-Bewertung)
-                    if strings.EqualFold(initialQE.Result, currentSpecForInitialQE.ExpectedValue) {
-                        initialQE.EvaluationOutcome = "PASS"
-                    } else {
-                        initialQE.EvaluationOutcome = "FAIL_INITIAL"
-                        initialQE.EvaluationComment = fmt.Sprintf("Initial erwartet: '%s', Erhalten: '%s'.", currentSpecForInitialQE.ExpectedValue, initialQE.Result)
-                    }
-	            }
-                 if currentSpecForInitialQE.Unit != "" && initialQE.Unit != "" && !strings.EqualFold(currentSpecForInitialQE.Unit, initialQE.Unit) {
-	                initialQE.EvaluationComment += fmt.Sprintf(" Einheit für initialen Test '%s' passt nicht: Spezifikation '%s', Eintrag '%s'.", initialQE.TestName, currentSpecForInitialQE.Unit, initialQE.Unit)
-	            }
-	        } else if initialQE.TestName != "" {
-	             initialQE.EvaluationComment = fmt.Sprintf("Keine Spezifikation für initialen Test '%s' im Compound-DPP hinterlegt. Daten als informativ gespeichert.", initialQE.TestName)
-	        }
-	        outputDPP.Quality = append(outputDPP.Quality, initialQE)
-	        fmt.Printf("[RecordTransformation-DEBUG] InitialQE zu outputDPP.Quality hinzugefügt: %+v\n", initialQE)
-
-	        if currentSpecForInitialQE != nil && currentSpecForInitialQE.IsMandatory && initialQE.EvaluationOutcome == "PASS" {
-	            var newOpenChecks []string
-	            for _, checkName := range outputDPP.OpenMandatoryChecks {
-	                if checkName != initialQE.TestName {
-	                    newOpenChecks = append(newOpenChecks, checkName)
-	                }
-	            }
-	            outputDPP.OpenMandatoryChecks = newOpenChecks
-	            fmt.Printf("[RecordTransformation-DEBUG] OpenMandatoryChecks nach initialQE aktualisiert: %v\n", newOpenChecks)
+	        if _, errApply := outputDPP.applyQualityEntry(ctx, clk, &initialQE); errApply != nil {
+	            return errApply
 	        }
+	        fmt.Printf("[RecordTransformation-DEBUG] InitialQE bewertet und für outputDPP vorgemerkt: %+v\n", initialQE)
+	        fmt.Printf("[RecordTransformation-DEBUG] OpenMandatoryChecks nach initialQE: %v\n", outputDPP.OpenMandatoryChecks)
 	    } else {
 	        fmt.Printf("[RecordTransformation-WARN] initialQualityEntryJSON ('%s') fehlerhaft und wird ignoriert: %v\n", initialQualityEntryJSON, errQE)
 	    }
     } else {
-        if outputDPP.Quality == nil { outputDPP.Quality = []QualityEntry{} }
          fmt.Printf("[RecordTransformation-DEBUG] Keine initialQualityEntryJSON vorhanden oder leer.\n")
     }
 
-    outputDPP.EPCISEvents = append(outputDPP.EPCISEvents, tfEvent)
-    outputDPP.recalculateOverallStatus() // Status basierend auf initialen Checks und Qualität
-    fmt.Printf("[RecordTransformation-DEBUG] Status des Output-DPP %s nach recalculateOverallStatus: %s\n", outputDppID, outputDPP.Status)
-
-    // Finalen Output-DPP speichern (dies ist jetzt der einzige PutState für den outputDPP in dieser Funktion)
-    finalOutputDppBytes, errMarshalFinal := json.Marshal(outputDPP)
-    if errMarshalFinal != nil {
-        fmt.Printf("[RecordTransformation-ERROR] Fehler beim finalen Marshalling von OutputDPP %s: %v\n", outputDppID, errMarshalFinal)
-        return fmt.Errorf("Fehler beim finalen Marshalling von OutputDPP %s: %v", outputDppID, errMarshalFinal)
+    outputDPP.addEvent(clk, tfEvent)
+    if errStatus := outputDPP.recalculateOverallStatus(ctx, clk, "Statusermittlung nach Transformation"); errStatus != nil { // Status basierend auf initialen Checks und Qualität
+        return errStatus
     }
+    fmt.Printf("[RecordTransformation-DEBUG] Status des Output-DPP %s nach recalculateOverallStatus: %s\n", outputDppID, outputDPP.Status)
 
-    targetKey := dppPrefix + outputDppID // targetKey hier definieren für den Log
-    logFinalBytesSample := string(finalOutputDppBytes)
-    if len(logFinalBytesSample) > 300 { logFinalBytesSample = logFinalBytesSample[:300] + "..." }
-    fmt.Printf("[RecordTransformation-DEBUG] Vor finalem PutState für Key: %s, OutputDPP JSON (Ausschnitt): %s\n", targetKey, logFinalBytesSample)
-
-    errPutFinal := ctx.GetStub().PutState(targetKey, finalOutputDppBytes)
+    // Finalen Output-DPP speichern (Kopf wurde in createDPP bereits geschrieben, saveDPP überschreibt ihn mit InputDPPIDs)
+    errPutFinal := c.saveDPP(ctx, outputDPP)
     if errPutFinal != nil {
-        fmt.Printf("[RecordTransformation-ERROR] Finales PutState für Output-DPP %s (Key: %s) fehlgeschlagen: %v\n", outputDppID, targetKey, errPutFinal)
+        fmt.Printf("[RecordTransformation-ERROR] Finales Speichern von Output-DPP %s fehlgeschlagen: %v\n", outputDppID, errPutFinal)
         return fmt.Errorf("Finales PutState für Output-DPP %s fehlgeschlagen: %v", outputDppID, errPutFinal)
     }
 
@@ -624,67 +695,66 @@ func (c *DPPQualityContract) RecordTransformation(ctx contractapi.TransactionCon
 
 // TransferDPP: Unternehmen C übergibt den Compound-DPP an D
 func (c *DPPQualityContract) TransferDPP(ctx contractapi.TransactionContextInterface, dppID, newOwnerMSP, shipperGLN string) error {
-	dppBytes, err := ctx.GetStub().GetState(dppPrefix + dppID)
-	if err != nil {
+	if err := validateSiteGLN("shipperGLN", shipperGLN); err != nil {
 		return err
 	}
-	if dppBytes == nil {
-		return fmt.Errorf("DPP %s nicht gefunden", dppID)
-	}
-	var dpp DPP
-	if errUnmarshal := json.Unmarshal(dppBytes, &dpp); errUnmarshal != nil {
-		return fmt.Errorf("Fehler beim Unmarshalling von DPP %s für Transfer: %v", dppID, errUnmarshal)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
 	}
 
-	currentOwnerMSPID, errClientMSPID := ctx.GetClientIdentity().GetMSPID()
-	if errClientMSPID != nil {
-		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID für Transfer: %v", errClientMSPID)
-	}
-	if dpp.OwnerOrg != currentOwnerMSPID {
-		return fmt.Errorf("Nur der aktuelle Eigentümer (%s) darf DPP %s transferieren. Aufrufer ist %s.", dpp.OwnerOrg, dppID, currentOwnerMSPID)
+	currentOwnerMSPID, err := requireOwner(ctx, dpp, "transferieren")
+	if err != nil {
+		return err
 	}
 	if dpp.OwnerOrg == newOwnerMSP {
 		return errors.New("neuer Eigentümer ist identisch mit aktuellem Eigentümer")
 	}
-
-	if dpp.Status != "Released" && dpp.Status != "ReleasedWithDeviations" {
-		return fmt.Errorf("DPP %s (Status: %s) ist nicht für den Transfer freigegeben.", dppID, dpp.Status)
+	if err := dpp.checkNoOpenRecall("versendet"); err != nil {
+		return err
 	}
 
-	now := time.Now()
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.syncStatus(ctx, clk, dpp); err != nil {
+		return err
+	}
+	if err := dpp.checkConcessionCustomer(newOwnerMSP); err != nil {
+		return err
+	}
 	shipEvt := EPCISEvent{
-		EventID:             fmt.Sprintf("evt-ship-%s-%d", strings.ReplaceAll(dpp.GS1Key, ":", "_"), now.UnixNano()),
+		EventID:             clk.nextEventID("ship"),
 		EventType:           "ObjectEvent",
-		EventTime:           now.UTC().Format(time.RFC3339),
-		EventTimeZoneOffset: tzOffset(),
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
 		BizStep:             "urn:epcglobal:cbv:bizstep:shipping",
 		Action:              "OBSERVE",
 		EPCList:             []string{dpp.GS1Key},
 		Disposition:         "urn:epcglobal:cbv:disp:in_transit",
-		ReadPoint:           sgln(shipperGLN),
+		ReadPoint:           siteLocation(shipperGLN),
 		BizLocation:         "", // Leer, da unterwegs
 		Extensions:          map[string]interface{}{"intendedRecipientMSP": newOwnerMSP},
 	}
-	dpp.EPCISEvents = append(dpp.EPCISEvents, shipEvt)
+	if err := dpp.transitionTo(ctx, clk, StatusInTransit, fmt.Sprintf("Versand von %s an %s", currentOwnerMSPID, newOwnerMSP)); err != nil {
+		return err
+	}
+	dpp.addEvent(clk, shipEvt)
 	dpp.OwnerOrg = newOwnerMSP
-	dpp.Status = fmt.Sprintf("InTransitTo_%s", newOwnerMSP)
+	dpp.IntendedRecipientMSP = newOwnerMSP
 
-	updatedDppBytes, _ := json.Marshal(dpp)
-	return ctx.GetStub().PutState(dppPrefix+dppID, updatedDppBytes)
+	return c.saveDPP(ctx, dpp)
 }
 
 // AcknowledgeReceiptAndRecordInspection: Unternehmen D bestätigt Empfang und führt ggf. Eingangsprüfung durch.
 func (c *DPPQualityContract) AcknowledgeReceiptAndRecordInspection(ctx contractapi.TransactionContextInterface, dppID, recipientGLN string, incomingInspectionJSON string) error {
-	dppBytes, err := ctx.GetStub().GetState(dppPrefix + dppID)
-	if err != nil {
+	if err := validateSiteGLN("recipientGLN", recipientGLN); err != nil {
 		return err
 	}
-	if dppBytes == nil {
-		return fmt.Errorf("DPP %s nicht gefunden", dppID)
-	}
-	var dpp DPP
-	if errUnmarshal := json.Unmarshal(dppBytes, &dpp); errUnmarshal != nil {
-		return fmt.Errorf("Fehler beim Unmarshalling von DPP %s für Acknowledge: %v", dppID, errUnmarshal)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
 	}
 
 	recipientMSPID, errClientMSPID := ctx.GetClientIdentity().GetMSPID()
@@ -692,89 +762,85 @@ func (c *DPPQualityContract) AcknowledgeReceiptAndRecordInspection(ctx contracta
 		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID für Acknowledge: %v", errClientMSPID)
 	}
 
-	expectedStatus := "InTransitTo_" + recipientMSPID
-	if dpp.OwnerOrg != recipientMSPID || dpp.Status != expectedStatus {
-		return fmt.Errorf("DPP %s ist nicht für Empfang durch %s vorgesehen oder hat falschen Status/Owner (Status: %s, Owner: %s, Erwartet Status: %s, Erwartet Owner: %s)", dppID, recipientMSPID, dpp.Status, dpp.OwnerOrg, expectedStatus, recipientMSPID)
+	if dpp.OwnerOrg != recipientMSPID || dpp.IntendedRecipientMSP != recipientMSPID {
+		return fmt.Errorf("DPP %s ist nicht für Empfang durch %s vorgesehen (Status: %s, Owner: %s, Vorgesehener Empfänger: %s)", dppID, recipientMSPID, dpp.Status, dpp.OwnerOrg, dpp.IntendedRecipientMSP)
 	}
 
-	dpp.Status = "AcceptedAtRecipient"
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if err := dpp.transitionTo(ctx, clk, StatusAcceptedAtRecipient, fmt.Sprintf("Empfang durch %s bestätigt", recipientMSPID)); err != nil {
+		return err
+	}
+	dpp.IntendedRecipientMSP = ""
 	ackDisposition := "urn:epcglobal:cbv:disp:in_possession"
 
-	now := time.Now()
 	ackEvt := EPCISEvent{
-		EventID:             fmt.Sprintf("evt-recv-%s-%d", strings.ReplaceAll(dpp.GS1Key, ":", "_"), now.UnixNano()),
+		EventID:             clk.nextEventID("recv"),
 		EventType:           "ObjectEvent",
-		EventTime:           now.UTC().Format(time.RFC3339),
-		EventTimeZoneOffset: tzOffset(),
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
 		BizStep:             "urn:epcglobal:cbv:bizstep:receiving",
 		Action:              "ADD",
 		EPCList:             []string{dpp.GS1Key},
 		Disposition:         ackDisposition,
-		ReadPoint:           sgln(recipientGLN),
-		BizLocation:         sgln(recipientGLN),
+		ReadPoint:           siteLocation(recipientGLN),
+		BizLocation:         siteLocation(recipientGLN),
 		Extensions:          make(map[string]interface{}),
 	}
-	dpp.EPCISEvents = append(dpp.EPCISEvents, ackEvt)
+	dpp.addEvent(clk, ackEvt)
 
 	if incomingInspectionJSON != "" {
 		var inspQE QualityEntry
 		if errQE := json.Unmarshal([]byte(incomingInspectionJSON), &inspQE); errQE != nil {
 			fmt.Printf("[Acknowledge-WARN] incomingInspectionJSON für DPP %s fehlerhaft, wird ignoriert: %v\n", dppID, errQE)
 		} else {
+			inspQE.clearManagedFields()
 			if inspQE.Timestamp == "" {
-				inspQE.Timestamp = time.Now().UTC().Format(time.RFC3339)
+				inspQE.Timestamp = clk.timestamp()
 			}
 			if inspQE.PerformingOrg == "" {
 				// Bereits durch recipientMSPID oben ermittelt
 				inspQE.PerformingOrg = recipientMSPID
 			}
-			inspQE.EvaluationOutcome = "INCOMING_INSPECTION_DATA" // Beispiel, könnte auch bewertet werden
-			dpp.Quality = append(dpp.Quality, inspQE)
+			if errHash := anchorOffChainHash(&inspQE); errHash != nil {
+				return errHash
+			}
+			inspQE.EvaluationOutcome = OutcomeIncomingInspectionData // Beispiel, könnte auch bewertet werden
+			dpp.addQualityEntry(clk, &inspQE)
 
-			inspTime := time.Now()
 			inspEvent := EPCISEvent{
-				EventID:             fmt.Sprintf("evt-insp-%s-%s-%d", recipientMSPID, strings.ReplaceAll(dpp.GS1Key, ":", "_"), inspTime.UnixNano()),
+				EventID:             clk.nextEventID("insp"),
 				EventType:           "ObjectEvent",
-				EventTime:           inspTime.UTC().Format(time.RFC3339),
-				EventTimeZoneOffset: tzOffset(),
+				EventTime:           clk.timestamp(),
+				EventTimeZoneOffset: clk.tzOffset(),
 				BizStep:             "urn:epcglobal:cbv:bizstep:inspecting",
 				Action:              "OBSERVE",
 				EPCList:             []string{dpp.GS1Key},
 				Disposition:         "urn:epcglobal:cbv:disp:active",
-				ReadPoint:           sgln(recipientGLN),
-				BizLocation:         sgln(recipientGLN),
+				ReadPoint:           siteLocation(recipientGLN),
+				BizLocation:         siteLocation(recipientGLN),
 				Extensions:          map[string]interface{}{"inspectionDataByRecipient": inspQE},
 			}
-			dpp.EPCISEvents = append(dpp.EPCISEvents, inspEvent)
+			dpp.addEvent(clk, inspEvent)
 			// Ggf. dpp.recalculateOverallStatus() wenn die Inspektion mandatorisch war oder Specs hatte
 		}
 	}
 
-	updatedDppBytes, _ := json.Marshal(dpp)
-	return ctx.GetStub().PutState(dppPrefix+dppID, updatedDppBytes)
+	return c.saveDPP(ctx, dpp)
 }
 
-// QueryDPP: Liest den vollständigen DPP.
+// QueryDPP: Liest den vollständigen DPP (Kopf plus Qualitätseinträge und Events aus ihren Schlüsseln).
 func (c *DPPQualityContract) QueryDPP(ctx contractapi.TransactionContextInterface, dppID string) (*DPP, error) {
 	fmt.Printf("[QueryDPP-DEBUG] Query für dppID: %s\n", dppID)
-	dppBytes, err := ctx.GetStub().GetState(dppPrefix + dppID)
+	dpp, err := c.readDPP(ctx, dppID)
 	if err != nil {
-		fmt.Printf("[QueryDPP-ERROR] GetState für dppID %s fehlgeschlagen: %v\n", dppID, err)
+		fmt.Printf("[QueryDPP-ERROR] Lesen von DPP %s fehlgeschlagen: %v\n", dppID, err)
 		return nil, err
 	}
-	if dppBytes == nil {
-		fmt.Printf("[QueryDPP-ERROR] DPP %s nicht gefunden.\n", dppID)
-		return nil, fmt.Errorf("DPP %s nicht gefunden", dppID)
-	}
-	fmt.Printf("[QueryDPP-DEBUG] DPP %s gefunden, Bytes Länge: %d\n", dppID, len(dppBytes))
-
-	var dpp DPP
-	if errUnmarshal := json.Unmarshal(dppBytes, &dpp); errUnmarshal != nil {
-		fmt.Printf("[QueryDPP-ERROR] Fehler beim Unmarshalling von DPP %s: %v\n", dppID, errUnmarshal)
-		return nil, fmt.Errorf("Fehler beim Unmarshalling von DPP %s: %v", dppID, errUnmarshal)
-	}
-	fmt.Printf("[QueryDPP-DEBUG] DPP %s erfolgreich unmarshalled. OwnerOrg: %s, GS1Key: %s\n", dppID, dpp.OwnerOrg, dpp.GS1Key)
-	return &dpp, nil
+	fmt.Printf("[QueryDPP-DEBUG] DPP %s zusammengesetzt: %d Qualitätseinträge, %d Events. OwnerOrg: %s, GS1Key: %s\n", dppID, len(dpp.Quality), len(dpp.EPCISEvents), dpp.OwnerOrg, dpp.GS1Key)
+	return dpp, nil
 }
 
 // InitLedger: Kann für Testaufbau verwendet werden (optional).
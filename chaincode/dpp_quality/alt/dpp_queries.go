@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Rich Queries (CouchDB) --------------------------- //
+//
+// Abfragen über DPP-Köpfe mit GetQueryResultWithPagination. Sie funktionieren nur mit
+// CouchDB als State-DB und nur in evaluateTransaction (Paginierung ist in Update-
+// Transaktionen nicht erlaubt). Die passenden Indizes liegen unter
+// META-INF/statedb/couchdb/indexes und werden mit dem Chaincode installiert.
+//
+// Zurückgegeben werden nur DPP-Köpfe (ohne Quality/EPCISEvents); den vollständigen DPP
+// liefert QueryDPP. Status und Prüfzusammenfassungen werden wie bei QueryDPP aus den
+// Prüfständen abgeleitet (siehe dpp_checks.go).
+
+// dppDocType kennzeichnet DPP-Köpfe im State, damit Selektoren keine Qualitätseinträge,
+// Events oder Spezifikationssätze treffen.
+const dppDocType = "dpp"
+
+const maxQueryPageSize = 200
+
+// DPPQueryResult: Eine Seite einer Rich Query. Bookmark an die nächste Abfrage übergeben;
+// ist FetchedRecordsCount kleiner als pageSize, gibt es keine weiteren Seiten.
+type DPPQueryResult struct {
+	Records             []*DPP `json:"records"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// QueryDPPsByStatus: DPPs in einem Lebenszyklus-Status (z.B. "Blocked"). In den
+// Qualitätsphasen (und für Blocked) steht im Kopf nur der zuletzt übernommene Status; gesucht
+// wird dann über alle diese Status und nach dem abgeleiteten Status gefiltert. Records kann
+// deshalb weniger Einträge enthalten als FetchedRecordsCount, maßgeblich für weitere Seiten
+// bleibt FetchedRecordsCount.
+func (c *DPPQualityContract) QueryDPPsByStatus(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	wanted := DPPStatus(status)
+	if _, known := allowedTransitions[wanted]; !known || status == "" {
+		return nil, fmt.Errorf("unbekannter DPP-Status '%s'", status)
+	}
+	if !wanted.isQualityPhase() && wanted != StatusBlocked {
+		return c.queryDPPs(ctx, "status", status, pageSize, bookmark)
+	}
+	stored := []DPPStatus{StatusDraft, StatusAwaitingMandatoryChecks, StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations, StatusBlocked}
+	query := map[string]interface{}{
+		"selector":  map[string]interface{}{"docType": dppDocType, "status": map[string]interface{}{"$in": stored}},
+		"use_index": []string{"_design/indexStatusDoc", "indexStatus"},
+	}
+	result, err := c.runDPPQuery(ctx, query, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	matching := []*DPP{}
+	for _, dpp := range result.Records {
+		if dpp.Status == wanted {
+			matching = append(matching, dpp)
+		}
+	}
+	result.Records = matching
+	return result, nil
+}
+
+// QueryDPPsByOwner: DPPs, deren aktueller Eigentümer die angegebene MSP ist.
+func (c *DPPQualityContract) QueryDPPsByOwner(ctx contractapi.TransactionContextInterface, ownerMSP string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	return c.queryDPPs(ctx, "ownerOrg", ownerMSP, pageSize, bookmark)
+}
+
+// QueryDPPsByProductType: DPPs eines Produkttyps.
+func (c *DPPQualityContract) QueryDPPsByProductType(ctx contractapi.TransactionContextInterface, productTypeID string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	return c.queryDPPs(ctx, "productTypeId", productTypeID, pageSize, bookmark)
+}
+
+// QueryDPPsByBatch: DPPs einer Charge.
+func (c *DPPQualityContract) QueryDPPsByBatch(ctx contractapi.TransactionContextInterface, batch string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	return c.queryDPPs(ctx, "batch", batch, pageSize, bookmark)
+}
+
+// QueryDPPsByManufacturer: DPPs eines Herstellers (GLN oder SGLN-URN).
+func (c *DPPQualityContract) QueryDPPsByManufacturer(ctx contractapi.TransactionContextInterface, manufacturerGLN string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	gln, err := normalizeGLN("manufacturerGLN", manufacturerGLN)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryDPPs(ctx, "manufacturerGln", gln, pageSize, bookmark)
+}
+
+// QueryDPPsByProductionDateRange: DPPs mit fromDate <= productionDate <= toDate, aufsteigend
+// sortiert. Vergleich als String, daher Datumsangaben im Format YYYY-MM-DD übergeben.
+// Eine leere Grenze ist offen.
+func (c *DPPQualityContract) QueryDPPsByProductionDateRange(ctx contractapi.TransactionContextInterface, fromDate, toDate string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	if fromDate == "" && toDate == "" {
+		return nil, fmt.Errorf("mindestens fromDate oder toDate muss angegeben werden")
+	}
+	if fromDate != "" && toDate != "" && fromDate > toDate {
+		return nil, fmt.Errorf("fromDate %s liegt nach toDate %s", fromDate, toDate)
+	}
+	dateRange := map[string]string{}
+	if fromDate != "" {
+		dateRange["$gte"] = fromDate
+	}
+	if toDate != "" {
+		dateRange["$lte"] = toDate
+	}
+	query := map[string]interface{}{
+		"selector":  map[string]interface{}{"docType": dppDocType, "productionDate": dateRange},
+		"sort":      []map[string]string{{"docType": "asc"}, {"productionDate": "asc"}},
+		"use_index": []string{"_design/indexProductionDateDoc", "indexProductionDate"},
+	}
+	return c.runDPPQuery(ctx, query, pageSize, bookmark)
+}
+
+// queryDPPs: Gleichheitsabfrage auf ein Feld des DPP-Kopfs. Die Index-Namen folgen dem
+// Schema index<Feld>Doc / index<Feld> (siehe META-INF/statedb/couchdb/indexes).
+func (c *DPPQualityContract) queryDPPs(ctx contractapi.TransactionContextInterface, field, value string, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	if value == "" {
+		return nil, fmt.Errorf("Suchwert für %s darf nicht leer sein", field)
+	}
+	index := map[string]string{
+		"status":          "Status",
+		"ownerOrg":        "OwnerOrg",
+		"productTypeId":   "ProductType",
+		"batch":           "Batch",
+		"manufacturerGln": "Manufacturer",
+	}[field]
+	query := map[string]interface{}{
+		"selector":  map[string]interface{}{"docType": dppDocType, field: value},
+		"use_index": []string{"_design/index" + index + "Doc", "index" + index},
+	}
+	return c.runDPPQuery(ctx, query, pageSize, bookmark)
+}
+
+func (c *DPPQualityContract) runDPPQuery(ctx contractapi.TransactionContextInterface, query map[string]interface{}, pageSize int32, bookmark string) (*DPPQueryResult, error) {
+	if pageSize <= 0 || pageSize > maxQueryPageSize {
+		return nil, fmt.Errorf("pageSize muss zwischen 1 und %d liegen, erhalten: %d", maxQueryPageSize, pageSize)
+	}
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Erzeugen der Abfrage: %v", err)
+	}
+	fmt.Printf("[runDPPQuery-DEBUG] Query: %s, pageSize=%d, bookmark=%s\n", queryBytes, pageSize, bookmark)
+
+	iter, meta, err := ctx.GetStub().GetQueryResultWithPagination(string(queryBytes), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler bei der Rich Query: %v", err)
+	}
+	defer iter.Close()
+
+	result := &DPPQueryResult{Records: []*DPP{}}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var dpp DPP
+		if err := json.Unmarshal(kv.Value, &dpp); err != nil {
+			return nil, fmt.Errorf("Fehler beim Unmarshalling von %s: %v", kv.Key, err)
+		}
+		dpp.migrateLegacyStatus()
+		dpp.ensureDigitalLink()
+		dpp.migrateLegacyChecks()
+		if err := c.deriveStatus(ctx, &dpp); err != nil {
+			return nil, err
+		}
+		result.Records = append(result.Records, &dpp)
+	}
+	result.FetchedRecordsCount = meta.GetFetchedRecordsCount()
+	result.Bookmark = meta.GetBookmark()
+	return result, nil
+}
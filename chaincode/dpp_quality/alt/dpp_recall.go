@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Rückruf (Recall) --------------------------- //
+//
+// Ein Rückruf wird auf einem DPP ausgelöst und über OutputDPPIDs auf alle nachgelagerten
+// DPPs übertragen. Der Rückruf-Status ist unabhängig vom Lebenszyklus-Status: Auch bereits
+// verbrauchte oder ausgelieferte DPPs werden markiert. Solange ein Rückruf offen ist, kann
+// der DPP weder versendet noch in einer Transformation verbraucht werden.
+
+// RecallSeverity: Schweregrad eines Rückrufs.
+type RecallSeverity string
+
+const (
+	RecallSeverityLow      RecallSeverity = "LOW"
+	RecallSeverityMedium   RecallSeverity = "MEDIUM"
+	RecallSeverityHigh     RecallSeverity = "HIGH"
+	RecallSeverityCritical RecallSeverity = "CRITICAL"
+)
+
+// RecallStatus: Offen bis CloseRecall.
+type RecallStatus string
+
+const (
+	RecallOpen   RecallStatus = "Open"
+	RecallClosed RecallStatus = "Closed"
+)
+
+// DPPRecallState: Rückruf-Markierung auf einem betroffenen DPP.
+type DPPRecallState struct {
+	RecallID    string         `json:"recallId"`
+	Status      RecallStatus   `json:"status"`
+	Severity    RecallSeverity `json:"severity"`
+	Reason      string         `json:"reason"`
+	OriginDPPID string         `json:"originDppId"`
+	InitiatedAt string         `json:"initiatedAt"`
+	Resolution  string         `json:"resolution,omitempty" metadata:",optional"`
+	ClosedAt    string         `json:"closedAt,omitempty"   metadata:",optional"`
+}
+
+// AffectedDPP: Ein vom Rückruf betroffener DPP mit Eigentümer zum Zeitpunkt des Rückrufs.
+type AffectedDPP struct {
+	DppID    string    `json:"dppId"`
+	GS1Key   string    `json:"gs1Key"`
+	OwnerOrg string    `json:"ownerOrg"`
+	Status   DPPStatus `json:"status"`
+	Depth    int       `json:"depth"`
+}
+
+// Recall: Rückruf-Datensatz unter Recall~<recallId>.
+type Recall struct {
+	RecallID       string         `json:"recallId"`
+	OriginDPPID    string         `json:"originDppId"`
+	Severity       RecallSeverity `json:"severity"`
+	Reason         string         `json:"reason"`
+	Status         RecallStatus   `json:"status"`
+	AffectedDPPs   []AffectedDPP  `json:"affectedDpps"`
+	InitiatedByMSP string         `json:"initiatedByMsp"`
+	InitiatedAt    string         `json:"initiatedAt"`
+	InitiatedTxID  string         `json:"initiatedTxId"`
+	Resolution     string         `json:"resolution,omitempty"  metadata:",optional"`
+	ClosedByMSP    string         `json:"closedByMsp,omitempty" metadata:",optional"`
+	ClosedAt       string         `json:"closedAt,omitempty"    metadata:",optional"`
+	ClosedTxID     string         `json:"closedTxId,omitempty"  metadata:",optional"`
+}
+
+const recallObjectType = "Recall"
+
+func parseRecallSeverity(s string) (RecallSeverity, error) {
+	switch sev := RecallSeverity(strings.ToUpper(strings.TrimSpace(s))); sev {
+	case RecallSeverityLow, RecallSeverityMedium, RecallSeverityHigh, RecallSeverityCritical:
+		return sev, nil
+	}
+	return "", fmt.Errorf("ungültiger Schweregrad '%s' (erlaubt: LOW, MEDIUM, HIGH, CRITICAL)", s)
+}
+
+// checkNoOpenRecall: Fehler, wenn der DPP durch einen offenen Rückruf gesperrt ist.
+func (dpp *DPP) checkNoOpenRecall(action string) error {
+	for _, r := range dpp.Recalls {
+		if r.Status == RecallOpen {
+			return fmt.Errorf("DPP %s ist von Rückruf %s betroffen (%s) und kann nicht %s werden", dpp.DppID, r.RecallID, r.Severity, action)
+		}
+	}
+	return nil
+}
+
+func (c *DPPQualityContract) getRecall(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(recallObjectType, []string{recallID})
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen von Rückruf %s: %v", recallID, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("Rückruf %s nicht gefunden", recallID)
+	}
+	var recall Recall
+	if err := json.Unmarshal(data, &recall); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling von Rückruf %s: %v", recallID, err)
+	}
+	return &recall, nil
+}
+
+func (c *DPPQualityContract) putRecall(ctx contractapi.TransactionContextInterface, recall *Recall) error {
+	key, err := ctx.GetStub().CreateCompositeKey(recallObjectType, []string{recall.RecallID})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(recall)
+	if err != nil {
+		return fmt.Errorf("Fehler beim Marshalling von Rückruf %s: %v", recall.RecallID, err)
+	}
+	return ctx.GetStub().PutState(key, data)
+}
+
+// QueryRecall: Liest einen Rückruf mit allen betroffenen DPPs.
+func (c *DPPQualityContract) QueryRecall(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	return c.getRecall(ctx, recallID)
+}
+
+// InitiateRecall: Markiert den DPP und alle nachgelagerten DPPs als zurückgerufen, erzeugt je
+// ein EPCIS-Event und ein Chaincode-Event "RecallInitiated" mit allen Betroffenen.
+// Auslösen darf der Hersteller (Anleger) des DPP oder sein aktueller Eigentümer.
+func (c *DPPQualityContract) InitiateRecall(ctx contractapi.TransactionContextInterface, dppID, reason, severity string) (*Recall, error) {
+	fmt.Printf("[InitiateRecall-DEBUG] Entry: dppID=%s, severity=%s, reason=%s\n", dppID, severity, reason)
+	sev, err := parseRecallSeverity(severity)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("Rückruf für DPP %s ohne Begründung", dppID)
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+
+	origin, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	if clientMSPID != origin.OwnerOrg && clientMSPID != origin.creatorMSP() {
+		return nil, fmt.Errorf("Rückruf für DPP %s darf nur vom Hersteller (%s) oder Eigentümer (%s) ausgelöst werden, Aufrufer ist %s", dppID, origin.creatorMSP(), origin.OwnerOrg, clientMSPID)
+	}
+
+	graph, err := c.traceGenealogy(ctx, dppID, maxTraceDepth, "downstream")
+	if err != nil {
+		return nil, err
+	}
+	if graph.Truncated {
+		return nil, fmt.Errorf("Genealogie von DPP %s ist tiefer als %d Stufen, Rückruf nicht vollständig möglich", dppID, maxTraceDepth)
+	}
+
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recall := &Recall{
+		RecallID:       "RCL-" + clk.txID,
+		OriginDPPID:    dppID,
+		Severity:       sev,
+		Reason:         reason,
+		Status:         RecallOpen,
+		AffectedDPPs:   []AffectedDPP{},
+		InitiatedByMSP: clientMSPID,
+		InitiatedAt:    clk.timestamp(),
+		InitiatedTxID:  clk.txID,
+	}
+
+	for _, node := range graph.Nodes {
+		dpp, err := c.readDPPHeader(ctx, node.DppID)
+		if err != nil {
+			return nil, err
+		}
+		dpp.Recalls = append(dpp.Recalls, DPPRecallState{
+			RecallID:    recall.RecallID,
+			Status:      RecallOpen,
+			Severity:    sev,
+			Reason:      reason,
+			OriginDPPID: dppID,
+			InitiatedAt: clk.timestamp(),
+		})
+		dpp.addEvent(clk, EPCISEvent{
+			EventID:             clk.nextEventID("recall"),
+			EventType:           "ObjectEvent",
+			EventTime:           clk.timestamp(),
+			EventTimeZoneOffset: clk.tzOffset(),
+			BizStep:             "urn:epcglobal:cbv:bizstep:holding",
+			Action:              "OBSERVE",
+			EPCList:             []string{dpp.GS1Key},
+			Disposition:         "urn:epcglobal:cbv:disp:recalled",
+			Extensions: map[string]interface{}{
+				"recallId":    recall.RecallID,
+				"originDppId": dppID,
+				"severity":    sev,
+				"reason":      reason,
+			},
+		})
+		if err := c.saveDPP(ctx, dpp); err != nil {
+			return nil, err
+		}
+		recall.AffectedDPPs = append(recall.AffectedDPPs, AffectedDPP{DppID: dpp.DppID, GS1Key: dpp.GS1Key, OwnerOrg: dpp.OwnerOrg, Status: node.Status, Depth: node.Depth})
+	}
+
+	if err := c.putRecall(ctx, recall); err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(recall)
+	if err := ctx.GetStub().SetEvent("RecallInitiated", payload); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[InitiateRecall-INFO] Rückruf %s für DPP %s ausgelöst, %d DPPs betroffen.\n", recall.RecallID, dppID, len(recall.AffectedDPPs))
+	return recall, nil
+}
+
+// CloseRecall: Schließt einen offenen Rückruf auf allen betroffenen DPPs und hält fest,
+// wie er gelöst wurde. Nur die MSP, die den Rückruf ausgelöst hat, darf ihn schließen.
+func (c *DPPQualityContract) CloseRecall(ctx contractapi.TransactionContextInterface, recallID, resolution string) (*Recall, error) {
+	fmt.Printf("[CloseRecall-DEBUG] Entry: recallID=%s\n", recallID)
+	if strings.TrimSpace(resolution) == "" {
+		return nil, fmt.Errorf("Abschluss von Rückruf %s ohne Beschreibung der Lösung", recallID)
+	}
+	recall, err := c.getRecall(ctx, recallID)
+	if err != nil {
+		return nil, err
+	}
+	if recall.Status != RecallOpen {
+		return nil, fmt.Errorf("Rückruf %s ist bereits geschlossen", recallID)
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if clientMSPID != recall.InitiatedByMSP {
+		return nil, fmt.Errorf("Rückruf %s darf nur von %s geschlossen werden, Aufrufer ist %s", recallID, recall.InitiatedByMSP, clientMSPID)
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, affected := range recall.AffectedDPPs {
+		dpp, err := c.readDPPHeader(ctx, affected.DppID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range dpp.Recalls {
+			if dpp.Recalls[i].RecallID == recallID {
+				dpp.Recalls[i].Status = RecallClosed
+				dpp.Recalls[i].Resolution = resolution
+				dpp.Recalls[i].ClosedAt = clk.timestamp()
+			}
+		}
+		dpp.addEvent(clk, EPCISEvent{
+			EventID:             clk.nextEventID("recall-close"),
+			EventType:           "ObjectEvent",
+			EventTime:           clk.timestamp(),
+			EventTimeZoneOffset: clk.tzOffset(),
+			BizStep:             "urn:epcglobal:cbv:bizstep:holding",
+			Action:              "OBSERVE",
+			EPCList:             []string{dpp.GS1Key},
+			Extensions:          map[string]interface{}{"recallId": recallID, "resolution": resolution},
+		})
+		if err := c.saveDPP(ctx, dpp); err != nil {
+			return nil, err
+		}
+	}
+
+	recall.Status = RecallClosed
+	recall.Resolution = resolution
+	recall.ClosedByMSP = clientMSPID
+	recall.ClosedAt = clk.timestamp()
+	recall.ClosedTxID = clk.txID
+	if err := c.putRecall(ctx, recall); err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(recall)
+	if err := ctx.GetStub().SetEvent("RecallClosed", payload); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[CloseRecall-INFO] Rückruf %s geschlossen: %s\n", recallID, resolution)
+	return recall, nil
+}
+
+// creatorMSP: Die MSP, die den DPP angelegt hat (erster Eintrag der Statushistorie).
+func (dpp *DPP) creatorMSP() string {
+	if len(dpp.StatusHistory) > 0 {
+		return dpp.StatusHistory[0].ChangedByMSP
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Retest und Entsperrung --------------------------- //
+//
+// Ein FAIL oder INVALID_FORMAT (z.B. Tippfehler, Laborfehler) wird nicht gelöscht, sondern mit
+// RecordRetest durch einen neuen Eintrag ersetzt. Beide Einträge bleiben gespeichert und sind
+// über Supersedes/SupersededBy verknüpft. Für den Prüfstand des Tests zählt nur der Retest
+// als jüngster gültiger Eintrag; Sonderfreigaben des Tests werden ersetzt. Ein gesperrter DPP
+// (Blocked) bleibt gesperrt, bis die QA des Eigentümers ihn mit ReleaseBlockedDPP freigibt.
+
+// findQualityEntry liest einen Qualitätseintrag; migrierte Alt-Einträge stehen noch in dpp.Quality.
+func findQualityEntry(ctx contractapi.TransactionContextInterface, dpp *DPP, entryID string) (*QualityEntry, error) {
+	for _, qe := range dpp.Quality {
+		if qe.EntryID == entryID {
+			entry := qe
+			return &entry, nil
+		}
+	}
+	return readQualityEntry(ctx, dpp.DppID, entryID)
+}
+
+// RecordRetest: Erfasst eine Wiederholungsprüfung, die den nicht konformen Eintrag
+// originalEntryRef ersetzt. qualityEntryJSON leer = vertraulicher Eintrag aus dem Transient
+// Field "qualityEntry" (wie RecordQualityData).
+func (c *DPPQualityContract) RecordRetest(ctx contractapi.TransactionContextInterface, dppID, originalEntryRef, qualityEntryJSON, reason string) error {
+	fmt.Printf("[RecordRetest-DEBUG] Entry: dppID=%s, originalEntryRef=%s\n", dppID, originalEntryRef)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	clientMSPID, err := requireOwner(ctx, dpp, "Wiederholungsprüfungen erfassen")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("reason darf nicht leer sein")
+	}
+	if !dpp.Status.isQualityPhase() && dpp.Status != StatusBlocked {
+		return fmt.Errorf("für DPP %s im Status %s können keine Wiederholungsprüfungen erfasst werden", dppID, dpp.Status)
+	}
+
+	original, err := findQualityEntry(ctx, dpp, originalEntryRef)
+	if err != nil {
+		return err
+	}
+	if original.SupersededBy != "" {
+		return fmt.Errorf("Eintrag %s wurde bereits durch %s ersetzt", originalEntryRef, original.SupersededBy)
+	}
+	if !original.EvaluationOutcome.isNonConformant() {
+		return fmt.Errorf("nur nicht konforme Einträge können ersetzt werden, Eintrag %s ist %s", originalEntryRef, original.EvaluationOutcome)
+	}
+
+	var qe QualityEntry
+	var private *privateSubmission
+	if qualityEntryJSON == "" {
+		if private, err = readPrivateSubmission(ctx, dpp, clientMSPID); err != nil {
+			return err
+		}
+		qe = private.entry
+	} else if err := json.Unmarshal([]byte(qualityEntryJSON), &qe); err != nil {
+		return fmt.Errorf("QualityEntry JSON fehlerhaft: %v", err)
+	}
+	if qe.TestName == "" {
+		qe.TestName = original.TestName
+	}
+	if qe.TestName != original.TestName {
+		return fmt.Errorf("Retest für Test '%s' kann Eintrag %s (Test '%s') nicht ersetzen", qe.TestName, originalEntryRef, original.TestName)
+	}
+	if err := anchorOffChainHash(&qe); err != nil {
+		return err
+	}
+
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if qe.Timestamp == "" {
+		qe.Timestamp = clk.timestamp()
+	}
+	if qe.PerformingOrg == "" {
+		qe.PerformingOrg = clientMSPID
+	}
+	qe.clearManagedFields()
+	qe.Supersedes = original.EntryID
+	qe.RetestReason = reason
+
+	// Mit Supersedes setzt recordEvaluatedEntry den Prüfstand des Tests zurück.
+	if private != nil {
+		if err := c.recordPrivateQualityEntry(ctx, clk, dpp, private, &qe); err != nil {
+			return err
+		}
+	} else if _, err := dpp.applyQualityEntry(ctx, clk, &qe); err != nil {
+		return err
+	}
+	original.SupersededBy = qe.EntryID
+	dpp.updateQualityEntry(*original)
+
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("retest-" + strings.ReplaceAll(strings.ReplaceAll(qe.TestName, " ", "_"), "/", "_")),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:inspecting",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         qe.EvaluationOutcome.disposition(),
+		SensorElementList:   sensorElements(qualitySensorElement(dpp.specFor(qe.TestName), qe)),
+		Extensions:          map[string]interface{}{"recordedQualityData": qe, "supersedes": original.EntryID, "retestReason": reason},
+	})
+
+	if qe.EvaluationOutcome.isNonConformant() {
+		emitQualityAlert(ctx, dpp, qe)
+	}
+
+	fmt.Printf("[RecordRetest-INFO] Eintrag %s von DPP %s durch %s ersetzt (%s).\n", original.EntryID, dppID, qe.EntryID, qe.EvaluationOutcome)
+	return c.saveDPP(ctx, dpp)
+}
+
+// ReleaseBlockedDPP: Die QA des Eigentümers hebt die Sperre eines DPP auf. Der neue Status wird
+// aus den aktuellen Qualitätsdaten abgeleitet; kritische Fehler und abgelehnte Sonderfreigaben
+// müssen vorher durch Retests ersetzt sein.
+func (c *DPPQualityContract) ReleaseBlockedDPP(ctx contractapi.TransactionContextInterface, dppID, comment string) error {
+	fmt.Printf("[ReleaseBlockedDPP-DEBUG] Entry: dppID=%s\n", dppID)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	clientMSPID, err := requireOwner(ctx, dpp, "die Sperre aufheben")
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ctx, "qa", "Aufheben einer Sperre"); err != nil {
+		return err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.syncStatus(ctx, clk, dpp); err != nil {
+		return err
+	}
+	if dpp.Status != StatusBlocked {
+		return fmt.Errorf("DPP %s ist nicht gesperrt (Status: %s)", dppID, dpp.Status)
+	}
+	if strings.TrimSpace(comment) == "" {
+		return fmt.Errorf("comment darf nicht leer sein")
+	}
+	if len(dpp.FailedTests) > 0 {
+		return fmt.Errorf("DPP %s hat weiterhin kritische Ergebnisse %v, zuerst RecordRetest", dppID, dpp.FailedTests)
+	}
+	if dpp.hasDeniedConcession() {
+		return fmt.Errorf("DPP %s hat abgelehnte Sonderfreigaben für %v, zuerst RecordRetest", dppID, dpp.DeviationTests)
+	}
+
+	for _, name := range dpp.checkNames() {
+		if check := dpp.checks[name]; check.Blocking {
+			check.Blocking = false
+			dpp.queueCheck(check)
+		}
+	}
+	target := dpp.derivedQualityStatus()
+	if err := dpp.transitionTo(ctx, clk, target, fmt.Sprintf("Sperre durch QA von %s aufgehoben: %s", clientMSPID, comment)); err != nil {
+		return err
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"dppId":         dppID,
+		"gs1Key":        dpp.GS1Key,
+		"status":        dpp.Status,
+		"releasedByMsp": clientMSPID,
+		"comment":       comment,
+	})
+	if err := ctx.GetStub().SetEvent("DPPUnblocked", payload); err != nil {
+		return fmt.Errorf("Fehler beim Setzen des DPPUnblocked-Events: %v", err)
+	}
+	fmt.Printf("[ReleaseBlockedDPP-INFO] Sperre von DPP %s aufgehoben, neuer Status %s.\n", dppID, dpp.Status)
+	return c.saveDPP(ctx, dpp)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// retestSpecs: MFI ist Pflichtprüfung, Dichte nicht; Werte außerhalb der Grenzen sind Abweichungen.
+const retestSpecs = `[
+	{"testName":"MFI","isNumeric":true,"lowerLimit":10,"upperLimit":20,"unit":"g/10min","isMandatory":true},
+	{"testName":"Dichte","isNumeric":true,"lowerLimit":0.9,"upperLimit":1.1,"unit":"g/cm3","isMandatory":false}
+]`
+
+func qualityJSON(testName, result, unit string) string {
+	return fmt.Sprintf(`{"testName":%q,"result":%q,"unit":%q,"systemId":"LIMS","responsible":"Labor"}`, testName, result, unit)
+}
+
+// latestEntry: jüngster noch nicht ersetzter Eintrag eines Tests.
+func latestEntry(t *testing.T, dpp *DPP, testName string) QualityEntry {
+	t.Helper()
+	for i := len(dpp.Quality) - 1; i >= 0; i-- {
+		if qe := dpp.Quality[i]; qe.TestName == testName && qe.SupersededBy == "" {
+			return qe
+		}
+	}
+	t.Fatalf("kein Eintrag für Test %s", testName)
+	return QualityEntry{}
+}
+
+func TestRecordRetestFromQualityPhaseStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      []string // Einträge als "Test=Wert"
+		concession bool     // Sonderfreigabe für Dichte genehmigen
+		from       DPPStatus
+		retest     string
+		value      string
+		want       DPPStatus
+	}{
+		{"offene Pflichtprüfung, Retest PASS", []string{"Dichte=1.5"}, false, StatusAwaitingMandatoryChecks, "Dichte", "1.0", StatusAwaitingMandatoryChecks},
+		{"offene Pflichtprüfung, Retest weiter abweichend", []string{"Dichte=1.5"}, false, StatusAwaitingMandatoryChecks, "Dichte", "1.6", StatusAwaitingMandatoryChecks},
+		{"Sonderfreigabe ausstehend, Retest PASS", []string{"MFI=15", "Dichte=1.5"}, false, StatusAwaitingConcession, "Dichte", "1.0", StatusReleased},
+		{"Sonderfreigabe ausstehend, Retest weiter abweichend", []string{"MFI=15", "Dichte=1.5"}, false, StatusAwaitingConcession, "Dichte", "1.6", StatusAwaitingConcession},
+		{"mit Abweichungen freigegeben, Retest PASS", []string{"MFI=15", "Dichte=1.5"}, true, StatusReleasedWithDeviations, "Dichte", "1.0", StatusReleased},
+		{"mit Abweichungen freigegeben, Retest weiter abweichend", []string{"MFI=15", "Dichte=1.5"}, true, StatusReleasedWithDeviations, "Dichte", "1.6", StatusAwaitingConcession},
+		{"gesperrt, Retest PASS", []string{"MFI=1x5"}, false, StatusBlocked, "MFI", "15", StatusBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			c := &DPPQualityContract{}
+			_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-R", retestSpecs, "", "")
+			e.must(err)
+			_, err = c.CreateDPP(e.ctx("Org1MSP"), "R1", "urn:epc:id:sgtin:4012345.011111.1001", "PT-R", "4012345000016", "L1", "2025-06-01", 25000, "kg", 0)
+			e.must(err)
+			units := map[string]string{"MFI": "g/10min", "Dichte": "g/cm3"}
+			for _, entry := range tt.setup {
+				testName, value, _ := strings.Cut(entry, "=")
+				e.must(c.RecordQualityData(e.ctx("Org1MSP"), "R1", qualityJSON(testName, value, units[testName]), ""))
+			}
+			if tt.concession {
+				e.must(c.SetIntendedCustomer(e.ctx("Org1MSP"), "R1", "Org3MSP"))
+				_, err = c.RequestConcession(e.ctx("Org1MSP"), "R1", "Dichte", "für Spritzguss unkritisch")
+				e.must(err)
+				_, err = c.ApproveConcession(e.ctx("Org3MSP"), "R1", "Dichte", "akzeptiert")
+				e.must(err)
+			}
+
+			dpp, err := c.QueryDPP(e.ctx("Org1MSP"), "R1")
+			e.must(err)
+			if dpp.Status != tt.from {
+				t.Fatalf("Ausgangsstatus %s, erwartet %s", dpp.Status, tt.from)
+			}
+			original := latestEntry(t, dpp, tt.retest)
+			if err := c.RecordRetest(e.ctx("Org1MSP"), "R1", original.EntryID, qualityJSON(tt.retest, tt.value, units[tt.retest]), "Laborfehler"); err != nil {
+				t.Fatalf("RecordRetest aus %s: %v", tt.from, err)
+			}
+
+			dpp, err = c.QueryDPP(e.ctx("Org1MSP"), "R1")
+			e.must(err)
+			if dpp.Status != tt.want {
+				t.Errorf("Status nach Retest %s, erwartet %s", dpp.Status, tt.want)
+			}
+			if retest := latestEntry(t, dpp, tt.retest); retest.Supersedes != original.EntryID {
+				t.Errorf("Retest ersetzt %q, erwartet %q", retest.Supersedes, original.EntryID)
+			}
+		})
+	}
+}
+
+// TestRecalculateFromEveryQualityPhaseStatus: Aus jedem Status der Qualitätsphase ist jeder
+// Status erreichbar, den ein Retest über derivedQualityStatus ergeben kann.
+func TestRecalculateFromEveryQualityPhaseStatus(t *testing.T) {
+	summaries := map[DPPStatus]DPP{
+		StatusAwaitingMandatoryChecks: {OpenMandatoryChecks: []string{"MFI"}},
+		StatusAwaitingConcession:      {DeviationTests: []string{"Dichte"}},
+		StatusReleased:                {},
+		StatusReleasedWithDeviations:  {DeviationTests: []string{"Dichte"}, Concessions: []Concession{{TestName: "Dichte", Status: ConcessionApproved}}},
+		StatusBlocked:                 {FailedTests: []string{"MFI"}},
+	}
+	froms := []DPPStatus{StatusDraft, StatusAwaitingMandatoryChecks, StatusAwaitingConcession, StatusReleased, StatusReleasedWithDeviations}
+	for _, from := range froms {
+		for target, summary := range summaries {
+			e := newTestEnv(t)
+			ctx := e.ctx("Org1MSP")
+			clk, err := newTxClock(ctx)
+			e.must(err)
+			dpp := summary
+			dpp.DppID, dpp.Status = "R1", from
+			if got := dpp.derivedQualityStatus(); got != target {
+				t.Fatalf("Zusammenfassung für %s ergibt %s", target, got)
+			}
+			if err := dpp.recalculateOverallStatus(ctx, clk, "Retest"); err != nil {
+				t.Errorf("%s -> %s: %v", from, target, err)
+				continue
+			}
+			want := target
+			if target == StatusAwaitingMandatoryChecks && from.isReleased() {
+				want = from // freigegebene DPPs bekommen keine offenen Pflichtprüfungen mehr
+			}
+			if dpp.Status != want {
+				t.Errorf("%s -> %s: Status %s, erwartet %s", from, target, dpp.Status, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Ablehnung und Rücksendung --------------------------- //
+//
+// Der vorgesehene Empfänger kann eine Lieferung statt AcknowledgeReceiptAndRecordInspection
+// mit RejectDelivery ablehnen. Das Eigentum geht an den Versender zurück und der DPP ist als
+// Rücksendung unterwegs (ReturnInTransit), bis der Versender sie mit AcknowledgeReturn
+// bestätigt (Returned). Die Rückware gilt als nicht konform und wird in derselben Transaktion
+// gesperrt (Blocked); QA gibt sie nach RecordRetest mit ReleaseBlockedDPP wieder frei.
+
+// Ablehnungsgründe für RejectDelivery.
+var rejectionReasonCodes = map[string]string{
+	"QUALITY_NONCONFORMANT": "Eingangsprüfung nicht bestanden",
+	"TRANSPORT_DAMAGE":      "Beschädigung beim Transport",
+	"TRANSPORT_EXCURSION":   "Transportbedingungen überschritten",
+	"WRONG_PRODUCT":         "Falsches Produkt geliefert",
+	"QUANTITY_MISMATCH":     "Menge weicht von der Bestellung ab",
+	"DOCUMENTATION_MISSING": "Begleitdokumente fehlen",
+	"OTHER":                 "Sonstiger Grund",
+}
+
+// DeliveryRejection: Abgelehnte Lieferung und Stand der Rücksendung.
+type DeliveryRejection struct {
+	RejectedByMSP          string `json:"rejectedByMsp"`
+	ReturnToMSP            string `json:"returnToMsp"`
+	ReasonCode             string `json:"reasonCode"`
+	Reason                 string `json:"reason"`
+	InspectionEntryID      string `json:"inspectionEntryId,omitempty"      metadata:",optional"`
+	RejectedAt             string `json:"rejectedAt"`
+	RejectedTxID           string `json:"rejectedTxId"`
+	ReturnAcknowledgedAt   string `json:"returnAcknowledgedAt,omitempty"   metadata:",optional"`
+	ReturnAcknowledgedTxID string `json:"returnAcknowledgedTxId,omitempty" metadata:",optional"`
+}
+
+// RejectDelivery: Der vorgesehene Empfänger lehnt eine Lieferung ab. inspectionJSON ist optional
+// (QualityEntry der Eingangsprüfung, die zur Ablehnung geführt hat).
+func (c *DPPQualityContract) RejectDelivery(ctx contractapi.TransactionContextInterface, dppID, reasonCode, inspectionJSON string) error {
+	fmt.Printf("[RejectDelivery-DEBUG] Entry: dppID=%s, reasonCode=%s\n", dppID, reasonCode)
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	recipientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if dpp.Status != StatusInTransit || dpp.OwnerOrg != recipientMSPID || dpp.IntendedRecipientMSP != recipientMSPID {
+		return fmt.Errorf("DPP %s ist nicht für Empfang durch %s vorgesehen (Status: %s, Owner: %s, Vorgesehener Empfänger: %s)", dppID, recipientMSPID, dpp.Status, dpp.OwnerOrg, dpp.IntendedRecipientMSP)
+	}
+	reason, ok := rejectionReasonCodes[reasonCode]
+	if !ok {
+		codes := make([]string, 0, len(rejectionReasonCodes))
+		for code := range rejectionReasonCodes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return fmt.Errorf("unbekannter reasonCode '%s' (erlaubt: [%s])", reasonCode, strings.Join(codes, ", "))
+	}
+	shipperMSPID := dpp.shipperMSP()
+	if shipperMSPID == "" || shipperMSPID == recipientMSPID {
+		return fmt.Errorf("Versender von DPP %s nicht ermittelbar, Rücksendung nicht möglich", dppID)
+	}
+
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	rejection := DeliveryRejection{
+		RejectedByMSP: recipientMSPID,
+		ReturnToMSP:   shipperMSPID,
+		ReasonCode:    reasonCode,
+		Reason:        reason,
+		RejectedAt:    clk.timestamp(),
+		RejectedTxID:  clk.txID,
+	}
+
+	recvExtensions := map[string]interface{}{"rejectionReasonCode": reasonCode, "rejectionReason": reason}
+	if inspectionJSON != "" {
+		var inspQE QualityEntry
+		if err := json.Unmarshal([]byte(inspectionJSON), &inspQE); err != nil {
+			return fmt.Errorf("inspectionJSON fehlerhaft: %v", err)
+		}
+		inspQE.clearManagedFields()
+		if inspQE.Timestamp == "" {
+			inspQE.Timestamp = clk.timestamp()
+		}
+		if inspQE.PerformingOrg == "" {
+			inspQE.PerformingOrg = recipientMSPID
+		}
+		if err := anchorOffChainHash(&inspQE); err != nil {
+			return err
+		}
+		inspQE.EvaluationOutcome = OutcomeIncomingInspectionData
+		dpp.addQualityEntry(clk, &inspQE)
+		rejection.InspectionEntryID = inspQE.EntryID
+		recvExtensions["inspectionDataByRecipient"] = inspQE
+	}
+
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("reject"),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:receiving",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         "urn:epcglobal:cbv:disp:non_conformant",
+		Extensions:          recvExtensions,
+	})
+	if err := dpp.transitionTo(ctx, clk, StatusReturnInTransit, fmt.Sprintf("Lieferung von %s abgelehnt (%s), Rücksendung an %s", recipientMSPID, reasonCode, shipperMSPID)); err != nil {
+		return err
+	}
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("return"),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:shipping",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         "urn:epcglobal:cbv:disp:in_transit",
+		Extensions:          map[string]interface{}{"intendedRecipientMSP": shipperMSPID, "returnShipment": true},
+	})
+	dpp.OwnerOrg = shipperMSPID
+	dpp.IntendedRecipientMSP = shipperMSPID
+	dpp.Rejections = append(dpp.Rejections, rejection)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"dppId":         dppID,
+		"gs1Key":        dpp.GS1Key,
+		"rejectedByMsp": recipientMSPID,
+		"returnToMsp":   shipperMSPID,
+		"reasonCode":    reasonCode,
+		"timestamp":     rejection.RejectedAt,
+	})
+	if err := ctx.GetStub().SetEvent("DeliveryRejected", payload); err != nil {
+		return fmt.Errorf("Fehler beim Setzen des DeliveryRejected-Events: %v", err)
+	}
+	fmt.Printf("[RejectDelivery-INFO] DPP %s von %s abgelehnt (%s), Rücksendung an %s.\n", dppID, recipientMSPID, reasonCode, shipperMSPID)
+	return c.saveDPP(ctx, dpp)
+}
+
+// AcknowledgeReturn: Der ursprüngliche Versender bestätigt den Eingang der Rücksendung.
+func (c *DPPQualityContract) AcknowledgeReturn(ctx contractapi.TransactionContextInterface, dppID, siteGLN string) error {
+	if err := validateSiteGLN("siteGLN", siteGLN); err != nil {
+		return err
+	}
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if dpp.Status != StatusReturnInTransit || dpp.OwnerOrg != clientMSPID || dpp.IntendedRecipientMSP != clientMSPID {
+		return fmt.Errorf("für DPP %s ist keine Rücksendung an %s offen (Status: %s, Owner: %s, Vorgesehener Empfänger: %s)", dppID, clientMSPID, dpp.Status, dpp.OwnerOrg, dpp.IntendedRecipientMSP)
+	}
+
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if err := dpp.transitionTo(ctx, clk, StatusReturned, fmt.Sprintf("Rücksendung durch %s bestätigt", clientMSPID)); err != nil {
+		return err
+	}
+	if err := dpp.transitionTo(ctx, clk, StatusBlocked, "Rückware gesperrt bis zur Freigabe durch QA"); err != nil {
+		return err
+	}
+	dpp.IntendedRecipientMSP = ""
+	if n := len(dpp.Rejections); n > 0 {
+		dpp.Rejections[n-1].ReturnAcknowledgedAt = clk.timestamp()
+		dpp.Rejections[n-1].ReturnAcknowledgedTxID = clk.txID
+	}
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("recv-return"),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:receiving",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         "urn:epcglobal:cbv:disp:returned",
+		ReadPoint:           siteLocation(siteGLN),
+		BizLocation:         siteLocation(siteGLN),
+		Extensions:          map[string]interface{}{"returnShipment": true},
+	})
+	fmt.Printf("[AcknowledgeReturn-INFO] Rücksendung von DPP %s bei %s eingegangen.\n", dppID, clientMSPID)
+	return c.saveDPP(ctx, dpp)
+}
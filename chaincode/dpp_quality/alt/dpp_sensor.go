@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"dpp_transfer_chaincode/epcis"
+)
+
+// --------------------------- Sensordaten (EPCIS 2.0 sensorElementList) --------------------------- //
+//
+// Messwerte aus Transport-Loggern (AddTransportUpdate) und numerische Prüfergebnisse der
+// Oracles (z.B. MFI-Messreihen) werden zusätzlich zum Eintrag als sensorElementList am
+// EPCIS-Event abgelegt: sensorMetadata mit Zeit und Rohdatendatei (Referenz, Hash und ni-URI
+// des Hashes), sensorReport mit Messgröße, Wert bzw. Min/Max/Mittelwert/Standardabweichung
+// und Einheit (Rec 20 und UCUM).
+
+// SensorElement: Ein Element der sensorElementList eines EPCIS-Events.
+type SensorElement struct {
+	SensorMetadata SensorMetadata `json:"sensorMetadata"`
+	SensorReport   []SensorReport `json:"sensorReport"`
+}
+
+// SensorMetadata: Zeit der Messung und Verweis auf die Rohdatendatei.
+type SensorMetadata struct {
+	Time          string `json:"time"`
+	RawData       string `json:"rawData,omitempty"       metadata:",optional"` // ni-URI (RFC 6920) des Datei-Hashes
+	RawDataRef    string `json:"rawDataRef,omitempty"    metadata:",optional"` // offChainDataRef bzw. offChainLogRef
+	RawDataHash   string `json:"rawDataHash,omitempty"   metadata:",optional"` // Hash der Datei (hex), siehe VerifyOffChainData
+	HashAlgorithm string `json:"hashAlgorithm,omitempty" metadata:",optional"`
+}
+
+// SensorReport: Messwert oder Zusammenfassung einer Messreihe. Wie bei QualitySpecification
+// wird "nicht gesetzt" von 0 unterschieden (has*-Felder, eigenes JSON-Format).
+type SensorReport struct {
+	Type      string  `json:"type"`                                     // CBV-Messgröße (gs1:MT-Temperature) oder dpp:<Test>
+	Exception string  `json:"exception,omitempty" metadata:",optional"` // ALARM_CONDITION bei Grenzwertverletzung, ERROR_CONDITION bei ungültigem Wert
+	Value     float64 `json:"value,omitempty"     metadata:",optional"`
+	MinValue  float64 `json:"minValue,omitempty"  metadata:",optional"`
+	MaxValue  float64 `json:"maxValue,omitempty"  metadata:",optional"`
+	MeanValue float64 `json:"meanValue,omitempty" metadata:",optional"`
+	SDev      float64 `json:"sDev,omitempty"      metadata:",optional"`
+	UOM       string  `json:"uom,omitempty"       metadata:",optional"` // UN/CEFACT Rec 20, leer wenn es keinen Code gibt
+	UCUMUnit  string  `json:"ucumUnit,omitempty"  metadata:",optional"` // Einheit der Werte
+
+	hasValue, hasMin, hasMax, hasMean, hasSDev bool
+}
+
+type sensorReportAlias SensorReport
+
+type sensorReportJSON struct {
+	sensorReportAlias
+	Value     *float64 `json:"value,omitempty"`
+	MinValue  *float64 `json:"minValue,omitempty"`
+	MaxValue  *float64 `json:"maxValue,omitempty"`
+	MeanValue *float64 `json:"meanValue,omitempty"`
+	SDev      *float64 `json:"sDev,omitempty"`
+}
+
+func (r SensorReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sensorReportJSON{
+		sensorReportAlias: sensorReportAlias(r),
+		Value:             optionalFloat(r.Value, r.hasValue),
+		MinValue:          optionalFloat(r.MinValue, r.hasMin),
+		MaxValue:          optionalFloat(r.MaxValue, r.hasMax),
+		MeanValue:         optionalFloat(r.MeanValue, r.hasMean),
+		SDev:              optionalFloat(r.SDev, r.hasSDev),
+	})
+}
+
+func (r *SensorReport) UnmarshalJSON(data []byte) error {
+	var aux sensorReportJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*r = SensorReport(aux.sensorReportAlias)
+	if aux.Value != nil {
+		r.Value, r.hasValue = *aux.Value, true
+	}
+	if aux.MinValue != nil {
+		r.MinValue, r.hasMin = *aux.MinValue, true
+	}
+	if aux.MaxValue != nil {
+		r.MaxValue, r.hasMax = *aux.MaxValue, true
+	}
+	if aux.MeanValue != nil {
+		r.MeanValue, r.hasMean = *aux.MeanValue, true
+	}
+	if aux.SDev != nil {
+		r.SDev, r.hasSDev = *aux.SDev, true
+	}
+	return nil
+}
+
+// newSensorReport legt einen Report für eine Messgröße in der UCUM-Einheit unit an.
+func newSensorReport(name, unit string, outcome EvaluationOutcome) SensorReport {
+	return SensorReport{Type: epcis.SensorType(name), Exception: outcome.sensorException(), UOM: epcis.UOM(unit), UCUMUnit: unit}
+}
+
+// newSensorMetadata: Metadaten mit Rohdatendatei; der ni-URI entsteht aus dem Hash.
+func newSensorMetadata(time, rawDataRef, rawDataHash, hashAlgorithm string) SensorMetadata {
+	return SensorMetadata{
+		Time:          time,
+		RawData:       epcis.NamedInformationURI(rawDataHash),
+		RawDataRef:    rawDataRef,
+		RawDataHash:   rawDataHash,
+		HashAlgorithm: hashAlgorithm,
+	}
+}
+
+// sensorException: exception eines sensorReport zur Bewertung.
+func (o EvaluationOutcome) sensorException() string {
+	switch {
+	case o == OutcomeInvalidFormat || o == OutcomeUnitMismatch:
+		return epcis.ErrorCondition
+	case o.isNonConformant():
+		return epcis.AlarmCondition
+	}
+	return ""
+}
+
+func parseSensorValue(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v, err == nil
+}
+
+// qualitySensorElement: Sensordaten eines numerischen Prüfergebnisses; nil bei nicht
+// numerischen Tests und vertraulichen Einträgen (ohne Ergebnis). Messreihen werden mit den
+// im Chaincode berechneten Kennzahlen (Einheit der Spezifikation) gemeldet.
+func qualitySensorElement(spec *QualitySpecification, qe QualityEntry) *SensorElement {
+	if spec == nil || !spec.IsNumeric {
+		return nil
+	}
+	var report SensorReport
+	if stats := qe.Statistics; stats != nil {
+		report = newSensorReport(qe.TestName, stats.Unit, qe.EvaluationOutcome)
+		report.MinValue, report.hasMin = stats.Min, true
+		report.MaxValue, report.hasMax = stats.Max, true
+		report.MeanValue, report.hasMean = stats.Mean, true
+		if stats.Count > 1 {
+			report.SDev, report.hasSDev = stats.StdDev, true
+		}
+	} else if value, ok := parseSensorValue(qe.Result); ok {
+		unit := qe.Unit
+		if unit == "" {
+			unit = spec.Unit
+		}
+		report = newSensorReport(qe.TestName, unit, qe.EvaluationOutcome)
+		report.Value, report.hasValue = value, true
+	} else {
+		return nil
+	}
+	return &SensorElement{
+		SensorMetadata: newSensorMetadata(qe.Timestamp, qe.OffChainDataRef, qe.OffChainDataHash, qe.HashAlgorithm),
+		SensorReport:   []SensorReport{report},
+	}
+}
+
+// transportSensorElement: Sensordaten eines Transport-Eintrags; nil, wenn value keine Zahl ist.
+func transportSensorElement(entry TransportConditionLogEntry) *SensorElement {
+	value, ok := parseSensorValue(entry.Value)
+	if !ok {
+		return nil
+	}
+	report := newSensorReport(entry.LogType, entry.Unit, entry.EvaluationOutcome)
+	report.Value, report.hasValue = value, true
+	if lo, ok := parseSensorValue(entry.MinValue); ok {
+		report.MinValue, report.hasMin = lo, true
+	}
+	if hi, ok := parseSensorValue(entry.MaxValue); ok {
+		report.MaxValue, report.hasMax = hi, true
+	}
+	return &SensorElement{
+		SensorMetadata: newSensorMetadata(entry.Timestamp, entry.OffChainLogRef, entry.OffChainLogHash, entry.HashAlgorithm),
+		SensorReport:   []SensorReport{report},
+	}
+}
+
+// sensorElements sammelt die vorhandenen Elemente (nil wird übersprungen).
+func sensorElements(elements ...*SensorElement) []SensorElement {
+	var list []SensorElement
+	for _, e := range elements {
+		if e != nil {
+			list = append(list, *e)
+		}
+	}
+	return list
+}
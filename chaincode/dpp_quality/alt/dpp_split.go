@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"dpp_transfer_chaincode/ucum"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Aufteilung (Split) --------------------------- //
+//
+// Eine freigegebene Charge kann in Teilmengen (z.B. Paletten, Big Bags) aufgeteilt werden,
+// die jeweils einen eigenen DPP mit eigenem GS1-Schlüssel erhalten und einzeln transferiert
+// werden. Die Teil-DPPs übernehmen Spezifikationen, Prüfzusammenfassung und Sonderfreigaben
+// des Eltern-DPP; die Qualitätseinträge selbst werden nicht kopiert, sondern über
+// SplitFromDPPID referenziert und von QueryDPP als inheritedQuality mitgeliefert. Der
+// aufgeteilte Eltern-DPP nimmt keine Qualitätsdaten mehr an; weitere Prüfungen werden an den
+// Teil-DPPs erfasst und betreffen nur diese. Die Aufteilung wird als EPCIS TransformationEvent
+// (bizStep repackaging) am Eltern-DPP und als Input/Output-Beziehung in der Genealogie
+// festgehalten, so dass Rückrufe auf alle Teil-DPPs durchgreifen.
+
+// relativeQuantityTolerance: Zulässige relative Abweichung zwischen Summe der Teilmengen und
+// Menge des Eltern-DPP (Rundung bei der Einheitenumrechnung).
+const relativeQuantityTolerance = 1e-9
+
+// SplitChild: Ein Teil-DPP in SplitDPP. QuantityUnit leer = Einheit des Eltern-DPP; hat
+// dieser keine Menge, ist QuantityUnit Pflicht.
+type SplitChild struct {
+	DppID        string  `json:"dppId"`
+	GS1Key       string  `json:"gs1Key"`
+	Quantity     float64 `json:"quantity"`
+	QuantityUnit string  `json:"quantityUnit,omitempty" metadata:",optional"` // UCUM-Code, z.B. "kg"
+}
+
+// validateQuantity prüft die Menge eines DPP. Eine Menge braucht immer eine UCUM-Einheit,
+// damit Teilmengen bei SplitDPP umgerechnet und summiert werden können.
+func validateQuantity(quantity float64, quantityUnit string) error {
+	if math.IsNaN(quantity) || math.IsInf(quantity, 0) || quantity < 0 {
+		return fmt.Errorf("quantity muss eine Zahl größer oder gleich 0 sein, erhalten: %v", quantity)
+	}
+	if quantity == 0 {
+		if quantityUnit != "" {
+			return fmt.Errorf("quantityUnit '%s' ohne quantity", quantityUnit)
+		}
+		return nil
+	}
+	if quantityUnit == "" {
+		return fmt.Errorf("quantity %s ohne quantityUnit", formatStat(quantity))
+	}
+	if _, err := ucum.Parse(quantityUnit); err != nil {
+		return fmt.Errorf("quantityUnit: %v", err)
+	}
+	return nil
+}
+
+// parseSplitChildren liest und prüft childrenJSON. Die Mengen werden in die Einheit des
+// Eltern-DPP umgerechnet, falls dieser eine Menge hat.
+func parseSplitChildren(parent *DPP, childrenJSON string) ([]SplitChild, error) {
+	var children []SplitChild
+	if err := json.Unmarshal([]byte(childrenJSON), &children); err != nil {
+		return nil, fmt.Errorf("childrenJSON fehlerhaft (erwartet JSON-Array von SplitChild): %v", err)
+	}
+	if len(children) < 2 {
+		return nil, fmt.Errorf("eine Aufteilung braucht mindestens zwei Teil-DPPs, erhalten: %d", len(children))
+	}
+
+	ids := map[string]bool{parent.DppID: true}
+	keys := map[string]bool{parent.GS1Key: true}
+	total := 0.0
+	for i := range children {
+		child := &children[i]
+		if child.DppID == "" {
+			return nil, fmt.Errorf("Teil-DPP %d ohne dppId", i+1)
+		}
+		if ids[child.DppID] {
+			return nil, fmt.Errorf("dppId %s ist in der Aufteilung mehrfach vergeben", child.DppID)
+		}
+		ids[child.DppID] = true
+		if err := validateGS1Key(child.GS1Key); err != nil {
+			return nil, fmt.Errorf("Teil-DPP %s: %v", child.DppID, err)
+		}
+		if keys[child.GS1Key] {
+			return nil, fmt.Errorf("GS1 Key %s ist in der Aufteilung mehrfach vergeben", child.GS1Key)
+		}
+		keys[child.GS1Key] = true
+		if math.IsNaN(child.Quantity) || math.IsInf(child.Quantity, 0) || child.Quantity <= 0 {
+			return nil, fmt.Errorf("Teil-DPP %s: quantity muss größer 0 sein", child.DppID)
+		}
+		if child.QuantityUnit == "" {
+			child.QuantityUnit = parent.QuantityUnit
+		}
+		if err := validateQuantity(child.Quantity, child.QuantityUnit); err != nil {
+			return nil, fmt.Errorf("Teil-DPP %s: %v", child.DppID, err)
+		}
+		if parent.Quantity > 0 {
+			q := child.Quantity
+			if child.QuantityUnit != parent.QuantityUnit {
+				converted, err := ucum.Convert(child.Quantity, child.QuantityUnit, parent.QuantityUnit)
+				if err != nil {
+					return nil, fmt.Errorf("Teil-DPP %s: Menge nicht in '%s' umrechenbar: %v", child.DppID, parent.QuantityUnit, err)
+				}
+				q = converted
+			}
+			total += q
+		}
+	}
+	if parent.Quantity > 0 && math.Abs(total-parent.Quantity) > relativeQuantityTolerance*parent.Quantity {
+		return nil, fmt.Errorf("Summe der Teilmengen (%s %s) entspricht nicht der Menge von DPP %s (%s %s)",
+			formatStat(roundStat(total)), parent.QuantityUnit, parent.DppID, formatStat(parent.Quantity), parent.QuantityUnit)
+	}
+	return children, nil
+}
+
+// SplitDPP: Teilt einen freigegebenen DPP in Teil-DPPs (childrenJSON: JSON-Array von
+// SplitChild). Der Eltern-DPP geht in den Status Split über und kann nicht mehr transferiert
+// werden; die Teil-DPPs gehören dem bisherigen Eigentümer und starten mit dem aus den
+// übernommenen Prüfergebnissen abgeleiteten Status.
+func (c *DPPQualityContract) SplitDPP(ctx contractapi.TransactionContextInterface, dppID, childrenJSON, siteGLN string) ([]string, error) {
+	fmt.Printf("[SplitDPP-DEBUG] Entry: dppID=%s, siteGLN=%s\n", dppID, siteGLN)
+	if err := validateSiteGLN("siteGLN", siteGLN); err != nil {
+		return nil, err
+	}
+	parent, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireOwner(ctx, parent, "aufteilen"); err != nil {
+		return nil, err
+	}
+	if err := parent.checkNoOpenRecall("aufgeteilt"); err != nil {
+		return nil, err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.syncStatus(ctx, clk, parent); err != nil {
+		return nil, err
+	}
+	if !canTransition(parent.Status, StatusSplit) {
+		return nil, fmt.Errorf("DPP %s kann im Status %s nicht aufgeteilt werden (nur freigegeben oder beim Empfänger angenommen)", dppID, parent.Status)
+	}
+	children, err := parseSplitChildren(parent, childrenJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	splitEventID := clk.nextEventID("split")
+	var childIDs, childKeys []string
+	quantities := make([]map[string]interface{}, 0, len(children))
+	for _, child := range children {
+		exists, err := c.dppExists(ctx, child.DppID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("DPP %s existiert bereits", child.DppID)
+		}
+
+		childDPP := parent.splitChild(child, splitEventID)
+		childDPP.inheritChecks(parent)
+		childDPP.ensureDigitalLink()
+		childDPP.addEvent(clk, EPCISEvent{
+			EventID:             clk.nextEventID("create"),
+			EventType:           "ObjectEvent",
+			EventTime:           clk.timestamp(),
+			EventTimeZoneOffset: clk.tzOffset(),
+			BizStep:             "urn:epcglobal:cbv:bizstep:commissioning",
+			Action:              "ADD",
+			EPCList:             []string{child.GS1Key},
+			Disposition:         "urn:epcglobal:cbv:disp:active",
+			ReadPoint:           siteLocation(siteGLN),
+			BizLocation:         siteLocation(siteGLN),
+			Extensions:          map[string]interface{}{"splitFrom": parent.GS1Key, "splitEventId": splitEventID},
+		})
+		if err := childDPP.transitionTo(ctx, clk, StatusDraft, fmt.Sprintf("Aus Aufteilung von DPP %s angelegt", dppID)); err != nil {
+			return nil, err
+		}
+		if err := childDPP.recalculateOverallStatus(ctx, clk, fmt.Sprintf("Prüfergebnisse von DPP %s übernommen", dppID)); err != nil {
+			return nil, err
+		}
+		if err := c.saveDPP(ctx, childDPP); err != nil {
+			return nil, err
+		}
+
+		childIDs = append(childIDs, child.DppID)
+		childKeys = append(childKeys, child.GS1Key)
+		quantities = append(quantities, map[string]interface{}{"epc": child.GS1Key, "quantity": child.Quantity, "uom": child.QuantityUnit})
+		fmt.Printf("[SplitDPP-DEBUG] Teil-DPP %s (%s, %s %s) angelegt, Status %s.\n", child.DppID, child.GS1Key, formatStat(child.Quantity), child.QuantityUnit, childDPP.Status)
+	}
+
+	parent.OutputDPPIDs = append(parent.OutputDPPIDs, childIDs...)
+	parent.SplitSeq = clk.nextRecordSeq()
+	parent.addEvent(clk, EPCISEvent{
+		EventID:             splitEventID,
+		EventType:           "TransformationEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:repackaging",
+		InputEPCList:        []string{parent.GS1Key},
+		OutputEPCList:       childKeys,
+		ReadPoint:           siteLocation(siteGLN),
+		BizLocation:         siteLocation(siteGLN),
+		Extensions:          map[string]interface{}{"splitQuantities": quantities},
+	})
+	if err := parent.transitionTo(ctx, clk, StatusSplit, fmt.Sprintf("Aufgeteilt in %s", strings.Join(childIDs, ", "))); err != nil {
+		return nil, err
+	}
+	if err := c.saveDPP(ctx, parent); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[SplitDPP-INFO] DPP %s in %d Teil-DPPs aufgeteilt: %v\n", dppID, len(childIDs), childIDs)
+	return childIDs, nil
+}
+
+// splitChild erzeugt den Kopf eines Teil-DPP. Sonderfreigaben werden übernommen, die
+// Qualitätseinträge bleiben am Eltern-DPP.
+func (parent *DPP) splitChild(child SplitChild, splitEventID string) *DPP {
+	return &DPP{
+		DocType:                 dppDocType,
+		DppID:                   child.DppID,
+		GS1Key:                  child.GS1Key,
+		ProductTypeID:           parent.ProductTypeID,
+		ManufacturerGLN:         parent.ManufacturerGLN,
+		Batch:                   parent.Batch,
+		ProductionDate:          parent.ProductionDate,
+		OwnerOrg:                parent.OwnerOrg,
+		SpecificationVersion:    parent.SpecificationVersion,
+		Specifications:          parent.Specifications,
+		InputDPPIDs:             []string{parent.DppID},
+		TransformationEventID:   splitEventID,
+		TransportSpecifications: parent.TransportSpecifications,
+		TransportAlert:          parent.TransportAlert,
+		TransportExcursions:     append([]string(nil), parent.TransportExcursions...),
+		TransportClearances:     append([]TransportClearance(nil), parent.TransportClearances...),
+		IntendedCustomerMSP:     parent.IntendedCustomerMSP,
+		Concessions:             append([]Concession(nil), parent.Concessions...),
+		SplitFromDPPID:          parent.DppID,
+		Quantity:                child.Quantity,
+		QuantityUnit:            child.QuantityUnit,
+	}
+}
+
+// inheritChecks übernimmt die Prüfstände des Eltern-DPP (mit loadChecks gelesen) als eigene
+// Prüfstände des Teil-DPP.
+func (dpp *DPP) inheritChecks(parent *DPP) {
+	dpp.initChecks()
+	for _, name := range parent.checkNames() {
+		check := *parent.checks[name]
+		dpp.checks[name] = &check
+		dpp.queueCheck(&check)
+	}
+}
+
+// loadInheritedQuality liest die Qualitätseinträge aller Eltern-DPPs entlang SplitFromDPPID
+// (ältester zuerst) nach dpp.InheritedQuality. Vererbt werden nur Einträge bis zur Aufteilung
+// (SplitSeq des Eltern-DPP); ältere Chaincode-Versionen ließen danach noch Einträge am
+// Eltern-DPP zu.
+func (c *DPPQualityContract) loadInheritedQuality(ctx contractapi.TransactionContextInterface, dpp *DPP) error {
+	var chain []*DPP
+	for parentID := dpp.SplitFromDPPID; parentID != ""; {
+		if len(chain) == maxTraceDepth {
+			return fmt.Errorf("Aufteilungskette von DPP %s ist tiefer als %d Stufen", dpp.DppID, maxTraceDepth)
+		}
+		parent, err := c.readDPPHeader(ctx, parentID)
+		if err != nil {
+			return fmt.Errorf("Eltern-DPP von %s: %v", dpp.DppID, err)
+		}
+		chain = append(chain, parent)
+		parentID = parent.SplitFromDPPID
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+
+	dpp.InheritedQuality = []QualityEntry{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		parent := chain[i]
+		if parent.embedded {
+			// Altes Format: Einträge stehen noch im Kopf-Dokument.
+			for _, qe := range parent.Quality {
+				if parent.beforeSplit(qe) {
+					dpp.InheritedQuality = append(dpp.InheritedQuality, qe)
+				}
+			}
+			continue
+		}
+		if err := forEachRecord(ctx, qualityObjectType, parent.DppID, func(data []byte) error {
+			var qe QualityEntry
+			if err := json.Unmarshal(data, &qe); err != nil {
+				return err
+			}
+			if parent.beforeSplit(qe) {
+				dpp.InheritedQuality = append(dpp.InheritedQuality, qe)
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("Fehler beim Lesen der Qualitätseinträge von Eltern-DPP %s: %v", parent.DppID, err)
+		}
+	}
+	return nil
+}
+
+// beforeSplit: Eintrag lag bei der Aufteilung schon vor. Ohne SplitSeq (Aufteilung durch eine
+// ältere Chaincode-Version) werden alle Einträge vererbt.
+func (parent *DPP) beforeSplit(qe QualityEntry) bool {
+	return parent.SplitSeq == "" || qe.EntryID < parent.SplitSeq
+}
+
+// checkNotTerminal: Aufgeteilte und verbrauchte DPPs nehmen keine Daten mehr an, sonst
+// tauchten sie bei den Teil-DPPs als geerbte Einträge auf, ohne deren Status zu ändern.
+func (dpp *DPP) checkNotTerminal(action string) error {
+	if !dpp.Status.isTerminal() {
+		return nil
+	}
+	if out := dpp.outputDPPIDs(); len(out) > 0 {
+		return fmt.Errorf("DPP %s ist im Status %s, es können keine %s werden; bitte an den Folge-DPPs %s erfassen", dpp.DppID, dpp.Status, action, strings.Join(out, ", "))
+	}
+	return fmt.Errorf("DPP %s ist im Status %s, es können keine %s werden", dpp.DppID, dpp.Status, action)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCreateDPPQuantity(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		unit     string
+		wantErr  string
+	}{
+		{1000, "kg", ""},
+		{0, "", ""},
+		{1000, "", "ohne quantityUnit"},
+		{0, "kg", "ohne quantity"},
+		{-1, "kg", "größer oder gleich 0"},
+		{1000, "Sack", "kein gültiger UCUM-Code"},
+	}
+	for _, tt := range tests {
+		e := newTestEnv(t)
+		c := &DPPQualityContract{}
+		_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-S", retestSpecs, "", "")
+		e.must(err)
+		dpp, err := c.CreateDPP(e.ctx("Org1MSP"), "S1", "urn:epc:id:sgtin:4012345.011111.2001", "PT-S", "4012345000016", "L1", "2025-06-01", tt.quantity, tt.unit, 0)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CreateDPP(%v %q): Fehler %v, erwartet %q", tt.quantity, tt.unit, err, tt.wantErr)
+			}
+			continue
+		}
+		e.must(err)
+		if dpp.Quantity != tt.quantity || dpp.QuantityUnit != tt.unit {
+			t.Errorf("Menge %v %q, erwartet %v %q", dpp.Quantity, dpp.QuantityUnit, tt.quantity, tt.unit)
+		}
+	}
+}
+
+func TestSplitDPPQuantities(t *testing.T) {
+	tests := []struct {
+		name           string
+		quantity       float64
+		unit           string
+		children       string
+		wantErr        string
+		wantChildUnits []string
+	}{
+		{"Einheit vom Eltern-DPP", 1000, "kg",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":600}]`,
+			"", []string{"kg", "kg"}},
+		{"umgerechnete Teilmengen", 1000, "kg",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":0.5,"quantityUnit":"t"},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":500000,"quantityUnit":"g"}]`,
+			"", []string{"t", "g"}},
+		{"Summe passt nicht", 1000, "kg",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":500}]`,
+			"Summe der Teilmengen (900 kg)", nil},
+		{"unvereinbare Einheit", 1000, "kg",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":600,"quantityUnit":"L"}]`,
+			"nicht in 'kg' umrechenbar", nil},
+		{"Eltern-DPP ohne Menge", 0, "",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400,"quantityUnit":"kg"},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":1,"quantityUnit":"t"}]`,
+			"", []string{"kg", "t"}},
+		{"Teilmenge ohne Einheit", 0, "",
+			`[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400,"quantityUnit":"kg"},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":600}]`,
+			"Teil-DPP S1-2: quantity 600 ohne quantityUnit", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			c := &DPPQualityContract{}
+			_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-S", retestSpecs, "", "")
+			e.must(err)
+			_, err = c.CreateDPP(e.ctx("Org1MSP"), "S1", "urn:epc:id:sgtin:4012345.011111.2001", "PT-S", "4012345000016", "L1", "2025-06-01", tt.quantity, tt.unit, 0)
+			e.must(err)
+			e.must(c.RecordQualityData(e.ctx("Org1MSP"), "S1", qualityJSON("MFI", "15", "g/10min"), ""))
+
+			ids, err := c.SplitDPP(e.ctx("Org1MSP"), "S1", tt.children, "4012345000016")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Fehler %v, erwartet %q", err, tt.wantErr)
+				}
+				return
+			}
+			e.must(err)
+			for i, id := range ids {
+				child, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+				e.must(err)
+				if child.QuantityUnit != tt.wantChildUnits[i] {
+					t.Errorf("Teil-DPP %s: Einheit %q, erwartet %q", id, child.QuantityUnit, tt.wantChildUnits[i])
+				}
+				if child.Status != StatusReleased {
+					t.Errorf("Teil-DPP %s: Status %s, erwartet Released", id, child.Status)
+				}
+			}
+		})
+	}
+}
+
+// splitEnv: Freigegebener DPP S1 (1000 kg, MFI und Dichte geprüft), aufgeteilt in S1-1 und S1-2.
+func splitEnv(t *testing.T) (*testEnv, *DPPQualityContract) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-S", retestSpecs, "", "")
+	e.must(err)
+	_, err = c.CreateDPP(e.ctx("Org1MSP"), "S1", "urn:epc:id:sgtin:4012345.011111.2001", "PT-S", "4012345000016", "L1", "2025-06-01", 1000, "kg", 0)
+	e.must(err)
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "S1", qualityJSON("MFI", "15", "g/10min"), ""))
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "S1", qualityJSON("Dichte", "1.0", "g/cm3"), ""))
+	_, err = c.SplitDPP(e.ctx("Org1MSP"), "S1", `[{"dppId":"S1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.2011","quantity":400},{"dppId":"S1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.2012","quantity":600}]`, "4012345000016")
+	e.must(err)
+	return e, c
+}
+
+func TestSplitParentRejectsQualityData(t *testing.T) {
+	e, c := splitEnv(t)
+	err := c.RecordQualityData(e.ctx("Org1MSP"), "S1", qualityJSON("Dichte", "1.5", "g/cm3"), "")
+	if err == nil || !strings.Contains(err.Error(), "Folge-DPPs S1-1, S1-2") {
+		t.Errorf("RecordQualityData am Eltern-DPP: Fehler %v", err)
+	}
+	err = c.RecordQualityDataBatch(e.ctx("Org1MSP"), "S1", `[{"testName":"Dichte","result":"1.5","unit":"g/cm3"}]`, "")
+	if err == nil || !strings.Contains(err.Error(), "im Status Split") {
+		t.Errorf("RecordQualityDataBatch am Eltern-DPP: Fehler %v", err)
+	}
+	// Teil-DPPs nehmen weiter Daten an.
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "S1-1", qualityJSON("Dichte", "1.05", "g/cm3"), ""))
+}
+
+func TestSplitInheritedQuality(t *testing.T) {
+	e, c := splitEnv(t)
+
+	// Eine ältere Chaincode-Version nahm am Eltern-DPP noch nach der Aufteilung Einträge an.
+	late := QualityEntry{EntryID: "99999999999999999999-late-0001", TestName: "Dichte", Result: "1.5", Unit: "g/cm3", EvaluationOutcome: OutcomeDeviationHigh}
+	data, err := json.Marshal(late)
+	e.must(err)
+	key, err := e.stub.CreateCompositeKey(qualityObjectType, []string{"S1", late.EntryID})
+	e.must(err)
+	e.must(e.ctx("Org1MSP").GetStub().PutState(key, data))
+
+	for _, id := range []string{"S1-1", "S1-2"} {
+		child, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+		e.must(err)
+		if child.Status != StatusReleased || len(child.Quality) != 0 {
+			t.Errorf("%s: Status %s, %d eigene Einträge", id, child.Status, len(child.Quality))
+		}
+		var tests []string
+		for _, qe := range child.InheritedQuality {
+			tests = append(tests, qe.TestName+" "+string(qe.EvaluationOutcome))
+		}
+		if strings.Join(tests, ", ") != "MFI PASS, Dichte PASS" {
+			t.Errorf("%s: geerbte Einträge [%s], erwartet [MFI PASS, Dichte PASS]", id, strings.Join(tests, ", "))
+		}
+	}
+	parent, err := c.QueryDPP(e.ctx("Org1MSP"), "S1")
+	e.must(err)
+	if len(parent.Quality) != 3 {
+		t.Errorf("Eltern-DPP: %d Einträge, erwartet 3", len(parent.Quality))
+	}
+}
+
+func TestSplitChildrenTransferAndRecall(t *testing.T) {
+	e, c := splitEnv(t)
+
+	// Teil-DPPs werden einzeln versandt; der Eltern-DPP nicht mehr.
+	e.must(c.TransferDPP(e.ctx("Org1MSP"), "S1-1", "Org2MSP", "4012345000016"))
+	e.must(c.AcknowledgeReceiptAndRecordInspection(e.ctx("Org2MSP"), "S1-1", "4098765000010", ""))
+	if err := c.TransferDPP(e.ctx("Org1MSP"), "S1", "Org2MSP", "4012345000016"); err == nil {
+		t.Errorf("aufgeteilter Eltern-DPP wurde transferiert")
+	}
+	for id, want := range map[string]struct {
+		owner  string
+		status DPPStatus
+	}{"S1": {"Org1MSP", StatusSplit}, "S1-1": {"Org2MSP", StatusAcceptedAtRecipient}, "S1-2": {"Org1MSP", StatusReleased}} {
+		dpp, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+		e.must(err)
+		if dpp.OwnerOrg != want.owner || dpp.Status != want.status {
+			t.Errorf("%s: %s, %s; erwartet %s, %s", id, dpp.OwnerOrg, dpp.Status, want.owner, want.status)
+		}
+	}
+
+	// Ein Rückruf am Eltern-DPP erreicht über OutputDPPIDs beide Teil-DPPs.
+	recall, err := c.InitiateRecall(e.ctx("Org1MSP"), "S1", "Verunreinigung in Charge L1", "HIGH")
+	e.must(err)
+	affected := map[string]bool{}
+	for _, a := range recall.AffectedDPPs {
+		affected[a.DppID] = true
+	}
+	if len(affected) != 3 || !affected["S1"] || !affected["S1-1"] || !affected["S1-2"] {
+		t.Errorf("betroffene DPPs %v, erwartet S1, S1-1, S1-2", affected)
+	}
+	for _, id := range []string{"S1-1", "S1-2"} {
+		child, err := c.QueryDPP(e.ctx("Org1MSP"), id)
+		e.must(err)
+		if len(child.Recalls) != 1 || child.Recalls[0].RecallID != recall.RecallID || child.Recalls[0].Status != RecallOpen {
+			t.Errorf("%s: Rückrufe %+v", id, child.Recalls)
+		}
+	}
+	if err := c.TransferDPP(e.ctx("Org1MSP"), "S1-2", "Org2MSP", "4012345000016"); err == nil {
+		t.Errorf("zurückgerufener Teil-DPP wurde transferiert")
+	}
+}
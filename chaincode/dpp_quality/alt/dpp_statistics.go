@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// --------------------------- Statistische Bewertung von Messreihen --------------------------- //
+//
+// Ein QualityEntry kann statt eines einzelnen Ergebnisses die Einzelwerte einer Messreihe
+// enthalten (readings, z.B. alle MFI-Messungen eines CSV-Logs). Der Chaincode berechnet
+// Anzahl, Mittelwert, Standardabweichung, Minimum, Maximum und Cpk selbst und speichert sie
+// am Eintrag. Die Spezifikation legt mit statistics fest, welche Kriterien gelten; ohne
+// Kriterien wird der Mittelwert gegen die Grenzen geprüft (wie bisher das Ergebnis des Oracles).
+
+// StatisticalCriteria: Kriterien einer numerischen Spezifikation für Messreihen. 0 bei
+// MaxStdDev, MinCpk und MinReadings bedeutet "nicht geprüft".
+type StatisticalCriteria struct {
+	MeanWithinLimits      bool    `json:"meanWithinLimits"`                                     // Mittelwert innerhalb der Grenzen
+	AllValuesWithinLimits bool    `json:"allValuesWithinLimits,omitempty" metadata:",optional"` // jeder Einzelwert innerhalb der Grenzen
+	MaxStdDev             float64 `json:"maxStdDev,omitempty"             metadata:",optional"` // größte zulässige Standardabweichung (Stichprobe)
+	MinCpk                float64 `json:"minCpk,omitempty"                metadata:",optional"` // kleinster zulässiger Prozessfähigkeitsindex
+	MinReadings           int     `json:"minReadings,omitempty"           metadata:",optional"` // Mindestanzahl Einzelwerte
+}
+
+// ReadingStatistics: Im Chaincode berechnete Kennzahlen einer Messreihe (Einheit der Spezifikation).
+type ReadingStatistics struct {
+	Count      int     `json:"count"`
+	Mean       float64 `json:"mean"`
+	StdDev     float64 `json:"stdDev"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Cpk        float64 `json:"cpk,omitempty"  metadata:",optional"`
+	CpkDefined bool    `json:"cpkDefined"` // false bei Standardabweichung 0 oder ohne Grenzen (siehe cpk)
+	Unit       string  `json:"unit,omitempty" metadata:",optional"`
+}
+
+// validate prüft die Kriterien gegen ihre Spezifikation. Cpk ist nur mit mindestens einer
+// Grenze definiert; ohne Grenzen würde minCpk stillschweigend nie geprüft.
+func (c *StatisticalCriteria) validate(spec *QualitySpecification) error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxStdDev < 0 || c.MinCpk < 0 || c.MinReadings < 0 {
+		return fmt.Errorf("Spezifikation '%s': maxStdDev, minCpk und minReadings dürfen nicht negativ sein", spec.TestName)
+	}
+	if !c.MeanWithinLimits && !c.AllValuesWithinLimits && c.MaxStdDev == 0 && c.MinCpk == 0 {
+		return fmt.Errorf("Spezifikation '%s': statistics ohne Kriterium", spec.TestName)
+	}
+	if _, _, hasLower, hasUpper := spec.bounds(); c.MinCpk > 0 && !hasLower && !hasUpper {
+		return fmt.Errorf("Spezifikation '%s': minCpk erfordert lowerLimit, upperLimit oder nominalValue", spec.TestName)
+	}
+	return nil
+}
+
+// requiredReadings: Mindestanzahl Einzelwerte; Standardabweichung und Cpk brauchen mindestens zwei.
+func (c *StatisticalCriteria) requiredReadings() int {
+	n := 1
+	if c.MaxStdDev > 0 || c.MinCpk > 0 {
+		n = 2
+	}
+	if c.MinReadings > n {
+		n = c.MinReadings
+	}
+	return n
+}
+
+// roundStat rundet auf 12 signifikante Stellen, damit alle Peers dieselben Kennzahlen
+// speichern (Gleitkomma-Reihenfolge und FMA können die letzten Stellen verändern).
+func roundStat(v float64) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	return r
+}
+
+// computeStatistics berechnet die Kennzahlen einer Messreihe gegen die Grenzen der Spezifikation.
+func computeStatistics(spec *QualitySpecification, values []float64) *ReadingStatistics {
+	stats := &ReadingStatistics{Count: len(values), Min: values[0], Max: values[0], Unit: spec.Unit}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		stats.Min = math.Min(stats.Min, v)
+		stats.Max = math.Max(stats.Max, v)
+	}
+	mean := sum / float64(len(values))
+	if len(values) > 1 {
+		squares := 0.0
+		for _, v := range values {
+			squares += (v - mean) * (v - mean)
+		}
+		stats.StdDev = roundStat(math.Sqrt(squares / float64(len(values)-1)))
+	}
+	stats.Mean = roundStat(mean)
+
+	lower, upper, hasLower, hasUpper := spec.bounds()
+	if stats.StdDev > 0 && (hasLower || hasUpper) {
+		cpk := math.Inf(1)
+		if hasUpper {
+			cpk = math.Min(cpk, (upper-stats.Mean)/(3*stats.StdDev))
+		}
+		if hasLower {
+			cpk = math.Min(cpk, (stats.Mean-lower)/(3*stats.StdDev))
+		}
+		stats.Cpk = roundStat(cpk)
+		stats.CpkDefined = true
+	}
+	return stats
+}
+
+// cpk liefert den Cpk für die Bewertung. Ohne Streuung ist er nicht berechenbar (Division
+// durch 0) und gilt innerhalb der Grenzen als +Inf, außerhalb als -Inf; gespeichert wird er
+// dann nicht, da JSON keine unendlichen Werte kennt. false ohne Grenzen oder mit nur einem Wert.
+func (s *ReadingStatistics) cpk(spec *QualitySpecification) (float64, bool) {
+	if s.CpkDefined {
+		return s.Cpk, true
+	}
+	if _, _, hasLower, hasUpper := spec.bounds(); s.Count < 2 || s.StdDev > 0 || (!hasLower && !hasUpper) {
+		return 0, false
+	}
+	if evaluateValue(spec, s.Mean).Outcome == OutcomePass {
+		return math.Inf(1), true
+	}
+	return math.Inf(-1), true
+}
+
+// evaluateSeries bewertet eine Messreihe. Die Einzelwerte werden in die Einheit der
+// Spezifikation umgerechnet. Mittelwert und Einzelwerte außerhalb der Grenzen ergeben
+// DEVIATION_LOW/HIGH, ebenso zu hohe Standardabweichung (HIGH) und zu kleiner Cpk (LOW).
+func evaluateSeries(spec *QualitySpecification, qe QualityEntry) Evaluation {
+	criteria := spec.Statistics
+	if criteria == nil {
+		criteria = &StatisticalCriteria{MeanWithinLimits: true}
+	}
+	readings := qe.Readings
+	if len(readings) == 0 {
+		// Nur ein Ergebnis übermittelt: als Messreihe mit einem Wert behandeln.
+		value, err := strconv.ParseFloat(strings.TrimSpace(qe.Result), 64)
+		if err != nil {
+			return Evaluation{Outcome: OutcomeInvalidFormat, Comment: fmt.Sprintf("Ergebnis '%s' für Test '%s' ist nicht numerisch.", qe.Result, qe.TestName)}
+		}
+		readings = []float64{value}
+	}
+	if required := criteria.requiredReadings(); len(readings) < required {
+		return Evaluation{Outcome: OutcomeInvalidFormat, Comment: fmt.Sprintf("Test '%s' erfordert mindestens %d Einzelwerte (readings), erhalten: %d.", qe.TestName, required, len(readings))}
+	}
+
+	convert, converted, mismatch := unitConversion(spec, qe)
+	if mismatch != nil {
+		return *mismatch
+	}
+	values := make([]float64, len(readings))
+	for i, r := range readings {
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return Evaluation{Outcome: OutcomeInvalidFormat, Comment: fmt.Sprintf("Einzelwert %d für Test '%s' ist keine endliche Zahl.", i+1, qe.TestName)}
+		}
+		v, err := convert(r)
+		if err != nil {
+			return Evaluation{Outcome: OutcomeUnitMismatch, Comment: err.Error()}
+		}
+		values[i] = v
+	}
+
+	stats := computeStatistics(spec, values)
+	ev := Evaluation{Outcome: OutcomePass, Statistics: stats}
+	var findings []string
+	fail := func(outcome EvaluationOutcome, finding string) {
+		if ev.Outcome == OutcomePass {
+			ev.Outcome = outcome
+		}
+		findings = append(findings, finding)
+	}
+
+	if criteria.MeanWithinLimits {
+		if mev := evaluateValue(spec, stats.Mean); mev.Outcome != OutcomePass {
+			fail(mev.Outcome, "Mittelwert: "+mev.Comment)
+		}
+	}
+	if criteria.AllValuesWithinLimits {
+		outside := 0
+		var first Evaluation
+		for _, v := range values {
+			if vev := evaluateValue(spec, v); vev.Outcome != OutcomePass {
+				if outside == 0 {
+					first = vev
+				}
+				outside++
+			}
+		}
+		if outside > 0 {
+			fail(first.Outcome, fmt.Sprintf("%d von %d Einzelwerten außerhalb der Grenzen, z.B. %s", outside, len(values), first.Comment))
+		}
+	}
+	if criteria.MaxStdDev > 0 && stats.StdDev > criteria.MaxStdDev {
+		fail(OutcomeDeviationHigh, fmt.Sprintf("Standardabweichung %.4f über Maximum %.4f %s.", stats.StdDev, criteria.MaxStdDev, spec.Unit))
+	}
+	cpk, cpkDefined := stats.cpk(spec)
+	if criteria.MinCpk > 0 && cpkDefined && cpk < criteria.MinCpk {
+		fail(OutcomeDeviationLow, fmt.Sprintf("Cpk %.2f unter Minimum %.2f.", cpk, criteria.MinCpk))
+	}
+
+	summary := fmt.Sprintf("Messreihe: n=%d, Mittelwert %s, s=%s, Min %s, Max %s %s",
+		stats.Count, formatStat(stats.Mean), formatStat(stats.StdDev), formatStat(stats.Min), formatStat(stats.Max), spec.Unit)
+	if cpkDefined {
+		summary += fmt.Sprintf(", Cpk %.2f", cpk)
+	}
+	summary = strings.TrimSpace(summary) + "."
+	if converted {
+		ev.NormalizedResult = formatStat(stats.Mean)
+		ev.NormalizedUnit = spec.Unit
+		summary = fmt.Sprintf("Umgerechnet von '%s' in '%s'. %s", qe.Unit, spec.Unit, summary)
+	}
+	ev.Comment = joinComments(append([]string{summary}, findings...)...)
+	return ev
+}
+
+func formatStat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestComputeStatistics(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		values     []float64
+		want       ReadingStatistics
+		cpkDefined bool
+	}{
+		{"zweiseitig", `{"lowerLimit":6,"upperLimit":20}`, []float64{10, 12, 14},
+			ReadingStatistics{Count: 3, Mean: 12, StdDev: 2, Min: 10, Max: 14, Cpk: 1}, true},
+		{"nur obere Grenze", `{"upperLimit":20}`, []float64{10, 12, 14},
+			ReadingStatistics{Count: 3, Mean: 12, StdDev: 2, Min: 10, Max: 14, Cpk: 1.33333333333}, true},
+		{"Nennwert und Toleranz", `{"nominalValue":12,"tolerance":3}`, []float64{10, 12, 14},
+			ReadingStatistics{Count: 3, Mean: 12, StdDev: 2, Min: 10, Max: 14, Cpk: 0.5}, true},
+		{"Stichprobe n-1", `{"lowerLimit":0,"upperLimit":10}`, []float64{2, 4, 4, 4, 5, 5, 7, 9},
+			ReadingStatistics{Count: 8, Mean: 5, StdDev: 2.1380899353, Min: 2, Max: 9, Cpk: 0.779511955578}, true},
+		{"ohne Streuung", `{"lowerLimit":6,"upperLimit":20}`, []float64{12, 12, 12},
+			ReadingStatistics{Count: 3, Mean: 12, Min: 12, Max: 12}, false},
+		{"ein Wert", `{"lowerLimit":6,"upperLimit":20}`, []float64{7},
+			ReadingStatistics{Count: 1, Mean: 7, Min: 7, Max: 7}, false},
+		{"Rundung", `{"lowerLimit":0,"upperLimit":1}`, []float64{0.1, 0.2},
+			ReadingStatistics{Count: 2, Mean: 0.15, StdDev: 0.0707106781187, Min: 0.1, Max: 0.2, Cpk: 0.707106781186}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spec QualitySpecification
+			if err := json.Unmarshal([]byte(tt.spec), &spec); err != nil {
+				t.Fatal(err)
+			}
+			spec.Unit = "g/10min"
+			got := computeStatistics(&spec, tt.values)
+			tt.want.CpkDefined, tt.want.Unit = tt.cpkDefined, "g/10min"
+			if *got != tt.want {
+				t.Errorf("Kennzahlen %+v, erwartet %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria string
+		result   string
+		unit     string
+		readings []float64
+		want     EvaluationOutcome
+		finding  string // Teil des Kommentars
+	}{
+		{"Mittelwert innerhalb", `{"meanWithinLimits":true}`, "", "g/10min", []float64{10, 12, 14}, OutcomePass, "n=3, Mittelwert 12, s=2"},
+		{"Mittelwert über Grenze", `{"meanWithinLimits":true}`, "", "g/10min", []float64{21, 22, 23}, OutcomeDeviationHigh, "Mittelwert: "},
+		{"ohne Kriterien nur Mittelwert", ``, "", "g/10min", []float64{5, 12, 19}, OutcomePass, "Mittelwert 12"},
+		{"Einzelwert außerhalb", `{"allValuesWithinLimits":true}`, "", "g/10min", []float64{5, 12, 19}, OutcomeDeviationLow, "1 von 3 Einzelwerten"},
+		{"Standardabweichung zu hoch", `{"maxStdDev":1}`, "", "g/10min", []float64{10, 12, 14}, OutcomeDeviationHigh, "Standardabweichung 2.0000"},
+		{"Standardabweichung zulässig", `{"maxStdDev":2}`, "", "g/10min", []float64{10, 12, 14}, OutcomePass, ""},
+		{"Cpk zu klein", `{"minCpk":1.33}`, "", "g/10min", []float64{10, 12, 14}, OutcomeDeviationLow, "Cpk 1.00 unter Minimum 1.33"},
+		{"Cpk ausreichend", `{"minCpk":1}`, "", "g/10min", []float64{10, 12, 14}, OutcomePass, "Cpk 1.00"},
+		{"Cpk ohne Streuung", `{"minCpk":1.33}`, "", "g/10min", []float64{12, 12}, OutcomePass, "Cpk +Inf"},
+		{"Cpk ohne Streuung außerhalb", `{"minCpk":1.33}`, "", "g/10min", []float64{25, 25, 25}, OutcomeDeviationLow, "Cpk -Inf unter Minimum 1.33"},
+		{"Cpk ohne Streuung an der Grenze", `{"minCpk":1.33}`, "", "g/10min", []float64{20, 20}, OutcomePass, "Cpk +Inf"},
+		{"erstes Kriterium bestimmt Ergebnis", `{"meanWithinLimits":true,"maxStdDev":1}`, "", "g/10min", []float64{3, 5, 7}, OutcomeDeviationLow, "Standardabweichung"},
+		{"zu wenige Einzelwerte", `{"meanWithinLimits":true,"minReadings":5}`, "", "g/10min", []float64{10, 12, 14}, OutcomeInvalidFormat, "mindestens 5"},
+		{"Cpk braucht zwei Werte", `{"minCpk":1}`, "12", "g/10min", nil, OutcomeInvalidFormat, "mindestens 2"},
+		{"Ergebnis ohne readings", `{"meanWithinLimits":true}`, "12", "g/10min", nil, OutcomePass, "n=1"},
+		{"umgerechnet", `{"meanWithinLimits":true}`, "", "g/h", []float64{60, 72, 84}, OutcomePass, "Umgerechnet von 'g/h' in 'g/10min'"},
+		{"unvereinbare Einheit", `{"meanWithinLimits":true}`, "", "g/cm3", []float64{10, 12, 14}, OutcomeUnitMismatch, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specJSON := `{"testName":"MFI","isNumeric":true,"lowerLimit":6,"upperLimit":20,"unit":"g/10min"`
+			if tt.criteria != "" {
+				specJSON += `,"statistics":` + tt.criteria
+			}
+			spec := testSpec(t, specJSON+"}")
+			ev := evaluateSeries(spec, QualityEntry{TestName: "MFI", Result: tt.result, Unit: tt.unit, Readings: tt.readings})
+			if ev.Outcome != tt.want {
+				t.Errorf("Ergebnis %s, erwartet %s (%s)", ev.Outcome, tt.want, ev.Comment)
+			}
+			if !strings.Contains(ev.Comment, tt.finding) {
+				t.Errorf("Kommentar %q enthält nicht %q", ev.Comment, tt.finding)
+			}
+			if tt.want != OutcomeInvalidFormat && tt.want != OutcomeUnitMismatch && ev.Statistics == nil {
+				t.Errorf("Kennzahlen fehlen")
+			}
+		})
+	}
+}
+
+func TestStatisticalCriteriaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"zweiseitig mit minCpk", `{"lowerLimit":6,"upperLimit":20,"statistics":{"minCpk":1.33}}`, ""},
+		{"einseitig mit minCpk", `{"upperLimit":20,"statistics":{"minCpk":1.33}}`, ""},
+		{"Nennwert mit minCpk", `{"nominalValue":12,"tolerance":3,"statistics":{"minCpk":1.33}}`, ""},
+		{"minCpk ohne Grenzen", `{"statistics":{"minCpk":1.33}}`, "minCpk erfordert"},
+		{"ohne Kriterium", `{"upperLimit":20,"statistics":{"minReadings":3}}`, "ohne Kriterium"},
+		{"negativ", `{"upperLimit":20,"statistics":{"meanWithinLimits":true,"maxStdDev":-1}}`, "nicht negativ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spec QualitySpecification
+			if err := json.Unmarshal([]byte(`{"testName":"MFI","isNumeric":true,`+tt.spec[1:]), &spec); err != nil {
+				t.Fatal(err)
+			}
+			err := spec.validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unerwarteter Fehler: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Fehler %v, erwartet %q", err, tt.wantErr)
+			}
+		})
+	}
+}
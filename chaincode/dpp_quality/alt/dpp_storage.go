@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Persistenz --------------------------- //
+//
+// Der DPP-Kopf (Stammdaten, Status, Sonderfreigaben) liegt unter "DPP-<id>".
+// Qualitätseinträge, EPCIS-Events, Transportprotokoll und Prüfstände liegen unter eigenen
+// Composite Keys:
+//
+//	DPP~quality~<dppId>~<seq>
+//	DPP~event~<dppId>~<seq>
+//	DPP~transport~<dppId>~<seq>
+//	DPP~check~<dppId>~<testName>
+//
+// Zu jedem Event kommen Indexeinträge für DPP-übergreifende Abfragen (siehe dpp_eventquery.go).
+// <seq> wird aus Transaktionszeit, TxID und laufender Nummer gebildet und ist damit
+// deterministisch, eindeutig und chronologisch sortierbar. Schreibende Funktionen lesen nur
+// den Kopf und schreiben ihn nur, wenn er sich tatsächlich geändert hat.
+//
+// Qualitätsdaten ändern nur den Prüfstand ihres Tests (siehe dpp_checks.go), nicht den Kopf.
+// Gleichzeitige Oracle-Einreichungen für verschiedene Tests desselben DPP sind damit
+// konfliktfrei; nur Einreichungen für denselben Test müssen erneut eingereicht werden.
+// Transaktionen, die den Kopf schreiben (Transfer, Sonderfreigaben, Transportalarm, …),
+// führen weiterhin zu MVCC-Konflikten mit allen gleichzeitigen Transaktionen auf dem DPP.
+
+const (
+	qualityObjectType   = "DPP~quality"
+	eventObjectType     = "DPP~event"
+	transportObjectType = "DPP~transport"
+	checkObjectType     = "DPP~check"
+)
+
+// pendingRecord ist ein noch nicht geschriebener Einzel-Datensatz eines DPP.
+type pendingRecord struct {
+	objectType string
+	seq        string
+	value      interface{}
+}
+
+// nextRecordSeq liefert den Sortierschlüssel für den nächsten Einzel-Datensatz der Transaktion.
+func (clk *txClock) nextRecordSeq() string {
+	clk.recordSeq++
+	return fmt.Sprintf("%020d-%s-%04d", clk.now.UnixNano(), clk.txID, clk.recordSeq)
+}
+
+// clearManagedFields verwirft vom Client übergebene Werte der Felder, die der Contract selbst
+// setzt (Schlüssel, Retest-Verkettung, Sonderfreigabe, vertrauliche Ablage).
+func (qe *QualityEntry) clearManagedFields() {
+	qe.EntryID, qe.RecordedTxID = "", ""
+	qe.Supersedes, qe.SupersededBy, qe.RetestReason, qe.Concession = "", "", "", nil
+	qe.PrivateDataHash, qe.PrivateCollection = "", ""
+}
+
+// addQualityEntry vergibt immer eine neue EntryID und merkt den Eintrag zum Schreiben vor.
+// Eine vom Client übergebene EntryID würde sonst einen vorhandenen Eintrag überschreiben.
+func (dpp *DPP) addQualityEntry(clk *txClock, qe *QualityEntry) {
+	qe.EntryID = clk.nextRecordSeq()
+	dpp.queueQualityEntry(clk, qe)
+}
+
+// queueQualityEntry merkt einen Eintrag vor, dessen EntryID bereits mit nextRecordSeq vergeben
+// wurde (vertrauliche Einträge, deren EntryID in den Hash der Collection eingeht).
+func (dpp *DPP) queueQualityEntry(clk *txClock, qe *QualityEntry) {
+	qe.RecordedTxID = clk.txID
+	dpp.Quality = append(dpp.Quality, *qe)
+	dpp.pending = append(dpp.pending, pendingRecord{objectType: qualityObjectType, seq: qe.EntryID, value: *qe})
+}
+
+// updateQualityEntry merkt einen bereits gespeicherten Eintrag zum Überschreiben vor
+// (z.B. um eine Sonderfreigabe am Eintrag zu vermerken).
+func (dpp *DPP) updateQualityEntry(qe QualityEntry) {
+	dpp.pending = append(dpp.pending, pendingRecord{objectType: qualityObjectType, seq: qe.EntryID, value: qe})
+}
+
+// addEvent hängt ein EPCIS-Event an und merkt es zum Schreiben vor.
+func (dpp *DPP) addEvent(clk *txClock, evt EPCISEvent) {
+	dpp.EPCISEvents = append(dpp.EPCISEvents, evt)
+	dpp.pending = append(dpp.pending, pendingRecord{objectType: eventObjectType, seq: clk.nextRecordSeq(), value: evt})
+}
+
+// addTransportEntry vergibt EntryID/RecordedTxID und merkt den Transport-Eintrag zum Schreiben vor.
+func (dpp *DPP) addTransportEntry(clk *txClock, entry *TransportConditionLogEntry) {
+	entry.EntryID = clk.nextRecordSeq()
+	entry.RecordedTxID = clk.txID
+	dpp.TransportLog = append(dpp.TransportLog, *entry)
+	dpp.pending = append(dpp.pending, pendingRecord{objectType: transportObjectType, seq: entry.EntryID, value: *entry})
+}
+
+// headerBytes serialisiert den DPP ohne Qualitätseinträge, Events und Transportprotokoll und
+// ohne die aus den Prüfständen abgeleiteten Zusammenfassungen.
+func (dpp *DPP) headerBytes() ([]byte, error) {
+	header := *dpp
+	header.OpenMandatoryChecks = nil
+	header.FailedTests = nil
+	header.DeviationTests = nil
+	header.Quality = nil
+	header.EPCISEvents = nil
+	header.TransportLog = nil
+	header.InheritedQuality = nil
+	return json.Marshal(header)
+}
+
+// readDPPHeader liest nur den DPP-Kopf. Für schreibende Transaktionen gedacht, damit keine
+// Range-Reads über Qualitätseinträge in das Read-Set gelangen.
+func (c *DPPQualityContract) readDPPHeader(ctx contractapi.TransactionContextInterface, dppID string) (*DPP, error) {
+	dppBytes, err := ctx.GetStub().GetState(dppPrefix + dppID)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen von DPP %s: %v", dppID, err)
+	}
+	if dppBytes == nil {
+		return nil, fmt.Errorf("DPP %s nicht gefunden", dppID)
+	}
+
+	var dpp DPP
+	if err := json.Unmarshal(dppBytes, &dpp); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling von DPP %s: %v", dppID, err)
+	}
+	dpp.storedHeader = dppBytes
+	if dpp.DocType == "" {
+		// Vor Einführung der Rich Queries angelegt; wird beim nächsten Schreiben ergänzt.
+		dpp.DocType = dppDocType
+	}
+	dpp.migrateLegacyStatus()
+	dpp.ensureDigitalLink()
+	dpp.migrateEmbeddedRecords()
+	dpp.migrateLegacyChecks()
+	return &dpp, nil
+}
+
+// migrateEmbeddedRecords überführt Qualitätseinträge und Events aus DPPs im alten Format
+// (alles in einem JSON-Dokument) beim nächsten Schreiben in eigene Schlüssel.
+func (dpp *DPP) migrateEmbeddedRecords() {
+	for i := range dpp.Quality {
+		qe := &dpp.Quality[i]
+		if qe.EntryID == "" {
+			qe.EntryID = fmt.Sprintf("%020d-legacy-%04d", 0, i)
+		}
+		dpp.embedded = true
+		dpp.pending = append(dpp.pending, pendingRecord{objectType: qualityObjectType, seq: qe.EntryID, value: *qe})
+	}
+	for i, evt := range dpp.EPCISEvents {
+		dpp.embedded = true
+		dpp.pending = append(dpp.pending, pendingRecord{objectType: eventObjectType, seq: fmt.Sprintf("%020d-legacy-%04d", 0, i), value: evt})
+	}
+	for i := range dpp.TransportLog {
+		entry := &dpp.TransportLog[i]
+		if entry.EntryID == "" {
+			entry.EntryID = fmt.Sprintf("%020d-legacy-%04d", 0, i)
+		}
+		dpp.embedded = true
+		dpp.pending = append(dpp.pending, pendingRecord{objectType: transportObjectType, seq: entry.EntryID, value: *entry})
+	}
+}
+
+// readDPP liest den DPP-Kopf, setzt Qualitätseinträge und Events aus ihren Schlüsseln zusammen
+// und leitet den Status aus den Prüfständen ab.
+func (c *DPPQualityContract) readDPP(ctx contractapi.TransactionContextInterface, dppID string) (*DPP, error) {
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.deriveStatus(ctx, dpp); err != nil {
+		return nil, err
+	}
+	if dpp.embedded {
+		// Altes Format: Einträge stehen noch im Kopf-Dokument.
+		return dpp, nil
+	}
+
+	dpp.Quality = []QualityEntry{}
+	if err := forEachRecord(ctx, qualityObjectType, dppID, func(data []byte) error {
+		var qe QualityEntry
+		if err := json.Unmarshal(data, &qe); err != nil {
+			return err
+		}
+		dpp.Quality = append(dpp.Quality, qe)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen der Qualitätseinträge von DPP %s: %v", dppID, err)
+	}
+
+	dpp.EPCISEvents = []EPCISEvent{}
+	if err := forEachRecord(ctx, eventObjectType, dppID, func(data []byte) error {
+		var evt EPCISEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return err
+		}
+		dpp.EPCISEvents = append(dpp.EPCISEvents, evt)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen der EPCIS-Events von DPP %s: %v", dppID, err)
+	}
+
+	dpp.TransportLog = []TransportConditionLogEntry{}
+	if err := forEachRecord(ctx, transportObjectType, dppID, func(data []byte) error {
+		var entry TransportConditionLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		dpp.TransportLog = append(dpp.TransportLog, entry)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen des Transportprotokolls von DPP %s: %v", dppID, err)
+	}
+	if err := c.loadInheritedQuality(ctx, dpp); err != nil {
+		return nil, err
+	}
+	return dpp, nil
+}
+
+// readQualityEntry liest einen einzelnen Qualitätseintrag aus seinem Schlüssel.
+func readQualityEntry(ctx contractapi.TransactionContextInterface, dppID, entryID string) (*QualityEntry, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(qualityObjectType, []string{dppID, entryID})
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen von Eintrag %s: %v", entryID, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("Qualitätseintrag %s von DPP %s nicht gefunden", entryID, dppID)
+	}
+	var qe QualityEntry
+	if err := json.Unmarshal(data, &qe); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling von Eintrag %s: %v", entryID, err)
+	}
+	return &qe, nil
+}
+
+// forEachRecord iteriert in Schlüsselreihenfolge über alle Einzel-Datensätze eines DPP.
+func forEachRecord(ctx contractapi.TransactionContextInterface, objectType, dppID string, fn func(data []byte) error) error {
+	return forEachRecordKey(ctx, objectType, dppID, func(_ string, data []byte) error { return fn(data) })
+}
+
+// forEachRecordKey wie forEachRecord, übergibt zusätzlich die Sequenz aus dem Schlüssel.
+func forEachRecordKey(ctx contractapi.TransactionContextInterface, objectType, dppID string, fn func(seq string, data []byte) error) error {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{dppID})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil || len(attrs) != 2 {
+			return fmt.Errorf("ungültiger Schlüssel %s: %v", kv.Key, err)
+		}
+		if err := fn(attrs[1], kv.Value); err != nil {
+			return fmt.Errorf("%s: %v", kv.Key, err)
+		}
+	}
+	return nil
+}
+
+// scanChunkSize: Schlüssel je Range-Abfrage in scanCompositeKeys.
+const scanChunkSize = 100
+
+// scanCompositeKeys liest Composite Keys ab startKey (leer = ab Anfang des Präfixes) in
+// Schlüsselreihenfolge, bis fn false liefert. Bei Range-Abfragen ist das Bookmark von
+// GetStateByPartialCompositeKeyWithPagination der Startschlüssel; gelesen wird daher nur ab
+// startKey und nur so weit wie nötig. Wie alle paginierten Abfragen nur in evaluateTransaction.
+func scanCompositeKeys(ctx contractapi.TransactionContextInterface, objectType string, attrs []string, startKey string, fn func(key string, value []byte) (bool, error)) error {
+	bookmark := startKey
+	for {
+		iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attrs, scanChunkSize, bookmark)
+		if err != nil {
+			return err
+		}
+		done, err := func() (bool, error) {
+			defer iter.Close()
+			for iter.HasNext() {
+				kv, err := iter.Next()
+				if err != nil {
+					return true, err
+				}
+				next, err := fn(kv.Key, kv.Value)
+				if err != nil || !next {
+					return true, err
+				}
+			}
+			return false, nil
+		}()
+		if err != nil || done || meta.GetFetchedRecordsCount() < scanChunkSize || meta.GetBookmark() == "" {
+			return err
+		}
+		bookmark = meta.GetBookmark()
+	}
+}
+
+// saveDPP schreibt vorgemerkte Einzel-Datensätze und den Kopf, falls dieser sich geändert hat.
+func (c *DPPQualityContract) saveDPP(ctx contractapi.TransactionContextInterface, dpp *DPP) error {
+	for _, rec := range dpp.pending {
+		key, err := ctx.GetStub().CreateCompositeKey(rec.objectType, []string{dpp.DppID, rec.seq})
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(rec.value)
+		if err != nil {
+			return fmt.Errorf("Fehler beim Marshalling von %s für DPP %s: %v", rec.objectType, dpp.DppID, err)
+		}
+		if err := ctx.GetStub().PutState(key, data); err != nil {
+			return fmt.Errorf("PutState für %s fehlgeschlagen: %v", key, err)
+		}
+		if evt, ok := rec.value.(EPCISEvent); ok {
+			if err := putEventIndex(ctx, dpp.DppID, rec.seq, evt); err != nil {
+				return err
+			}
+		}
+	}
+	dpp.pending = nil
+	if dpp.indexDigitalLink {
+		if err := putDigitalLinkIndex(ctx, dpp); err != nil {
+			return err
+		}
+		dpp.indexDigitalLink = false
+	}
+
+	header, err := dpp.headerBytes()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Marshalling von DPP %s: %v", dpp.DppID, err)
+	}
+	if bytes.Equal(header, dpp.storedHeader) {
+		fmt.Printf("[saveDPP-DEBUG] Kopf von DPP %s unverändert, kein PutState.\n", dpp.DppID)
+		return nil
+	}
+	if err := ctx.GetStub().PutState(dppPrefix+dpp.DppID, header); err != nil {
+		return fmt.Errorf("PutState für DPP %s fehlgeschlagen: %v", dpp.DppID, err)
+	}
+	dpp.storedHeader = header
+	return nil
+}
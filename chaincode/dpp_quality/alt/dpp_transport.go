@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Transportbedingungen --------------------------- //
+//
+// Der Spezifikationssatz eines Produkttyps kann Grenzwerte für Transportbedingungen enthalten
+// (transportSpecifications, testName = logType, z.B. "temperature", "humidity", "shock").
+// CreateDPP übernimmt sie in den DPP. AddTransportUpdate bewertet jeden Messwert mit
+// derselben Logik wie Qualitätsdaten (evaluateResult). Bei einer Überschreitung wird
+// TransportAlert gesetzt; der Status (InTransit/ReturnInTransit) bleibt unverändert.
+// Nach dem Empfang bewertet der neue Eigentümer die Überschreitung und hebt den Alert mit
+// ClearTransportAlert auf; wer ihn wann mit welcher Begründung aufgehoben hat, bleibt in
+// TransportClearances erhalten.
+
+// TransportExcursion: Überschrittener Grenzwert eines Transport-Eintrags.
+type TransportExcursion struct {
+	Parameter string  `json:"parameter"`
+	Value     float64 `json:"value"`
+	Limit     float64 `json:"limit"`
+	LimitType string  `json:"limitType"` // "lower" oder "upper"
+	Unit      string  `json:"unit,omitempty" metadata:",optional"`
+}
+
+// TransportConditionLogEntry: Messwert oder Zusammenfassung eines Transport-Loggers.
+type TransportConditionLogEntry struct {
+	EntryID           string              `json:"entryId,omitempty"           metadata:",optional"` // Sortierschlüssel (DPP~transport~<dppId>~<entryId>)
+	RecordedTxID      string              `json:"recordedTxId,omitempty"      metadata:",optional"`
+	LogType           string              `json:"logType"`
+	Value             string              `json:"value"`
+	Unit              string              `json:"unit"`
+	MinValue          string              `json:"minValue,omitempty"          metadata:",optional"` // kleinster Wert im Log-Intervall (Einheit wie Value)
+	MaxValue          string              `json:"maxValue,omitempty"          metadata:",optional"` // größter Wert im Log-Intervall
+	Timestamp         string              `json:"timestamp"`
+	Status            string              `json:"status,omitempty"            metadata:",optional"` // vom Client gemeldeter Status, wird nicht ausgewertet
+	OffChainLogRef    string              `json:"offChainLogRef,omitempty"    metadata:",optional"`
+	OffChainLogHash   string              `json:"offChainLogHash,omitempty"   metadata:",optional"` // Hash der Log-Datei (hex), siehe VerifyOffChainData
+	HashAlgorithm     string              `json:"hashAlgorithm,omitempty"     metadata:",optional"`
+	ResponsibleSystem string              `json:"responsibleSystem,omitempty" metadata:",optional"`
+	EvaluationOutcome EvaluationOutcome   `json:"evaluationOutcome,omitempty" metadata:",optional"`
+	EvaluationComment string              `json:"evaluationComment,omitempty" metadata:",optional"`
+	NormalizedValue   string              `json:"normalizedValue,omitempty"   metadata:",optional"` // Value in der Einheit der Transportspezifikation
+	NormalizedUnit    string              `json:"normalizedUnit,omitempty"    metadata:",optional"`
+	Excursion         *TransportExcursion `json:"excursion,omitempty"         metadata:",optional"`
+}
+
+// TransportClearance: Aufhebung eines Transport-Alerts durch den Eigentümer nach dem Empfang.
+type TransportClearance struct {
+	Excursions   []string `json:"excursions"` // logTypes, deren Überschreitung bewertet wurde
+	Reason       string   `json:"reason"`
+	ClearedByMSP string   `json:"clearedByMsp"`
+	ClearedByID  string   `json:"clearedById"`
+	ClearedAt    string   `json:"clearedAt"`
+	ClearedTxID  string   `json:"clearedTxId"`
+	EventID      string   `json:"eventId"`
+}
+
+// parseTransportSpecifications liest die optionalen Transportgrenzwerte eines Spezifikationssatzes.
+// Transportbedingungen sind immer numerisch.
+func parseTransportSpecifications(transportSpecificationsJSON string) ([]QualitySpecification, error) {
+	if transportSpecificationsJSON == "" {
+		return nil, nil
+	}
+	var specs []QualitySpecification
+	if err := json.Unmarshal([]byte(transportSpecificationsJSON), &specs); err != nil {
+		return nil, fmt.Errorf("Transportspezifikationen JSON fehlerhaft: %v", err)
+	}
+	for _, s := range specs {
+		if !s.IsNumeric {
+			return nil, fmt.Errorf("Transportspezifikation '%s' muss numerisch sein (isNumeric)", s.TestName)
+		}
+	}
+	if err := validateSpecifications(specs); err != nil {
+		return nil, fmt.Errorf("Transportspezifikationen: %v", err)
+	}
+	return specs, nil
+}
+
+// transportSpecFor liefert die Transportspezifikation zu einem logType oder nil.
+func (dpp *DPP) transportSpecFor(logType string) *QualitySpecification {
+	for i := range dpp.TransportSpecifications {
+		if dpp.TransportSpecifications[i].TestName == logType {
+			return &dpp.TransportSpecifications[i]
+		}
+	}
+	return nil
+}
+
+// evaluateTransportEntry bewertet einen Transport-Eintrag und ermittelt ggf. den überschrittenen
+// Grenzwert. Neben value werden maxValue und minValue des Log-Intervalls geprüft: Liegt ein
+// Extremwert außerhalb der Grenzen, ist der Eintrag abweichend, auch wenn value innerhalb liegt,
+// und die Excursion nennt den Extremwert.
+func (dpp *DPP) evaluateTransportEntry(entry *TransportConditionLogEntry) {
+	spec := dpp.transportSpecFor(entry.LogType)
+	evaluate := func(value string) Evaluation {
+		return evaluateResult(spec, QualityEntry{TestName: entry.LogType, Result: value, Unit: entry.Unit})
+	}
+	ev := evaluate(entry.Value)
+	entry.EvaluationOutcome = ev.Outcome
+	entry.EvaluationComment = ev.Comment
+	entry.NormalizedValue = ev.NormalizedResult
+	entry.NormalizedUnit = ev.NormalizedUnit
+	entry.Excursion = nil
+	if ev.Outcome != OutcomePass && !ev.Outcome.isDeviation() {
+		return // NO_SPEC, INVALID_FORMAT oder UNIT_MISMATCH
+	}
+
+	// Der erste abweichende Wert in der Reihenfolge maxValue, minValue, value bestimmt die Excursion.
+	excursionEv, measured := ev, entry.Value
+	for _, extreme := range []struct{ name, value string }{{"maxValue", entry.MaxValue}, {"minValue", entry.MinValue}} {
+		if extreme.value == "" {
+			continue
+		}
+		if xev := evaluate(extreme.value); xev.Outcome.isDeviation() {
+			excursionEv, measured = xev, extreme.value
+			entry.EvaluationOutcome = xev.Outcome
+			entry.EvaluationComment = joinComments(ev.Comment, extreme.name+": "+xev.Comment)
+			break
+		}
+	}
+	if !excursionEv.Outcome.isDeviation() {
+		return
+	}
+	if excursionEv.NormalizedResult != "" {
+		measured = excursionEv.NormalizedResult
+	}
+	value, _ := strconv.ParseFloat(strings.TrimSpace(measured), 64)
+	lower, upper, _, _ := spec.bounds()
+	excursion := &TransportExcursion{Parameter: entry.LogType, Value: value, LimitType: "upper", Limit: upper, Unit: spec.Unit}
+	if excursionEv.Outcome == OutcomeDeviationLow {
+		excursion.LimitType, excursion.Limit = "lower", lower
+	}
+	entry.Excursion = excursion
+}
+
+// shipperMSP: Organisation, die den laufenden Transport ausgelöst hat (letzter Übergang nach
+// InTransit bzw. ReturnInTransit).
+func (dpp *DPP) shipperMSP() string {
+	for i := len(dpp.StatusHistory) - 1; i >= 0; i-- {
+		if dpp.StatusHistory[i].To.isInTransit() {
+			return dpp.StatusHistory[i].ChangedByMSP
+		}
+	}
+	return ""
+}
+
+// AddTransportUpdate: Erfasst einen Transport-Eintrag (z.B. Temperatur eines Loggers) für einen
+// DPP im Status InTransit oder ReturnInTransit. Versender und Empfänger dürfen Einträge
+// erfassen. Überschreitet der Wert die Transportspezifikation, wird TransportAlert gesetzt und
+// das Event "TransportAlert" mit Parameter, Wert und Grenzwert ausgelöst.
+func (c *DPPQualityContract) AddTransportUpdate(ctx contractapi.TransactionContextInterface, dppID string, transportUpdateEntryJSON string, siteGLN string) error {
+	fmt.Printf("[AddTransportUpdate-DEBUG] Entry: dppID=%s, siteGLN=%s\n", dppID, siteGLN)
+	if err := validateSiteGLN("siteGLN", siteGLN); err != nil {
+		return err
+	}
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return err
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	if !dpp.Status.isInTransit() {
+		return fmt.Errorf("DPP %s ist nicht im Transport (Status: %s)", dppID, dpp.Status)
+	}
+	if clientMSPID != dpp.OwnerOrg && clientMSPID != dpp.shipperMSP() {
+		return fmt.Errorf("Zugriff verweigert: nur Versender (%s) oder Empfänger (%s) dürfen Transportdaten für DPP %s erfassen, Aufrufer ist %s", dpp.shipperMSP(), dpp.OwnerOrg, dppID, clientMSPID)
+	}
+
+	var entry TransportConditionLogEntry
+	if err := json.Unmarshal([]byte(transportUpdateEntryJSON), &entry); err != nil {
+		return fmt.Errorf("TransportUpdateEntry JSON fehlerhaft: %v", err)
+	}
+	if entry.LogType == "" {
+		return fmt.Errorf("TransportUpdateEntry ohne logType")
+	}
+	for _, f := range []struct{ name, value string }{{"minValue", entry.MinValue}, {"maxValue", entry.MaxValue}} {
+		if _, ok := parseSensorValue(f.value); f.value != "" && !ok {
+			return fmt.Errorf("TransportUpdateEntry: %s '%s' ist keine Zahl", f.name, f.value)
+		}
+	}
+	if err := anchorTransportLogHash(&entry); err != nil {
+		return err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+	if entry.Timestamp == "" {
+		entry.Timestamp = clk.timestamp()
+	}
+	entry.EntryID = ""
+	dpp.evaluateTransportEntry(&entry)
+	dpp.addTransportEntry(clk, &entry)
+
+	// Der Messwert steht als sensorElementList im Event, der Eintrag selbst unter seinem Schlüssel.
+	extensions := map[string]interface{}{"transportLogEntryId": entry.EntryID, "evaluationOutcome": entry.EvaluationOutcome}
+	if entry.Excursion != nil {
+		extensions["transportExcursion"] = entry.Excursion
+	}
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clk.nextEventID("transport"),
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:transporting",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		Disposition:         "urn:epcglobal:cbv:disp:in_transit",
+		ReadPoint:           siteLocation(siteGLN),
+		BizLocation:         siteLocation(siteGLN),
+		SensorElementList:   sensorElements(transportSensorElement(entry)),
+		Extensions:          extensions,
+	})
+
+	if entry.Excursion != nil {
+		dpp.TransportAlert = true
+		dpp.TransportExcursions = addUnique(dpp.TransportExcursions, entry.LogType)
+		fmt.Printf("[AddTransportUpdate-INFO] Transport-Alert für DPP %s: %s = %s %s (%s)\n", dppID, entry.LogType, entry.Value, entry.Unit, entry.EvaluationComment)
+
+		alertPayload := map[string]interface{}{
+			"dppId":         dppID,
+			"gs1Key":        dpp.GS1Key,
+			"status":        dpp.Status,
+			"ownerOrg":      dpp.OwnerOrg,
+			"entryId":       entry.EntryID,
+			"parameter":     entry.Excursion.Parameter,
+			"value":         entry.Excursion.Value,
+			"limit":         entry.Excursion.Limit,
+			"limitType":     entry.Excursion.LimitType,
+			"unit":          entry.Excursion.Unit,
+			"timestamp":     entry.Timestamp,
+			"reportedByMsp": clientMSPID,
+		}
+		alertBytes, _ := json.Marshal(alertPayload)
+		if err := ctx.GetStub().SetEvent("TransportAlert", alertBytes); err != nil {
+			return fmt.Errorf("Fehler beim Setzen des TransportAlert-Events: %v", err)
+		}
+	}
+
+	return c.saveDPP(ctx, dpp)
+}
+
+// ClearTransportAlert: Der Eigentümer hebt nach dem Empfang einen Transport-Alert auf, z.B.
+// weil die Eingangsprüfung die Ware trotz Überschreitung für verwendbar befunden hat. reason
+// ist Pflicht. Während des Transports ist die Ware noch nicht beim Eigentümer und der Alert
+// kann nicht aufgehoben werden.
+func (c *DPPQualityContract) ClearTransportAlert(ctx contractapi.TransactionContextInterface, dppID, reason, siteGLN string) (*TransportClearance, error) {
+	fmt.Printf("[ClearTransportAlert-DEBUG] Entry: dppID=%s, siteGLN=%s\n", dppID, siteGLN)
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("reason darf nicht leer sein")
+	}
+	if err := validateSiteGLN("siteGLN", siteGLN); err != nil {
+		return nil, err
+	}
+	dpp, err := c.readDPPHeader(ctx, dppID)
+	if err != nil {
+		return nil, err
+	}
+	clientMSPID, err := requireOwner(ctx, dpp, "den Transport-Alert aufheben")
+	if err != nil {
+		return nil, err
+	}
+	if !dpp.TransportAlert {
+		return nil, fmt.Errorf("DPP %s hat keinen offenen Transport-Alert", dppID)
+	}
+	if dpp.Status == StatusInTransit || dpp.Status == StatusReturnInTransit {
+		return nil, fmt.Errorf("Transport-Alert von DPP %s kann erst nach dem Empfang aufgehoben werden (Status: %s)", dppID, dpp.Status)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client ID: %v", err)
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clearance := TransportClearance{
+		Excursions:   dpp.TransportExcursions,
+		Reason:       reason,
+		ClearedByMSP: clientMSPID,
+		ClearedByID:  clientID,
+		ClearedAt:    clk.timestamp(),
+		ClearedTxID:  clk.txID,
+		EventID:      clk.nextEventID("transport-clear"),
+	}
+	dpp.addEvent(clk, EPCISEvent{
+		EventID:             clearance.EventID,
+		EventType:           "ObjectEvent",
+		EventTime:           clk.timestamp(),
+		EventTimeZoneOffset: clk.tzOffset(),
+		BizStep:             "urn:epcglobal:cbv:bizstep:inspecting",
+		Action:              "OBSERVE",
+		EPCList:             []string{dpp.GS1Key},
+		ReadPoint:           siteLocation(siteGLN),
+		BizLocation:         siteLocation(siteGLN),
+		Extensions:          map[string]interface{}{"transportAlertCleared": clearance},
+	})
+	dpp.TransportAlert = false
+	dpp.TransportExcursions = nil
+	dpp.TransportClearances = append(dpp.TransportClearances, clearance)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"dppId":        dppID,
+		"gs1Key":       dpp.GS1Key,
+		"excursions":   clearance.Excursions,
+		"reason":       reason,
+		"clearedByMsp": clientMSPID,
+	})
+	if err := ctx.GetStub().SetEvent("TransportAlertCleared", payload); err != nil {
+		return nil, fmt.Errorf("Fehler beim Setzen des TransportAlertCleared-Events: %v", err)
+	}
+	fmt.Printf("[ClearTransportAlert-INFO] Transport-Alert von DPP %s (%s) durch %s aufgehoben: %s\n", dppID, strings.Join(clearance.Excursions, ", "), clientMSPID, reason)
+	if err := c.saveDPP(ctx, dpp); err != nil {
+		return nil, err
+	}
+	return &clearance, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// transportEnv: Freigegebener DPP T1 (Transportgrenzwerte 0 bis 8 Cel), an Org2MSP versandt.
+func transportEnv(t *testing.T) (*testEnv, *DPPQualityContract) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	_, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-T", retestSpecs,
+		`[{"testName":"temperature","isNumeric":true,"lowerLimit":0,"upperLimit":8,"unit":"Cel"}]`, "")
+	e.must(err)
+	_, err = c.CreateDPP(e.ctx("Org1MSP"), "T1", "urn:epc:id:sgtin:4012345.011111.5001", "PT-T", "4012345000016", "L1", "2025-06-01", 1000, "kg", 0)
+	e.must(err)
+	e.must(c.RecordQualityData(e.ctx("Org1MSP"), "T1", qualityJSON("MFI", "15", "g/10min"), ""))
+	e.must(c.TransferDPP(e.ctx("Org1MSP"), "T1", "Org2MSP", "4012345000016"))
+	return e, c
+}
+
+func TestTransportUpdateIntervalExtremes(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     string
+		want      EvaluationOutcome
+		limitType string
+		value     float64
+	}{
+		{"innerhalb", `{"logType":"temperature","value":"5","unit":"Cel","minValue":"2","maxValue":"7"}`, OutcomePass, "", 0},
+		{"Maximum über Grenze", `{"logType":"temperature","value":"5","unit":"Cel","minValue":"2","maxValue":"12"}`, OutcomeDeviationHigh, "upper", 12},
+		{"Minimum unter Grenze", `{"logType":"temperature","value":"5","unit":"Cel","minValue":"-2","maxValue":"7"}`, OutcomeDeviationLow, "lower", -2},
+		{"Wert und Maximum über Grenze", `{"logType":"temperature","value":"9","unit":"Cel","maxValue":"12"}`, OutcomeDeviationHigh, "upper", 12},
+		{"Maximum umgerechnet", `{"logType":"temperature","value":"278.15","unit":"K","maxValue":"285.15"}`, OutcomeDeviationHigh, "upper", 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, c := transportEnv(t)
+			e.must(c.AddTransportUpdate(e.ctx("Org1MSP"), "T1", tt.entry, ""))
+			dpp, err := c.QueryDPP(e.ctx("Org2MSP"), "T1")
+			e.must(err)
+			entry := dpp.TransportLog[len(dpp.TransportLog)-1]
+			if entry.EvaluationOutcome != tt.want {
+				t.Errorf("Ergebnis %s, erwartet %s (%s)", entry.EvaluationOutcome, tt.want, entry.EvaluationComment)
+			}
+			if dpp.TransportAlert != (tt.limitType != "") {
+				t.Errorf("TransportAlert %v", dpp.TransportAlert)
+			}
+			if tt.limitType == "" {
+				if entry.Excursion != nil {
+					t.Errorf("unerwartete Excursion %+v", *entry.Excursion)
+				}
+				return
+			}
+			if x := entry.Excursion; x == nil || x.LimitType != tt.limitType || x.Value != tt.value {
+				t.Fatalf("Excursion %+v, erwartet %s-Grenze mit Wert %v", x, tt.limitType, tt.value)
+			}
+			doc, err := c.ExportEPCISDocument(e.ctx("Org2MSP"), "T1")
+			e.must(err)
+			if !strings.Contains(doc, `"exception":"ALARM_CONDITION"`) {
+				t.Errorf("sensorReport ohne ALARM_CONDITION: %s", doc)
+			}
+		})
+	}
+}
+
+func TestClearTransportAlert(t *testing.T) {
+	e, c := transportEnv(t)
+	e.must(c.AddTransportUpdate(e.ctx("Org1MSP"), "T1", `{"logType":"temperature","value":"12","unit":"Cel"}`, ""))
+	drainChaincodeEvents(e)
+
+	if _, err := c.ClearTransportAlert(e.ctx("Org2MSP"), "T1", "Ware geprüft", ""); err == nil || !strings.Contains(err.Error(), "erst nach dem Empfang") {
+		t.Errorf("während des Transports: Fehler %v", err)
+	}
+	e.must(c.AcknowledgeReceiptAndRecordInspection(e.ctx("Org2MSP"), "T1", "4098765000010", ""))
+	if _, err := c.ClearTransportAlert(e.ctx("Org1MSP"), "T1", "Ware geprüft", ""); err == nil || !strings.Contains(err.Error(), "Zugriff verweigert") {
+		t.Errorf("früherer Eigentümer: Fehler %v", err)
+	}
+	if _, err := c.ClearTransportAlert(e.ctx("Org2MSP"), "T1", " ", ""); err == nil || !strings.Contains(err.Error(), "reason") {
+		t.Errorf("ohne Begründung: Fehler %v", err)
+	}
+
+	clearance, err := c.ClearTransportAlert(e.ctx("Org2MSP"), "T1", "Eingangsprüfung MFI im Sollbereich", "4098765000010")
+	e.must(err)
+	dpp, err := c.QueryDPP(e.ctx("Org2MSP"), "T1")
+	e.must(err)
+	if dpp.TransportAlert || len(dpp.TransportExcursions) != 0 {
+		t.Errorf("Alert nicht aufgehoben: %v %v", dpp.TransportAlert, dpp.TransportExcursions)
+	}
+	if len(dpp.TransportClearances) != 1 {
+		t.Fatalf("%d Aufhebungen gespeichert", len(dpp.TransportClearances))
+	}
+	got := dpp.TransportClearances[0]
+	if got.ClearedByMSP != "Org2MSP" || got.ClearedByID != "x509::CN=user@Org2MSP" || got.Reason != clearance.Reason ||
+		len(got.Excursions) != 1 || got.Excursions[0] != "temperature" || got.ClearedTxID == "" {
+		t.Errorf("Aufhebung %+v", got)
+	}
+	if last := dpp.EPCISEvents[len(dpp.EPCISEvents)-1]; last.EventID != got.EventID || last.Extensions["transportAlertCleared"] == nil {
+		t.Errorf("letztes Event %s ohne transportAlertCleared", last.EventID)
+	}
+	if events := drainChaincodeEvents(e); len(events["TransportAlertCleared"]) != 1 {
+		t.Errorf("Chaincode-Events %v", events)
+	}
+	if _, err := c.ClearTransportAlert(e.ctx("Org2MSP"), "T1", "nochmal", ""); err == nil || !strings.Contains(err.Error(), "keinen offenen Transport-Alert") {
+		t.Errorf("zweite Aufhebung: Fehler %v", err)
+	}
+}
+
+func TestClearTransportAlertSplitChildren(t *testing.T) {
+	e, c := transportEnv(t)
+	e.must(c.AddTransportUpdate(e.ctx("Org1MSP"), "T1", `{"logType":"temperature","value":"12","unit":"Cel"}`, ""))
+	e.must(c.AcknowledgeReceiptAndRecordInspection(e.ctx("Org2MSP"), "T1", "4098765000010", ""))
+	_, err := c.SplitDPP(e.ctx("Org2MSP"), "T1", `[{"dppId":"T1-1","gs1Key":"urn:epc:id:sgtin:4012345.011111.5011","quantity":400},{"dppId":"T1-2","gs1Key":"urn:epc:id:sgtin:4012345.011111.5012","quantity":600}]`, "")
+	e.must(err)
+
+	// Jeder Teil-DPP übernimmt den offenen Alert und wird für sich bewertet.
+	_, err = c.ClearTransportAlert(e.ctx("Org2MSP"), "T1-1", "Teilmenge geprüft", "")
+	e.must(err)
+	for id, alert := range map[string]bool{"T1-1": false, "T1-2": true} {
+		dpp, err := c.QueryDPP(e.ctx("Org2MSP"), id)
+		e.must(err)
+		if dpp.TransportAlert != alert {
+			t.Errorf("%s: TransportAlert %v, erwartet %v", id, dpp.TransportAlert, alert)
+		}
+	}
+}
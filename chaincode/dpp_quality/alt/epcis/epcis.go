@@ -0,0 +1,217 @@
+// Package epcis erzeugt EPCIS 2.0 Dokumente im JSON-LD-Format (EPCISDocument) aus den
+// Events des DPP-Chaincodes.
+//
+// Der Chaincode speichert Events mit URN-Vokabular (urn:epcglobal:cbv:bizstep:...) und einer
+// freien Extensions-Map. Für den Export werden
+//
+//   - bizStep und disposition in die CBV-2.0-Web-URIs (https://ref.gs1.org/cbv/...) umgesetzt,
+//   - readPoint und bizLocation als Objekte {"id": ...} ausgegeben,
+//   - Event-IDs, die keine URI sind, in eine URN umgewandelt und
+//   - alle Erweiterungen, auch verschachtelte Felder, mit dem Präfix "dpp:" versehen, das im
+//     @context des Dokuments auf Namespace abgebildet wird.
+//
+// Die Tests prüfen die Ausgabe gegen das EPCIS 2.0 JSON-Schema in testdata.
+package epcis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// SchemaVersion ist die EPCIS-Version der erzeugten Dokumente.
+	SchemaVersion = "2.0"
+	// ContextURL ist der Standard-@context von EPCIS 2.0.
+	ContextURL = "https://ref.gs1.org/standards/epcis/epcis-context.jsonld"
+	// Prefix ist das JSON-LD-Präfix der DPP-Erweiterungen.
+	Prefix = "dpp"
+	// Namespace ist die IRI, auf die Prefix im @context abgebildet wird.
+	Namespace = "https://github.com/SilvStei/QualityUseCase/epcis#"
+
+	cbvBase       = "https://ref.gs1.org/cbv/"
+	eventIDPrefix = "urn:dpp-quality:event:"
+)
+
+// Document ist ein EPCISDocument mit allen Events eines DPP.
+type Document struct {
+	Context       []interface{} `json:"@context"`
+	Type          string        `json:"type"`
+	SchemaVersion string        `json:"schemaVersion"`
+	CreationDate  string        `json:"creationDate"`
+	EPCISBody     Body          `json:"epcisBody"`
+}
+
+// Body enthält die Event-Liste des Dokuments.
+type Body struct {
+	EventList []Event `json:"eventList"`
+}
+
+// NewDocument legt ein EPCISDocument mit Standard-@context und DPP-Namespace an.
+// creationDate im Format RFC3339.
+func NewDocument(creationDate string, events []Event) *Document {
+	if events == nil {
+		events = []Event{}
+	}
+	return &Document{
+		Context:       []interface{}{ContextURL, map[string]string{Prefix: Namespace}},
+		Type:          "EPCISDocument",
+		SchemaVersion: SchemaVersion,
+		CreationDate:  creationDate,
+		EPCISBody:     Body{EventList: events},
+	}
+}
+
+// Location ist readPoint bzw. bizLocation eines Events.
+type Location struct {
+	ID string `json:"id"`
+}
+
+// Event ist ein EPCIS 2.0 Event. Extensions enthält Erweiterungen mit lokalen Namen
+// (ohne Präfix); beim Serialisieren werden sie mit "dpp:" in das Event übernommen.
+type Event struct {
+	Type                string    `json:"type"`
+	EventID             string    `json:"eventID,omitempty"`
+	EventTime           string    `json:"eventTime"`
+	EventTimeZoneOffset string    `json:"eventTimeZoneOffset"`
+	ParentID            string    `json:"parentID,omitempty"`
+	EPCList             []string  `json:"epcList,omitempty"`
+	ChildEPCs           []string  `json:"childEPCs,omitempty"`
+	InputEPCList        []string  `json:"inputEPCList,omitempty"`
+	OutputEPCList       []string  `json:"outputEPCList,omitempty"`
+	Action              string    `json:"action,omitempty"`
+	BizStep             string    `json:"bizStep,omitempty"`
+	Disposition         string    `json:"disposition,omitempty"`
+	ReadPoint           *Location `json:"readPoint,omitempty"`
+	BizLocation         *Location `json:"bizLocation,omitempty"`
+
+	SensorElementList []SensorElement `json:"sensorElementList,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON serialisiert das Event und hängt die Erweiterungen (sortiert) mit Präfix an.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type plain Event
+	return marshalWithExtensions(plain(e), e.Extensions)
+}
+
+// marshalWithExtensions serialisiert v (ein JSON-Objekt) und hängt die Erweiterungen mit
+// Präfix in alphabetischer Reihenfolge an.
+func marshalWithExtensions(v interface{}, extensions map[string]interface{}) ([]byte, error) {
+	base, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(extensions) == 0 {
+		return base, nil
+	}
+
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(base[:len(base)-1])
+	for i, name := range names {
+		value, err := namespaced(extensions[name])
+		if err != nil {
+			return nil, fmt.Errorf("Erweiterung %s: %v", name, err)
+		}
+		key, _ := json.Marshal(Term(name))
+		if i > 0 || len(base) > 2 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Term liefert den Namen einer Erweiterung mit Präfix. Bereits kompakte IRIs ("gs1:...")
+// und JSON-LD-Schlüsselwörter ("@id") bleiben unverändert.
+func Term(name string) string {
+	if strings.Contains(name, ":") || strings.HasPrefix(name, "@") {
+		return name
+	}
+	return Prefix + ":" + name
+}
+
+// namespaced serialisiert einen Erweiterungswert und versieht alle Objektschlüssel mit Präfix.
+func namespaced(value interface{}) ([]byte, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(prefixKeys(generic))
+}
+
+func prefixKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[Term(k)] = prefixKeys(item)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = prefixKeys(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// BizStep liefert die CBV-Web-URI eines Geschäftsschritts ("urn:epcglobal:cbv:bizstep:shipping"
+// oder "shipping" -> "https://ref.gs1.org/cbv/BizStep-shipping"). Andere URIs bleiben unverändert.
+func BizStep(s string) string {
+	return cbvURI(s, "urn:epcglobal:cbv:bizstep:", "BizStep-")
+}
+
+// Disposition liefert die CBV-Web-URI einer Disposition ("urn:epcglobal:cbv:disp:active"
+// oder "active" -> "https://ref.gs1.org/cbv/Disp-active").
+func Disposition(s string) string {
+	return cbvURI(s, "urn:epcglobal:cbv:disp:", "Disp-")
+}
+
+func cbvURI(s, urnPrefix, webPrefix string) string {
+	switch {
+	case s == "":
+		return ""
+	case strings.HasPrefix(s, urnPrefix):
+		return cbvBase + webPrefix + strings.TrimPrefix(s, urnPrefix)
+	case !strings.Contains(s, ":"):
+		return cbvBase + webPrefix + s
+	}
+	return s
+}
+
+// EventID liefert die Event-ID als URI; Chaincode-IDs wie "evt-create-<tx>-1" werden zu
+// "urn:dpp-quality:event:evt-create-<tx>-1".
+func EventID(id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+	return eventIDPrefix + id
+}
+
+// NewLocation liefert readPoint/bizLocation; nil bei leerer ID.
+func NewLocation(id string) *Location {
+	if id == "" {
+		return nil
+	}
+	return &Location{ID: id}
+}
@@ -0,0 +1,247 @@
+package epcis
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// schemaPath: Offizielles EPCIS 2.0 JSON-Schema von GS1, eingecheckt mit
+// testdata/fetch-epcis-schema.sh.
+var schemaPath = filepath.Join("testdata", "epcis-json-schema.json")
+
+func loadSchema(t *testing.T) *gojsonschema.Schema {
+	t.Helper()
+	if _, err := os.Stat(schemaPath); err != nil {
+		t.Fatalf("offizielles Schema %s fehlt, mit testdata/fetch-epcis-schema.sh laden und einchecken: %v", schemaPath, err)
+	}
+	abs, err := filepath.Abs(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(abs)))
+	if err != nil {
+		t.Fatalf("Schema %s nicht ladbar: %v", schemaPath, err)
+	}
+	return schema
+}
+
+func validate(t *testing.T, schema *gojsonschema.Schema, doc []byte) []string {
+	t.Helper()
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(doc))
+	if err != nil {
+		t.Fatalf("Validierung fehlgeschlagen: %v", err)
+	}
+	var errs []string
+	for _, e := range result.Errors() {
+		errs = append(errs, e.String())
+	}
+	return errs
+}
+
+func float(v float64) *float64 { return &v }
+
+// sampleEvents: Events, wie der Chaincode sie für Anlage, Qualitätsprüfung, Transformation,
+// Aufteilung, Versand und Transport schreibt.
+func sampleEvents() []Event {
+	hash := "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+	quality := map[string]interface{}{
+		"testName":          "MFI",
+		"result":            "15.2",
+		"unit":              "g/10min",
+		"evaluationOutcome": "PASS",
+		"statistics":        map[string]interface{}{"count": 3, "mean": 15.2},
+	}
+	return []Event{
+		{
+			Type:                "ObjectEvent",
+			EventID:             EventID("evt-create-tx1-1"),
+			EventTime:           "2025-06-01T08:00:00Z",
+			EventTimeZoneOffset: "+00:00",
+			EPCList:             []string{"urn:epc:id:sgtin:4012345.011111.1001"},
+			Action:              "ADD",
+			BizStep:             BizStep("urn:epcglobal:cbv:bizstep:commissioning"),
+			Disposition:         Disposition("urn:epcglobal:cbv:disp:active"),
+			ReadPoint:           NewLocation("urn:epc:id:sgln:4012345.00001.0"),
+			BizLocation:         NewLocation("urn:epc:id:sgln:4012345.00001.0"),
+			Extensions:          map[string]interface{}{"specificationSet": map[string]interface{}{"productTypeId": "PT-1", "version": 1}},
+		},
+		{
+			Type:                "ObjectEvent",
+			EventID:             EventID("evt-qc-MFI-tx2-1"),
+			EventTime:           "2025-06-01T09:00:00Z",
+			EventTimeZoneOffset: "+00:00",
+			EPCList:             []string{"urn:epc:id:sgtin:4012345.011111.1001"},
+			Action:              "OBSERVE",
+			BizStep:             BizStep("urn:epcglobal:cbv:bizstep:inspecting"),
+			Disposition:         Disposition("urn:epcglobal:cbv:disp:conformant"),
+			SensorElementList: []SensorElement{{
+				SensorMetadata: &SensorMetadata{Time: "2025-06-01T08:55:00Z", RawData: NamedInformationURI(hash), Extensions: map[string]interface{}{"rawDataRef": "lab/mfi-1001.csv", "rawDataHash": hash}},
+				SensorReport:   []SensorReport{{Type: SensorType("MFI"), MinValue: float(15.0), MaxValue: float(15.4), MeanValue: float(15.2), SDev: float(0.2), Extensions: map[string]interface{}{"ucumUnit": "g/10min"}}},
+			}},
+			Extensions: map[string]interface{}{"recordedQualityData": quality},
+		},
+		{
+			Type:                "TransformationEvent",
+			EventID:             EventID("evt-split-tx3-1"),
+			EventTime:           "2025-06-02T10:00:00Z",
+			EventTimeZoneOffset: "+00:00",
+			InputEPCList:        []string{"urn:epc:id:sgtin:4012345.011111.1001"},
+			OutputEPCList:       []string{"urn:epc:id:sgtin:4012345.011111.1002", "urn:epc:id:sgtin:4012345.011111.1003"},
+			BizStep:             BizStep("urn:epcglobal:cbv:bizstep:repackaging"),
+			Extensions: map[string]interface{}{"splitQuantities": []map[string]interface{}{
+				{"epc": "urn:epc:id:sgtin:4012345.011111.1002", "quantity": 500, "uom": "kg"},
+			}},
+		},
+		{
+			Type:                "AssociationEvent",
+			EventID:             EventID("evt-assoc-tx4-1"),
+			EventTime:           "2025-06-02T11:00:00Z",
+			EventTimeZoneOffset: "+00:00",
+			ParentID:            "urn:epc:id:sscc:4012345.0000000001",
+			ChildEPCs:           []string{"urn:epc:id:sgtin:4012345.011111.1002"},
+			Action:              "ADD",
+			BizStep:             BizStep("packing"),
+		},
+		{
+			Type:                "ObjectEvent",
+			EventID:             EventID("evt-ship-tx5-1"),
+			EventTime:           "2025-06-03T07:30:00Z",
+			EventTimeZoneOffset: "+00:00",
+			EPCList:             []string{"urn:epc:id:sgtin:4012345.011111.1002"},
+			Action:              "OBSERVE",
+			BizStep:             BizStep("urn:epcglobal:cbv:bizstep:shipping"),
+			Disposition:         Disposition("urn:epcglobal:cbv:disp:in_transit"),
+			Extensions:          map[string]interface{}{"intendedRecipientMSP": "Org2MSP"},
+		},
+		{
+			Type:                "ObjectEvent",
+			EventID:             EventID("evt-transport-tx6-1"),
+			EventTime:           "2025-06-03T12:00:00Z",
+			EventTimeZoneOffset: "+00:00",
+			EPCList:             []string{"urn:epc:id:sgtin:4012345.011111.1002"},
+			Action:              "OBSERVE",
+			BizStep:             BizStep("urn:epcglobal:cbv:bizstep:transporting"),
+			Disposition:         Disposition("urn:epcglobal:cbv:disp:in_transit"),
+			SensorElementList: []SensorElement{{
+				SensorMetadata: &SensorMetadata{Time: "2025-06-03T11:59:00Z"},
+				SensorReport:   []SensorReport{{Type: SensorType("temperature"), Exception: AlarmCondition, Value: float(0), MinValue: float(-1.5), MaxValue: float(9.2), UOM: UOM("Cel")}},
+			}},
+			Extensions: map[string]interface{}{"transportLogEntryId": "0000000001-0001"},
+		},
+	}
+}
+
+func TestDocumentValidatesAgainstSchema(t *testing.T) {
+	schema := loadSchema(t)
+	doc, err := json.Marshal(NewDocument("2025-06-04T12:00:00Z", sampleEvents()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := validate(t, schema, doc); len(errs) > 0 {
+		t.Fatalf("Dokument ist kein gültiges EPCIS 2.0 Dokument:\n%s\n%s", strings.Join(errs, "\n"), doc)
+	}
+}
+
+func TestEmptyDocumentValidatesAgainstSchema(t *testing.T) {
+	doc, err := json.Marshal(NewDocument("2025-06-04T12:00:00Z", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := validate(t, loadSchema(t), doc); len(errs) > 0 {
+		t.Fatalf("leeres Dokument ungültig: %v", errs)
+	}
+}
+
+// TestSchemaRejectsChaincodeFormat stellt sicher, dass das Schema die bisherigen Events
+// (freie Extensions-Map, Event-ID ohne URI) tatsächlich ablehnt.
+func TestSchemaRejectsChaincodeFormat(t *testing.T) {
+	schema := loadSchema(t)
+	invalid := map[string]string{
+		"Extensions-Map": `{"type":"ObjectEvent","eventTime":"2025-06-01T08:00:00Z","eventTimeZoneOffset":"+00:00","action":"OBSERVE","extensions":{"recordedQualityData":{}}}`,
+		"Event-ID":       `{"type":"ObjectEvent","eventID":"evt-qc-1","eventTime":"2025-06-01T08:00:00Z","eventTimeZoneOffset":"+00:00","action":"OBSERVE"}`,
+		"action":         `{"type":"TransformationEvent","eventTime":"2025-06-01T08:00:00Z","eventTimeZoneOffset":"+00:00","action":"ADD"}`,
+		"readPoint":      `{"type":"ObjectEvent","eventTime":"2025-06-01T08:00:00Z","eventTimeZoneOffset":"+00:00","action":"OBSERVE","readPoint":"urn:epc:id:sgln:4012345.00001.0"}`,
+		"Zeitzone":       `{"type":"ObjectEvent","eventTime":"2025-06-01T08:00:00Z","eventTimeZoneOffset":"UTC","action":"OBSERVE"}`,
+	}
+	for name, event := range invalid {
+		doc := `{"@context":["` + ContextURL + `"],"type":"EPCISDocument","schemaVersion":"2.0","creationDate":"2025-06-04T12:00:00Z","epcisBody":{"eventList":[` + event + `]}}`
+		if errs := validate(t, schema, []byte(doc)); len(errs) == 0 {
+			t.Errorf("%s: ungültiges Event wurde akzeptiert: %s", name, event)
+		}
+	}
+}
+
+func TestExtensionsAreNamespaced(t *testing.T) {
+	data, err := json.Marshal(sampleEvents()[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	quality, ok := event["dpp:recordedQualityData"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("dpp:recordedQualityData fehlt: %s", data)
+	}
+	if quality["dpp:testName"] != "MFI" {
+		t.Errorf("verschachtelte Felder ohne Präfix: %s", data)
+	}
+	stats, _ := quality["dpp:statistics"].(map[string]interface{})
+	if stats["dpp:mean"] != 15.2 {
+		t.Errorf("dpp:statistics/dpp:mean fehlt: %s", data)
+	}
+	if _, ok := event["extensions"]; ok {
+		t.Errorf("Event enthält weiterhin die Extensions-Map: %s", data)
+	}
+}
+
+func TestCBVURIs(t *testing.T) {
+	cases := []struct{ got, want string }{
+		{BizStep("urn:epcglobal:cbv:bizstep:inspecting"), "https://ref.gs1.org/cbv/BizStep-inspecting"},
+		{BizStep("shipping"), "https://ref.gs1.org/cbv/BizStep-shipping"},
+		{BizStep("https://example.com/bizstep/mixing"), "https://example.com/bizstep/mixing"},
+		{Disposition("urn:epcglobal:cbv:disp:non_conformant"), "https://ref.gs1.org/cbv/Disp-non_conformant"},
+		{Disposition(""), ""},
+		{EventID("evt-create-tx1-1"), "urn:dpp-quality:event:evt-create-tx1-1"},
+		{EventID("ni:///sha-256;abc?ver=CBV2.0"), "ni:///sha-256;abc?ver=CBV2.0"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("erhalten %q, erwartet %q", c.got, c.want)
+		}
+	}
+}
+
+func TestSensorReport(t *testing.T) {
+	data, err := json.Marshal(sampleEvents()[5].SensorElementList[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"sensorMetadata":{"time":"2025-06-03T11:59:00Z"},"sensorReport":[{"type":"gs1:MT-Temperature","exception":"ALARM_CONDITION","value":0,"minValue":-1.5,"maxValue":9.2,"uom":"CEL"}]}`
+	if string(data) != want {
+		t.Errorf("erhalten %s, erwartet %s", data, want)
+	}
+}
+
+func TestSensorVocabulary(t *testing.T) {
+	cases := []struct{ got, want string }{
+		{UOM("Cel"), "CEL"},
+		{UOM("g/10min"), ""},
+		{SensorType("Temperature"), "gs1:MT-Temperature"},
+		{SensorType("MFI"), "dpp:MFI"},
+		{SensorType("Zug festigkeit"), "dpp:Zug_festigkeit"},
+		{NamedInformationURI("7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"), "ni:///sha-256;f4OxZX_x_FO5LcGBSKHWXfwtSx-j1ncoSt3SABJtkGk"},
+		{NamedInformationURI("abc"), ""},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("erhalten %q, erwartet %q", c.got, c.want)
+		}
+	}
+}
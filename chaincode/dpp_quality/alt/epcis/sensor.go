@@ -0,0 +1,128 @@
+package epcis
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// SensorElement ist ein Eintrag der sensorElementList: Metadaten der Messung (Zeit,
+// Rohdaten) und mindestens ein sensorReport.
+type SensorElement struct {
+	SensorMetadata *SensorMetadata `json:"sensorMetadata,omitempty"`
+	SensorReport   []SensorReport  `json:"sensorReport"`
+}
+
+// SensorMetadata beschreibt die Messung. RawData ist eine URI der Rohdatendatei, z.B. der
+// ni-URI ihres Hashes (siehe NamedInformationURI).
+type SensorMetadata struct {
+	Time    string `json:"time,omitempty"`
+	RawData string `json:"rawData,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON hängt die Erweiterungen (z.B. rawDataHash) mit Präfix an.
+func (m SensorMetadata) MarshalJSON() ([]byte, error) {
+	type plain SensorMetadata
+	return marshalWithExtensions(plain(m), m.Extensions)
+}
+
+// SensorReport ist ein Messwert bzw. eine Zusammenfassung von Messwerten. Zeiger, damit ein
+// Messwert 0 ausgegeben wird; uom ist ein Code nach UN/CEFACT Rec 20 (siehe UOM).
+type SensorReport struct {
+	Type      string   `json:"type"`
+	Exception string   `json:"exception,omitempty"`
+	Value     *float64 `json:"value,omitempty"`
+	MinValue  *float64 `json:"minValue,omitempty"`
+	MaxValue  *float64 `json:"maxValue,omitempty"`
+	MeanValue *float64 `json:"meanValue,omitempty"`
+	SDev      *float64 `json:"sDev,omitempty"`
+	UOM       string   `json:"uom,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON hängt die Erweiterungen (z.B. ucumUnit) mit Präfix an.
+func (r SensorReport) MarshalJSON() ([]byte, error) {
+	type plain SensorReport
+	return marshalWithExtensions(plain(r), r.Extensions)
+}
+
+// Werte von sensorReport.exception.
+const (
+	AlarmCondition = "ALARM_CONDITION"
+	ErrorCondition = "ERROR_CONDITION"
+)
+
+// rec20Codes: UCUM-Code -> UN/CEFACT Rec 20 für die im Projekt verwendeten Einheiten.
+var rec20Codes = map[string]string{
+	"Cel":    "CEL",
+	"[degF]": "FAH",
+	"K":      "KEL",
+	"%":      "P1",
+	"[ppm]":  "59",
+	"mg":     "MGM",
+	"g":      "GRM",
+	"kg":     "KGM",
+	"t":      "TNE",
+	"Pa":     "PAL",
+	"hPa":    "A97",
+	"kPa":    "KPA",
+	"mbar":   "MBR",
+	"bar":    "BAR",
+	"m/s2":   "MSK",
+	"Hz":     "HTZ",
+	"V":      "VLT",
+	"W":      "WTT",
+	"J":      "JOU",
+	"mL":     "MLT",
+	"L":      "LTR",
+	"l":      "LTR",
+	"m":      "MTR",
+	"s":      "SEC",
+	"min":    "MIN",
+	"h":      "HUR",
+	"d":      "DAY",
+}
+
+// UOM liefert den Rec-20-Code einer UCUM-Einheit oder "", wenn es keinen gibt (z.B. g/10min).
+// Die UCUM-Einheit wird dann nur als Erweiterung ucumUnit ausgegeben.
+func UOM(ucumCode string) string {
+	return rec20Codes[strings.ReplaceAll(ucumCode, " ", "")]
+}
+
+// sensorTypes: Bezeichnungen von Messgrößen (logType/testName, klein geschrieben) ->
+// CBV-Messgröße.
+var sensorTypes = map[string]string{
+	"temperature":       "gs1:MT-Temperature",
+	"temperatur":        "gs1:MT-Temperature",
+	"humidity":          "gs1:MT-Humidity",
+	"relative humidity": "gs1:MT-Humidity",
+	"feuchtigkeit":      "gs1:MT-Humidity",
+	"luftfeuchtigkeit":  "gs1:MT-Humidity",
+	"shock":             "gs1:MT-Acceleration",
+	"acceleration":      "gs1:MT-Acceleration",
+	"erschütterung":     "gs1:MT-Acceleration",
+	"pressure":          "gs1:MT-Pressure",
+	"druck":             "gs1:MT-Pressure",
+}
+
+// SensorType liefert den sensorReport.type zu einer Messgröße: die CBV-Messgröße, falls
+// bekannt, sonst einen Begriff im DPP-Namespace ("MFI" -> "dpp:MFI").
+func SensorType(name string) string {
+	if t, ok := sensorTypes[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return t
+	}
+	return Term(strings.NewReplacer(" ", "_", "/", "_", ":", "_").Replace(strings.TrimSpace(name)))
+}
+
+// NamedInformationURI liefert den ni-URI (RFC 6920) eines hex-kodierten SHA-256,
+// z.B. "ni:///sha-256;f4OxZX_x...". "" bei ungültigem Hash.
+func NamedInformationURI(sha256Hex string) string {
+	sum, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(sum) != 32 {
+		return ""
+	}
+	return "ni:///sha-256;" + base64.RawURLEncoding.EncodeToString(sum)
+}
@@ -0,0 +1,11 @@
+#!/bin/sh
+# Lädt das offizielle EPCIS 2.0 JSON-Schema von GS1 nach testdata/epcis-json-schema.json.
+# Die Tests in epcis und im Chaincode validieren immer gegen diese Datei; sie wird mit
+# eingecheckt und nur zum Aktualisieren neu geladen.
+set -e
+cd "$(dirname "$0")"
+URL="https://ref.gs1.org/standards/epcis/epcis-json-schema.json"
+curl -fsSL "$URL" -o epcis-json-schema.json.tmp
+grep -q '"EPCISDocument"' epcis-json-schema.json.tmp || { echo "Unerwarteter Inhalt von $URL" >&2; rm -f epcis-json-schema.json.tmp; exit 1; }
+mv epcis-json-schema.json.tmp epcis-json-schema.json
+echo "Offizielles Schema gespeichert: $(pwd)/epcis-json-schema.json"
@@ -0,0 +1,247 @@
+package gs1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Resolver ist die Domain der von EPC.DigitalLink erzeugten URIs (GS1-Resolver).
+const Resolver = "https://id.gs1.org"
+
+// Application Identifier der unterstützten Digital-Link-Schlüssel und -Qualifier.
+const (
+	AISSCC         = "00"
+	AIGTIN         = "01"
+	AIBatch        = "10"
+	AISerial       = "21"
+	AICPV          = "22"
+	AIGLNExtension = "254"
+	AIGLN          = "414"
+	AIGRAI         = "8003"
+)
+
+// qualifiers: erlaubte Schlüssel-Qualifier je Primärschlüssel.
+var qualifiers = map[string][]string{
+	AIGTIN: {AICPV, AIBatch, AISerial},
+	AISSCC: nil,
+	AIGLN:  {AIGLNExtension},
+	AIGRAI: nil,
+}
+
+// DigitalLink ist ein zerlegter GS1 Digital Link URI. Key ist der Primärschlüssel (GTIN-14,
+// SSCC, GLN oder GRAI mit Seriennummer), die Qualifier stehen dekodiert in Batch, Serial,
+// Extension bzw. CPV.
+type DigitalLink struct {
+	AI        string
+	Key       string
+	CPV       string
+	Batch     string
+	Serial    string
+	Extension string
+}
+
+// ParseDigitalLink zerlegt einen Digital Link URI beliebiger Domain, z.B.
+// "https://id.gs1.org/01/04012345111118/10/L1/21/1001". Vor dem Primärschlüssel darf ein
+// Pfad-Präfix stehen, Query-Parameter (Datenattribute) werden ignoriert.
+func ParseDigitalLink(uri string) (*DigitalLink, error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("'%s' ist kein GS1 Digital Link (http(s)://<domain>/<AI>/<Schlüssel>...)", uri)
+	}
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(u.EscapedPath(), "/"), "/") {
+		decoded, err := url.PathUnescape(s)
+		if err != nil {
+			return nil, fmt.Errorf("Digital Link '%s': ungültige Kodierung in '%s'", uri, s)
+		}
+		segments = append(segments, decoded)
+	}
+	start := -1
+	for i, s := range segments {
+		if _, ok := qualifiers[s]; ok {
+			start = i
+			break
+		}
+	}
+	if start < 0 || (len(segments)-start)%2 != 0 {
+		return nil, fmt.Errorf("Digital Link '%s' enthält keinen unterstützten Primärschlüssel (01, 00, 414, 8003) mit Wert", uri)
+	}
+
+	dl := &DigitalLink{AI: segments[start], Key: segments[start+1]}
+	if err := dl.validateKey(); err != nil {
+		return nil, fmt.Errorf("Digital Link '%s': %v", uri, err)
+	}
+	for i := start + 2; i < len(segments); i += 2 {
+		ai, value := segments[i], segments[i+1]
+		field := dl.qualifier(ai)
+		if field == nil {
+			return nil, fmt.Errorf("Digital Link '%s': Qualifier %s ist zu %s nicht erlaubt", uri, ai, dl.AI)
+		}
+		if *field != "" {
+			return nil, fmt.Errorf("Digital Link '%s': Qualifier %s ist mehrfach angegeben", uri, ai)
+		}
+		if err := validateCharset82(value, 20); err != nil {
+			return nil, fmt.Errorf("Digital Link '%s': AI %s: %v", uri, ai, err)
+		}
+		*field = value
+	}
+	return dl, nil
+}
+
+func (dl *DigitalLink) qualifier(ai string) *string {
+	allowed := false
+	for _, q := range qualifiers[dl.AI] {
+		allowed = allowed || q == ai
+	}
+	if !allowed {
+		return nil
+	}
+	switch ai {
+	case AICPV:
+		return &dl.CPV
+	case AIBatch:
+		return &dl.Batch
+	case AISerial:
+		return &dl.Serial
+	case AIGLNExtension:
+		return &dl.Extension
+	}
+	return nil
+}
+
+// validateKey prüft den Primärschlüssel und bringt eine GTIN auf 14 Stellen.
+func (dl *DigitalLink) validateKey() error {
+	switch dl.AI {
+	case AIGTIN:
+		if err := ValidateGTIN(dl.Key); err != nil {
+			return err
+		}
+		dl.Key = strings.Repeat("0", 14-len(dl.Key)) + dl.Key
+		return nil
+	case AISSCC:
+		return ValidateSSCC(dl.Key)
+	case AIGLN:
+		return ValidateGLN(dl.Key)
+	case AIGRAI:
+		if len(dl.Key) < 14 || dl.Key[0] != '0' {
+			return fmt.Errorf("GRAI '%s' muss mit 0 und 13 Ziffern beginnen", dl.Key)
+		}
+		if err := validateKey("GRAI", dl.Key[:14]); err != nil {
+			return err
+		}
+		return validateCharset82(dl.Key[14:], 16)
+	}
+	return fmt.Errorf("AI %s wird nicht unterstützt", dl.AI)
+}
+
+// URI liefert den Digital Link mit der Domain Resolver in der Reihenfolge des Standards
+// (Primärschlüssel, 22, 10, 21 bzw. 254).
+func (dl *DigitalLink) URI() string {
+	var b strings.Builder
+	b.WriteString(Resolver + "/" + dl.AI + "/" + url.PathEscape(dl.Key))
+	for _, q := range []struct{ ai, value string }{{AICPV, dl.CPV}, {AIBatch, dl.Batch}, {AISerial, dl.Serial}, {AIGLNExtension, dl.Extension}} {
+		if q.value != "" {
+			b.WriteString("/" + q.ai + "/" + url.PathEscape(q.value))
+		}
+	}
+	return b.String()
+}
+
+// DigitalLink liefert den Digital Link einer EPC (sgtin: 01+21, lgtin: 01+10, sscc: 00,
+// sgln: 414 und ggf. 254, grai: 8003).
+func (e *EPC) DigitalLink() *DigitalLink {
+	serial, _ := unescape(e.Serial)
+	switch e.Scheme {
+	case SGTIN:
+		return &DigitalLink{AI: AIGTIN, Key: e.GTIN(), Serial: serial}
+	case LGTIN:
+		return &DigitalLink{AI: AIGTIN, Key: e.GTIN(), Batch: serial}
+	case SSCC:
+		return &DigitalLink{AI: AISSCC, Key: e.SSCC()}
+	case SGLN:
+		dl := &DigitalLink{AI: AIGLN, Key: e.GLN()}
+		if serial != "0" {
+			dl.Extension = serial
+		}
+		return dl
+	case GRAI:
+		return &DigitalLink{AI: AIGRAI, Key: e.GRAI()}
+	}
+	return nil
+}
+
+// EPC bildet die EPC-URN-Form. Die Länge des Firmenpräfix (6 bis 12) steht nicht im Digital
+// Link und muss angegeben werden. Eine GTIN mit Seriennummer ergibt eine sgtin, mit Charge
+// (ohne Seriennummer) eine lgtin.
+func (dl *DigitalLink) EPC(companyPrefixLength int) (*EPC, error) {
+	if companyPrefixLength < 6 || companyPrefixLength > 12 {
+		return nil, fmt.Errorf("Länge des Firmenpräfix muss zwischen 6 und 12 liegen, erhalten: %d", companyPrefixLength)
+	}
+	n := companyPrefixLength
+	var epc *EPC
+	switch dl.AI {
+	case AIGTIN:
+		ref := dl.Key[:1] + dl.Key[1+n:13]
+		switch {
+		case dl.Serial != "":
+			epc = &EPC{Scheme: SGTIN, CompanyPrefix: dl.Key[1 : 1+n], Reference: ref, Serial: dl.Serial}
+		case dl.Batch != "":
+			epc = &EPC{Scheme: LGTIN, CompanyPrefix: dl.Key[1 : 1+n], Reference: ref, Serial: dl.Batch}
+		default:
+			return nil, fmt.Errorf("GTIN %s ohne Seriennummer (21) oder Charge (10) hat keine EPC", dl.Key)
+		}
+	case AISSCC:
+		epc = &EPC{Scheme: SSCC, CompanyPrefix: dl.Key[1 : 1+n], Reference: dl.Key[:1] + dl.Key[1+n:17]}
+	case AIGLN:
+		ext := dl.Extension
+		if ext == "" {
+			ext = "0"
+		}
+		epc = &EPC{Scheme: SGLN, CompanyPrefix: dl.Key[:n], Reference: dl.Key[n:12], Serial: ext}
+	case AIGRAI:
+		if len(dl.Key) == 14 {
+			return nil, fmt.Errorf("GRAI %s ohne Seriennummer hat keine EPC", dl.Key)
+		}
+		epc = &EPC{Scheme: GRAI, CompanyPrefix: dl.Key[1 : 1+n], Reference: dl.Key[1+n : 13], Serial: dl.Key[14:]}
+	default:
+		return nil, fmt.Errorf("AI %s wird nicht unterstützt", dl.AI)
+	}
+	if epc.Serial != "" {
+		serial, err := escape(epc.Serial)
+		if err != nil {
+			return nil, err
+		}
+		epc.Serial = serial
+	}
+	return ParseEPC(epc.URN())
+}
+
+// escape kodiert einen Wert des Zeichensatzes 82 für die URN (Umkehrung von unescape).
+func escape(s string) (string, error) {
+	if err := validateCharset82(s, len(s)); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`"%&/<>?`, s[i]) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// validateCharset82 prüft einen dekodierten Wert (Zeichensatz 82, 1 bis max Zeichen).
+func validateCharset82(s string, max int) error {
+	if s == "" || len(s) > max {
+		return fmt.Errorf("'%s' muss 1 bis %d Zeichen lang sein", s, max)
+	}
+	for i := 0; i < len(s); i++ {
+		if !inCharset82(s[i]) {
+			return fmt.Errorf("Zeichen '%c' in '%s' gehört nicht zum GS1-Zeichensatz 82", s[i], s)
+		}
+	}
+	return nil
+}
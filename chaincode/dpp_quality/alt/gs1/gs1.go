@@ -0,0 +1,262 @@
+// Package gs1 zerlegt GS1-Kennungen in EPC-URN-Form (Tag Data Standard) und prüft die
+// Prüfziffern von GTIN, GLN und SSCC.
+//
+// Unterstützt werden
+//
+//	urn:epc:id:sgtin:<Firmenpräfix>.<Indikator+Artikelreferenz>.<Seriennummer>
+//	urn:epc:class:lgtin:<Firmenpräfix>.<Indikator+Artikelreferenz>.<Charge>
+//	urn:epc:id:sscc:<Firmenpräfix>.<Erweiterungsziffer+Seriennummernreferenz>
+//	urn:epc:id:sgln:<Firmenpräfix>.<Lokationsreferenz>.<Erweiterung>
+//	urn:epc:id:grai:<Firmenpräfix>.<Anlagentyp>.<Seriennummer>
+//
+// Die URNs enthalten keine Prüfziffer; GTIN, SSCC, GLN und GRAI werden daraus mit
+// berechneter Prüfziffer gebildet.
+package gs1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schemata der EPC-URNs.
+const (
+	SGTIN = "sgtin"
+	LGTIN = "lgtin"
+	SSCC  = "sscc"
+	SGLN  = "sgln"
+	GRAI  = "grai"
+)
+
+// scheme beschreibt Aufbau und Längen eines Schemas.
+type scheme struct {
+	prefix     string // "urn:epc:id:" bzw. "urn:epc:class:"
+	parts      int    // Anzahl der durch "." getrennten Teile
+	digits     int    // Stellen von Firmenpräfix + Referenz
+	maxSerial  int    // maximale Länge des dritten Teils (Zeichensatz 82)
+	serialName string
+}
+
+var schemes = map[string]scheme{
+	SGTIN: {prefix: "urn:epc:id:", parts: 3, digits: 13, maxSerial: 20, serialName: "Seriennummer"},
+	LGTIN: {prefix: "urn:epc:class:", parts: 3, digits: 13, maxSerial: 20, serialName: "Charge"},
+	SSCC:  {prefix: "urn:epc:id:", parts: 2, digits: 17},
+	SGLN:  {prefix: "urn:epc:id:", parts: 3, digits: 12, maxSerial: 20, serialName: "Erweiterung"},
+	GRAI:  {prefix: "urn:epc:id:", parts: 3, digits: 12, maxSerial: 16, serialName: "Seriennummer"},
+}
+
+// EPC ist eine zerlegte EPC-URN.
+//
+// Reference ist je nach Schema Indikator und Artikelreferenz (sgtin, lgtin),
+// Erweiterungsziffer und Seriennummernreferenz (sscc), Lokationsreferenz (sgln) oder
+// Anlagentyp (grai). Serial ist Seriennummer (sgtin, grai), Charge (lgtin) oder
+// Erweiterung (sgln, "0" = keine); bei sscc leer. Serial ist URN-kodiert wie in der URN.
+type EPC struct {
+	Scheme        string
+	CompanyPrefix string
+	Reference     string
+	Serial        string
+}
+
+// ParseEPC zerlegt eine EPC-URN und prüft Längen und Zeichensätze.
+func ParseEPC(urn string) (*EPC, error) {
+	var name, body string
+	switch {
+	case strings.HasPrefix(urn, "urn:epc:id:"):
+		name, body = splitScheme(strings.TrimPrefix(urn, "urn:epc:id:"))
+	case strings.HasPrefix(urn, "urn:epc:class:"):
+		name, body = splitScheme(strings.TrimPrefix(urn, "urn:epc:class:"))
+	default:
+		return nil, fmt.Errorf("'%s' ist keine EPC-URN (urn:epc:id:... oder urn:epc:class:lgtin:...)", urn)
+	}
+	s, ok := schemes[name]
+	if !ok || !strings.HasPrefix(urn, s.prefix+name+":") {
+		return nil, fmt.Errorf("EPC-Schema '%s' in '%s' wird nicht unterstützt (erlaubt: sgtin, lgtin, sscc, sgln, grai)", name, urn)
+	}
+	parts := strings.SplitN(body, ".", s.parts)
+	if len(parts) != s.parts {
+		return nil, fmt.Errorf("%s-URN '%s' muss %d durch '.' getrennte Teile haben", name, urn, s.parts)
+	}
+	epc := &EPC{Scheme: name, CompanyPrefix: parts[0], Reference: parts[1]}
+	if s.parts == 3 {
+		epc.Serial = parts[2]
+	}
+
+	if !isDigits(epc.CompanyPrefix) || len(epc.CompanyPrefix) < 6 || len(epc.CompanyPrefix) > 12 {
+		return nil, fmt.Errorf("Firmenpräfix '%s' in '%s' muss aus 6 bis 12 Ziffern bestehen", epc.CompanyPrefix, urn)
+	}
+	if !isDigits(epc.Reference) && epc.Reference != "" {
+		return nil, fmt.Errorf("Referenz '%s' in '%s' darf nur Ziffern enthalten", epc.Reference, urn)
+	}
+	if n := len(epc.CompanyPrefix) + len(epc.Reference); n != s.digits {
+		return nil, fmt.Errorf("Firmenpräfix und Referenz in '%s' haben %d Stellen, erwartet %d", urn, n, s.digits)
+	}
+	if s.parts == 3 {
+		n, err := serialLength(epc.Serial)
+		if err != nil {
+			return nil, fmt.Errorf("%s in '%s': %v", s.serialName, urn, err)
+		}
+		if n == 0 || n > s.maxSerial {
+			return nil, fmt.Errorf("%s in '%s' muss 1 bis %d Zeichen lang sein", s.serialName, urn, s.maxSerial)
+		}
+	}
+	return epc, nil
+}
+
+func splitScheme(s string) (string, string) {
+	colon := strings.Index(s, ":")
+	if colon < 0 {
+		return s, ""
+	}
+	return s[:colon], s[colon+1:]
+}
+
+// URN liefert die EPC-URN.
+func (e *EPC) URN() string {
+	s := schemes[e.Scheme]
+	urn := s.prefix + e.Scheme + ":" + e.CompanyPrefix + "." + e.Reference
+	if s.parts == 3 {
+		urn += "." + e.Serial
+	}
+	return urn
+}
+
+// GTIN liefert die GTIN-14 einer sgtin/lgtin, sonst "".
+func (e *EPC) GTIN() string {
+	if e.Scheme != SGTIN && e.Scheme != LGTIN {
+		return ""
+	}
+	return withCheckDigit(e.Reference[:1] + e.CompanyPrefix + e.Reference[1:])
+}
+
+// SSCC liefert die 18-stellige SSCC einer sscc, sonst "".
+func (e *EPC) SSCC() string {
+	if e.Scheme != SSCC {
+		return ""
+	}
+	return withCheckDigit(e.Reference[:1] + e.CompanyPrefix + e.Reference[1:])
+}
+
+// GLN liefert die GLN einer sgln, sonst "".
+func (e *EPC) GLN() string {
+	if e.Scheme != SGLN {
+		return ""
+	}
+	return withCheckDigit(e.CompanyPrefix + e.Reference)
+}
+
+// GRAI liefert die GRAI (mit führender 0 und Seriennummer) einer grai, sonst "".
+func (e *EPC) GRAI() string {
+	if e.Scheme != GRAI {
+		return ""
+	}
+	serial, _ := unescape(e.Serial)
+	return withCheckDigit("0"+e.CompanyPrefix+e.Reference) + serial
+}
+
+// CheckDigit berechnet die Prüfziffer (Modulo 10, Gewichte 3 und 1 von rechts) zu einer
+// Ziffernfolge ohne Prüfziffer.
+func CheckDigit(digits string) (byte, error) {
+	if !isDigits(digits) {
+		return 0, fmt.Errorf("'%s' enthält nicht nur Ziffern", digits)
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+func withCheckDigit(digits string) string {
+	c, _ := CheckDigit(digits)
+	return digits + string(c)
+}
+
+// ValidateGTIN prüft eine GTIN-8, -12, -13 oder -14 einschließlich Prüfziffer.
+func ValidateGTIN(gtin string) error {
+	switch len(gtin) {
+	case 8, 12, 13, 14:
+		return validateKey("GTIN", gtin)
+	}
+	return fmt.Errorf("GTIN '%s' muss 8, 12, 13 oder 14 Ziffern haben", gtin)
+}
+
+// ValidateGLN prüft eine 13-stellige GLN einschließlich Prüfziffer.
+func ValidateGLN(gln string) error {
+	if len(gln) != 13 {
+		return fmt.Errorf("GLN '%s' muss 13 Ziffern haben", gln)
+	}
+	return validateKey("GLN", gln)
+}
+
+// ValidateSSCC prüft eine 18-stellige SSCC einschließlich Prüfziffer.
+func ValidateSSCC(sscc string) error {
+	if len(sscc) != 18 {
+		return fmt.Errorf("SSCC '%s' muss 18 Ziffern haben", sscc)
+	}
+	return validateKey("SSCC", sscc)
+}
+
+func validateKey(kind, key string) error {
+	if !isDigits(key) {
+		return fmt.Errorf("%s '%s' darf nur Ziffern enthalten", kind, key)
+	}
+	want, _ := CheckDigit(key[:len(key)-1])
+	if key[len(key)-1] != want {
+		return fmt.Errorf("%s '%s' hat eine falsche Prüfziffer (erwartet %c)", kind, key, want)
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// urnEscapes: Zeichen des Zeichensatzes 82, die in URNs mit %-Kodierung stehen.
+var urnEscapes = map[string]byte{"%22": '"', "%25": '%', "%26": '&', "%2F": '/', "%3C": '<', "%3E": '>', "%3F": '?'}
+
+// unescape dekodiert eine Seriennummer aus der URN und prüft den Zeichensatz 82.
+func unescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if i+3 > len(s) {
+				return "", fmt.Errorf("unvollständige %%-Kodierung in '%s'", s)
+			}
+			decoded, ok := urnEscapes[strings.ToUpper(s[i:i+3])]
+			if !ok {
+				return "", fmt.Errorf("%%-Kodierung %s in '%s' ist nicht erlaubt", s[i:i+3], s)
+			}
+			b.WriteByte(decoded)
+			i += 2
+		case inCharset82(c) && strings.IndexByte(`"%&/<>?`, c) < 0:
+			b.WriteByte(c)
+		default:
+			return "", fmt.Errorf("Zeichen '%c' in '%s' ist in EPC-URNs nicht erlaubt", c, s)
+		}
+	}
+	return b.String(), nil
+}
+
+// inCharset82: Zeichen des GS1-Zeichensatzes 82 (für Seriennummern, Chargen, Erweiterungen).
+func inCharset82(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte(`!"%&'()*+,-./:;<=>?_`, c) >= 0
+}
+
+func serialLength(s string) (int, error) {
+	decoded, err := unescape(s)
+	return len(decoded), err
+}
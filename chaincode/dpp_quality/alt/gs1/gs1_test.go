@@ -0,0 +1,169 @@
+package gs1
+
+import "testing"
+
+func TestCheckDigit(t *testing.T) {
+	for digits, want := range map[string]byte{
+		"0401234511111":     '8',
+		"401234500001":      '6',
+		"10614141234567890": '8',
+		"9638507":           '4',
+		"000000000000":      '0',
+	} {
+		got, err := CheckDigit(digits)
+		if err != nil || got != want {
+			t.Errorf("CheckDigit(%s) = %c, %v, erwartet %c", digits, got, err, want)
+		}
+	}
+	if _, err := CheckDigit("40123A"); err == nil {
+		t.Errorf("CheckDigit mit Buchstabe: kein Fehler")
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	tests := []struct {
+		validate func(string) error
+		key      string
+		valid    bool
+	}{
+		{ValidateGTIN, "04012345111118", true},
+		{ValidateGTIN, "4006381333931", true},
+		{ValidateGTIN, "036000291452", true},
+		{ValidateGTIN, "96385074", true},
+		{ValidateGTIN, "04012345111119", false}, // Prüfziffer
+		{ValidateGTIN, "4012345111118", true},   // GTIN-13 zur GTIN-14 04012345111118
+		{ValidateGTIN, "040123451111180", false},
+		{ValidateGTIN, "0401234511111X", false},
+		{ValidateGLN, "4012345000016", true},
+		{ValidateGLN, "0614141123452", true},
+		{ValidateGLN, "4012345000017", false},
+		{ValidateGLN, "401234500001", false},
+		{ValidateSSCC, "106141412345678908", true},
+		{ValidateSSCC, "340123450000000017", true},
+		{ValidateSSCC, "340123450000000018", false},
+		{ValidateSSCC, "34012345000000001", false},
+	}
+	for _, tt := range tests {
+		if err := tt.validate(tt.key); (err == nil) != tt.valid {
+			t.Errorf("%s: Fehler %v, gültig erwartet: %v", tt.key, err, tt.valid)
+		}
+	}
+}
+
+func TestParseEPCInvalid(t *testing.T) {
+	for _, urn := range []string{
+		"4012345000016",
+		"urn:epc:id:sgtin:4012345.011111",      // Seriennummer fehlt
+		"urn:epc:id:sgtin:4012345.01111.1001",  // 12 statt 13 Stellen
+		"urn:epc:id:sgtin:40123.0111111.1001",  // Firmenpräfix zu kurz
+		"urn:epc:id:sgtin:4012345.0111A1.1001", // Referenz mit Buchstabe
+		"urn:epc:id:sgtin:4012345.011111.123456789012345678901",
+		"urn:epc:id:sgtin:4012345.011111.A/B",             // '/' nur als %2F
+		"urn:epc:id:sgtin:4012345.011111.A%2",             // unvollständige Kodierung
+		"urn:epc:id:sgtin:4012345.011111.A%41",            // %-Kodierung außerhalb der erlaubten Zeichen
+		"urn:epc:id:sgtin:4012345.011111.A B",             // Leerzeichen
+		"urn:epc:id:lgtin:4012345.011111.L1",              // lgtin ist eine Klasse
+		"urn:epc:class:sgtin:4012345.011111.1001",         // sgtin ist keine Klasse
+		"urn:epc:id:giai:4012345.1001",                    // nicht unterstützt
+		"urn:epc:id:sgln:4012345000016.0.0",               // GLN statt Firmenpräfix und Lokationsreferenz
+		"urn:epc:id:grai:4012345.00001.12345678901234567", // Seriennummer zu lang
+	} {
+		if epc, err := ParseEPC(urn); err == nil {
+			t.Errorf("ParseEPC(%s) = %+v, Fehler erwartet", urn, epc)
+		}
+	}
+}
+
+// TestRoundTrip: URN -> EPC -> URN, EPC -> Digital Link und Digital Link -> EPC für jedes Schema.
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		urn, key, dl string
+	}{
+		{"urn:epc:id:sgtin:0614141.812345.6789", "80614141123458", "https://id.gs1.org/01/80614141123458/21/6789"},
+		{"urn:epc:id:sgtin:4012345.011111.A%2FB%26C", "04012345111118", "https://id.gs1.org/01/04012345111118/21/A%2FB&C"},
+		{"urn:epc:class:lgtin:4012345.011111.L1", "04012345111118", "https://id.gs1.org/01/04012345111118/10/L1"},
+		{"urn:epc:class:lgtin:4012345.011111.L%3F1", "04012345111118", "https://id.gs1.org/01/04012345111118/10/L%3F1"},
+		{"urn:epc:id:sscc:0614141.1234567890", "106141412345678908", "https://id.gs1.org/00/106141412345678908"},
+		{"urn:epc:id:sgln:0614141.12345.0", "0614141123452", "https://id.gs1.org/414/0614141123452"},
+		{"urn:epc:id:sgln:0614141.12345.400", "0614141123452", "https://id.gs1.org/414/0614141123452/254/400"},
+		{"urn:epc:id:grai:0614141.12345.400", "00614141123452400", "https://id.gs1.org/8003/00614141123452400"},
+	}
+	for _, tt := range tests {
+		epc, err := ParseEPC(tt.urn)
+		if err != nil {
+			t.Errorf("ParseEPC(%s): %v", tt.urn, err)
+			continue
+		}
+		if got := epc.URN(); got != tt.urn {
+			t.Errorf("URN() = %s, erwartet %s", got, tt.urn)
+		}
+		if key := epc.GTIN() + epc.SSCC() + epc.GLN() + epc.GRAI(); key != tt.key {
+			t.Errorf("%s: Schlüssel %s, erwartet %s", tt.urn, key, tt.key)
+		}
+		if got := epc.DigitalLink().URI(); got != tt.dl {
+			t.Errorf("%s: Digital Link %s, erwartet %s", tt.urn, got, tt.dl)
+		}
+		dl, err := ParseDigitalLink(tt.dl)
+		if err != nil {
+			t.Errorf("ParseDigitalLink(%s): %v", tt.dl, err)
+			continue
+		}
+		back, err := dl.EPC(len(epc.CompanyPrefix))
+		if err != nil {
+			t.Errorf("%s: EPC: %v", tt.dl, err)
+			continue
+		}
+		if back.URN() != tt.urn {
+			t.Errorf("%s: EPC %s, erwartet %s", tt.dl, back.URN(), tt.urn)
+		}
+	}
+}
+
+func TestParseDigitalLink(t *testing.T) {
+	dl, err := ParseDigitalLink("https://example.com/produkte/01/4012345111118/10/L1/21/1001?17=270101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DigitalLink{AI: AIGTIN, Key: "04012345111118", Batch: "L1", Serial: "1001"}); *dl != want {
+		t.Errorf("%+v, erwartet %+v", *dl, want)
+	}
+	for _, uri := range []string{
+		"urn:epc:id:sgtin:4012345.011111.1001",
+		"https://id.gs1.org/01/04012345111119/21/1001", // Prüfziffer
+		"https://id.gs1.org/01/04012345111118/21",      // Qualifier ohne Wert
+		"https://id.gs1.org/01/04012345111118/254/1",   // Qualifier gehört zur GLN
+		"https://id.gs1.org/01/04012345111118/21/1/21/2",
+		"https://id.gs1.org/01/04012345111118/21/A%20B", // Leerzeichen nicht im Zeichensatz 82
+		"https://id.gs1.org/8003/10614141123459400",     // GRAI ohne führende 0
+	} {
+		if _, err := ParseDigitalLink(uri); err == nil {
+			t.Errorf("ParseDigitalLink(%s): Fehler erwartet", uri)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for raw, want := range map[string]string{
+		"ABC-1.2":  "ABC-1.2",
+		"A/B":      "A%2FB",
+		`"%&<>?`:   "%22%25%26%3C%3E%3F",
+		"x!'()*+,": "x!'()*+,",
+	} {
+		got, err := escape(raw)
+		if err != nil || got != want {
+			t.Errorf("escape(%q) = %q, %v, erwartet %q", raw, got, err, want)
+			continue
+		}
+		if back, err := unescape(got); err != nil || back != raw {
+			t.Errorf("unescape(%q) = %q, %v, erwartet %q", got, back, err, raw)
+		}
+	}
+	if back, err := unescape("a%2fb"); err != nil || back != "a/b" {
+		t.Errorf("unescape mit Kleinbuchstaben: %q, %v", back, err)
+	}
+	for _, raw := range []string{"", "A B", "ä", "A#B"} {
+		if got, err := escape(raw); err == nil {
+			t.Errorf("escape(%q) = %q, Fehler erwartet", raw, got)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testStub ergänzt den MockStub um die Key-Historie und um paginierte Range-Abfragen über
+// Composite Keys, die der MockStub nicht implementiert. Je TxID werden gelesene Keys,
+// gelesene Composite-Key-Präfixe und geschriebene Keys mitgeschrieben (Read-/Write-Set).
+type testStub struct {
+	*shimtest.MockStub
+	history map[string][]*queryresult.KeyModification
+	rwsets  map[string]*rwSet
+}
+
+// rwSet: Was eine Transaktion gelesen und geschrieben hat, wie für die MVCC-Prüfung des Peers.
+type rwSet struct {
+	reads  map[string]bool
+	ranges []string
+	writes map[string]bool
+}
+
+func (s *testStub) rwSet() *rwSet {
+	set, ok := s.rwsets[s.TxID]
+	if !ok {
+		set = &rwSet{reads: map[string]bool{}, writes: map[string]bool{}}
+		s.rwsets[s.TxID] = set
+	}
+	return set
+}
+
+// conflictsWith: Eine Schreiboperation von other macht einen Lesevorgang von set ungültig.
+func (set *rwSet) conflictsWith(other *rwSet) []string {
+	var keys []string
+	for key := range other.writes {
+		if set.reads[key] {
+			keys = append(keys, key)
+			continue
+		}
+		for _, prefix := range set.ranges {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *testStub) GetState(key string) ([]byte, error) {
+	s.rwSet().reads[key] = true
+	return s.MockStub.GetState(key)
+}
+
+func (s *testStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	s.rwSet().ranges = append(s.rwSet().ranges, prefix)
+	return s.MockStub.GetStateByPartialCompositeKey(objectType, keys)
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if err := s.MockStub.PutState(key, value); err != nil {
+		return err
+	}
+	s.rwSet().writes[key] = true
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: s.TxTimestamp})
+	return nil
+}
+
+// GetHistoryForKey liefert wie Fabric ab 2.0 die neueste Version zuerst.
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	mods := s.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(mods))
+	for i, m := range mods {
+		newestFirst[len(mods)-1-i] = m
+	}
+	return &historyIterator{mods: newestFirst}, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination: Das Bookmark ist wie beim Peer der Startschlüssel.
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iter, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Close()
+	page := &stateIterator{}
+	next := ""
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if len(page.kvs) == int(pageSize) {
+			next = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	return page, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.kvs)), Bookmark: next}, nil
+}
+
+type historyIterator struct {
+	mods []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool { return len(it.mods) > 0 }
+func (it *historyIterator) Close() error  { return nil }
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	m := it.mods[0]
+	it.mods = it.mods[1:]
+	return m, nil
+}
+
+type stateIterator struct{ kvs []*queryresult.KV }
+
+func (it *stateIterator) HasNext() bool { return len(it.kvs) > 0 }
+func (it *stateIterator) Close() error  { return nil }
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+// testIdentity: Aufrufer mit MSP und Attributen (z.B. "role").
+type testIdentity struct {
+	msp   string
+	attrs map[string]string
+}
+
+func (id *testIdentity) GetID() (string, error)    { return "x509::CN=user@" + id.msp, nil }
+func (id *testIdentity) GetMSPID() (string, error) { return id.msp, nil }
+func (id *testIdentity) GetAttributeValue(name string) (string, bool, error) {
+	v, ok := id.attrs[name]
+	return v, ok, nil
+}
+func (id *testIdentity) AssertAttributeValue(name, value string) error {
+	if id.attrs[name] != value {
+		return fmt.Errorf("Attribut %s ist nicht %s", name, value)
+	}
+	return nil
+}
+func (id *testIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+var _ cid.ClientIdentity = (*testIdentity)(nil)
+
+type testContext struct {
+	stub *testStub
+	id   *testIdentity
+}
+
+func (c *testContext) GetStub() shim.ChaincodeStubInterface  { return c.stub }
+func (c *testContext) GetClientIdentity() cid.ClientIdentity { return c.id }
+
+// testEnv: Ledger für einen Test. Jeder Aufruf von ctx ist eine eigene Transaktion mit
+// neuer TxID und einer Minute späterem Zeitstempel.
+type testEnv struct {
+	t    *testing.T
+	stub *testStub
+	n    int
+	base time.Time
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	stub := &testStub{MockStub: shimtest.NewMockStub("dpp", nil), history: map[string][]*queryresult.KeyModification{}, rwsets: map[string]*rwSet{}}
+	return &testEnv{t: t, stub: stub, base: time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)}
+}
+
+// ctx startet eine Transaktion für msp; attrs sind Paare aus Attributname und Wert.
+func (e *testEnv) ctx(msp string, attrs ...string) *testContext {
+	e.n++
+	e.stub.TxID = fmt.Sprintf("tx%03d", e.n)
+	e.stub.TxTimestamp = timestamppb.New(e.base.Add(time.Duration(e.n) * time.Minute))
+	m := map[string]string{}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		m[attrs[i]] = attrs[i+1]
+	}
+	return &testContext{stub: e.stub, id: &testIdentity{msp: msp, attrs: m}}
+}
+
+func (e *testEnv) must(err error) {
+	e.t.Helper()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+}
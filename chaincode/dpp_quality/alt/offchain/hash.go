@@ -0,0 +1,103 @@
+// Package offchain berechnet Hashes von Off-Chain-Dateien (Sensor-Logs, Prüfprotokolle) so,
+// wie die Oracles sie auf dem Ledger verankern.
+//
+// Die Node.js-Oracles lesen die Datei als UTF-8-Text und hashen den Text:
+//
+//	crypto.createHash('sha256').update(fs.readFileSync(pfad, 'utf8')).digest('hex')
+//
+// Für gültige UTF-8-Dateien entspricht das dem SHA-256 der Rohdaten. Ungültige Byte-Folgen
+// ersetzt Node.js beim Lesen durch U+FFFD; HashBytes macht dasselbe, damit auch solche Dateien
+// denselben Hash ergeben.
+package offchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Algorithm ist der Name des Hash-Verfahrens, wie er auf dem Ledger gespeichert wird.
+const Algorithm = "SHA-256"
+
+// HashBytes liefert den SHA-256 (hex, Kleinbuchstaben) des als UTF-8 gelesenen Inhalts.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(utf8Text(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile liest die Datei und liefert ihren Hash wie HashBytes.
+func HashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Datei %s nicht lesbar: %v", path, err)
+	}
+	return HashBytes(data), nil
+}
+
+// NormalizeHash prüft einen hex-kodierten SHA-256 und liefert ihn in Kleinbuchstaben.
+func NormalizeHash(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if len(hash) != 2*sha256.Size {
+		return "", fmt.Errorf("%s-Hash muss %d Hex-Zeichen haben, erhalten: %d", Algorithm, 2*sha256.Size, len(hash))
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("%s-Hash ist nicht hex-kodiert: %v", Algorithm, err)
+	}
+	return hash, nil
+}
+
+// utf8Text ersetzt ungültige UTF-8-Folgen wie der UTF-8-Decoder von Node.js durch U+FFFD.
+func utf8Text(data []byte) []byte {
+	if utf8.Valid(data) {
+		return data
+	}
+	out := make([]byte, 0, len(data)+16)
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			out = utf8.AppendRune(out, utf8.RuneError)
+			data = data[maximalInvalidPrefix(data):]
+			continue
+		}
+		out = append(out, data[:size]...)
+		data = data[size:]
+	}
+	return out
+}
+
+// maximalInvalidPrefix: Länge des größten Anfangsstücks einer gültigen Folge (mind. 1 Byte).
+// Node.js ersetzt ein solches abgebrochenes Anfangsstück durch genau ein U+FFFD.
+func maximalInvalidPrefix(data []byte) int {
+	b := data[0]
+	var need int
+	lo, hi := byte(0x80), byte(0xBF)
+	switch {
+	case b >= 0xC2 && b <= 0xDF:
+		need = 1
+	case b == 0xE0:
+		need, lo = 2, 0xA0
+	case b >= 0xE1 && b <= 0xEC, b == 0xEE, b == 0xEF:
+		need = 2
+	case b == 0xED:
+		need, hi = 2, 0x9F
+	case b == 0xF0:
+		need, lo = 3, 0x90
+	case b >= 0xF1 && b <= 0xF3:
+		need = 3
+	case b == 0xF4:
+		need, hi = 3, 0x8F
+	default:
+		return 1
+	}
+	n := 1
+	for ; n <= need && n < len(data); n++ {
+		if data[n] < lo || data[n] > hi {
+			break
+		}
+		lo, hi = 0x80, 0xBF
+	}
+	return n
+}
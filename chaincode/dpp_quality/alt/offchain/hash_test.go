@@ -0,0 +1,63 @@
+package offchain
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Die erwarteten Hashes stammen aus Node.js 20 mit dem Aufruf der Oracles:
+//
+//	crypto.createHash('sha256').update(fs.readFileSync(pfad, 'utf8')).digest('hex')
+func TestHashBytesMatchesNode(t *testing.T) {
+	tests := []struct {
+		name string
+		data string // hex
+		want string
+	}{
+		{"ASCII", "74696d657374616d702c74656d70657261747572650a323032352d30362d30315430383a30303a30305a2c342e320a", "6d48c73d38c4d55f37752240999b41bc18bb7800b6c5f375b7c8fdf4f4724cfd"},
+		{"leer", "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"BOM bleibt erhalten", "efbbbf4d46493b31352c320a", "1b491c42840ca971a453d96ef0b3b4f8dfa6a49256ef27c0678331bd6b3771cd"},
+		{"Umlaute", "5072c3bc66756e672044696368746520302c393520672f636dc2b30a", "e2e4cb73398827158a5ccb9db07ac0fdbe9568c24fd82f0631c1e4f7977cab7c"},
+		{"Latin-1 statt UTF-8", "4dfc6c6c0a", "4667be32775587e07972a19e938d2e5fed58907db800ef8f01723df0088b77e0"},
+		{"am Ende abgeschnitten", "61e282", "51d277510ba4bf97b25f12d38513c1b620a2a33fc83b3beeeb0dd971bf429e6d"},
+		{"4-Byte-Folge unterbrochen", "f09f9861", "94b964456d33b6a0fb82bd59fb16d700eb6d2fea5366d4974e28980cc9c7144e"},
+		{"Surrogat", "eda08062", "5088320719458d3b8e23f097f8c6ed9251c84314764fdaa906e4760f4bd07a38"},
+		{"überlange Kodierung", "c0afe080af", "cf7f18b3357e0c3ce1b92f1d169ceb68364e747dc146576cb23d00a874a4638d"},
+		{"über U+10FFFF", "f4908080", "c22917d19a6656769f72fb7090d6590cb857aa735ef9e646a4ef15d78e7d2317"},
+		{"Folgebytes ohne Startbyte", "80bf63", "5eea8f465bc4dd79b7674c384e73a859c66828528cb79b97b62730a9ef4528d4"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := HashBytes(data); got != tt.want {
+				t.Errorf("HashBytes(%s) = %s, erwartet %s", tt.data, got, tt.want)
+			}
+			path := filepath.Join(dir, "log.csv")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := HashFile(path); err != nil || got != tt.want {
+				t.Errorf("HashFile = %s, %v, erwartet %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHash(t *testing.T) {
+	valid := "6D48C73D38C4D55F37752240999B41BC18BB7800B6C5F375B7C8FDF4F4724CFD"
+	if got, err := NormalizeHash(" " + valid + "\n"); err != nil || got != strings.ToLower(valid) {
+		t.Errorf("NormalizeHash = %s, %v", got, err)
+	}
+	for _, hash := range []string{"", valid[:62], valid + "00", "zz" + valid[2:]} {
+		if _, err := NormalizeHash(hash); err == nil {
+			t.Errorf("NormalizeHash(%q): kein Fehler", hash)
+		}
+	}
+}
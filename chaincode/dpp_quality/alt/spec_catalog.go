@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- Spezifikationskatalog --------------------------- //
+
+// SpecSetStatus: Ein Spezifikationssatz ist aktiv (für neue DPPs verwendbar) oder zurückgezogen.
+type SpecSetStatus string
+
+const (
+	SpecSetActive  SpecSetStatus = "Active"
+	SpecSetRetired SpecSetStatus = "Retired"
+)
+
+// SpecificationSet ist eine versionierte Menge von Qualitätsspezifikationen je ProductTypeID.
+// Versionen sind unveränderlich; nur der Status kann auf Retired wechseln. Der Produkttyp gehört
+// der Organisation, die Version 1 veröffentlicht hat (OwnerMSP); nur sie darf weitere Versionen
+// veröffentlichen und Versionen zurückziehen.
+type SpecificationSet struct {
+	ProductTypeID    string                 `json:"productTypeId"`
+	Version          int                    `json:"version"`
+	Status           SpecSetStatus          `json:"status"`
+	Description      string                 `json:"description,omitempty"      metadata:",optional"`
+	Specifications   []QualitySpecification `json:"specifications"`
+	OwnerMSP         string                 `json:"ownerMsp,omitempty"         metadata:",optional"` // Eigentümer des Produkttyps (Herausgeber von Version 1)
+	PublishedByMSP   string                 `json:"publishedByMsp"`
+	PublishedAt      string                 `json:"publishedAt"`
+	PublishedTxID    string                 `json:"publishedTxId"`
+	RetiredByMSP     string                 `json:"retiredByMsp,omitempty"     metadata:",optional"`
+	RetiredAt        string                 `json:"retiredAt,omitempty"        metadata:",optional"`
+	RetirementReason string                 `json:"retirementReason,omitempty" metadata:",optional"`
+
+	// Grenzwerte für Transportbedingungen (testName = logType, z.B. "temperature"), siehe AddTransportUpdate.
+	TransportSpecifications []QualitySpecification `json:"transportSpecifications,omitempty" metadata:",optional"`
+}
+
+// Schlüssel: SpecSet~<productTypeID>~<version, 8-stellig>. Die Auffüllung sorgt dafür, dass
+// GetStateByPartialCompositeKey die Versionen aufsteigend liefert.
+const specSetObjectType = "SpecSet"
+
+func specSetKey(ctx contractapi.TransactionContextInterface, productTypeID string, version int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(specSetObjectType, []string{productTypeID, fmt.Sprintf("%08d", version)})
+}
+
+func (c *DPPQualityContract) getSpecificationSet(ctx contractapi.TransactionContextInterface, productTypeID string, version int) (*SpecificationSet, error) {
+	key, err := specSetKey(ctx, productTypeID, version)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen von Spezifikationssatz %s v%d: %v", productTypeID, version, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("Spezifikationssatz %s v%d nicht gefunden", productTypeID, version)
+	}
+	var set SpecificationSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Fehler beim Unmarshalling von Spezifikationssatz %s v%d: %v", productTypeID, version, err)
+	}
+	return &set, nil
+}
+
+func (c *DPPQualityContract) putSpecificationSet(ctx contractapi.TransactionContextInterface, set *SpecificationSet) error {
+	key, err := specSetKey(ctx, set.ProductTypeID, set.Version)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("Fehler beim Marshalling von Spezifikationssatz %s v%d: %v", set.ProductTypeID, set.Version, err)
+	}
+	return ctx.GetStub().PutState(key, data)
+}
+
+// owner: Eigentümer des Produkttyps. Vor Einführung von OwnerMSP angelegte Sätze gehören dem
+// Herausgeber der Version.
+func (set *SpecificationSet) owner() string {
+	if set.OwnerMSP != "" {
+		return set.OwnerMSP
+	}
+	return set.PublishedByMSP
+}
+
+// checkOwner: Nur der Eigentümer des Produkttyps darf ihn ändern; set ist Version 1.
+func (set *SpecificationSet) checkOwner(clientMSPID string) error {
+	if owner := set.owner(); clientMSPID != owner {
+		return fmt.Errorf("Zugriff verweigert: Produkttyp %s gehört %s, Aufrufer ist %s", set.ProductTypeID, owner, clientMSPID)
+	}
+	return nil
+}
+
+// ListSpecificationSets: Alle Versionen eines Produkttyps, aufsteigend nach Version.
+func (c *DPPQualityContract) ListSpecificationSets(ctx contractapi.TransactionContextInterface, productTypeID string) ([]*SpecificationSet, error) {
+	if productTypeID == "" {
+		return nil, fmt.Errorf("productTypeID darf nicht leer sein")
+	}
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(specSetObjectType, []string{productTypeID})
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Lesen des Spezifikationskatalogs für %s: %v", productTypeID, err)
+	}
+	defer iter.Close()
+
+	sets := []*SpecificationSet{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var set SpecificationSet
+		if err := json.Unmarshal(kv.Value, &set); err != nil {
+			return nil, fmt.Errorf("Fehler beim Unmarshalling von Katalogeintrag %s: %v", kv.Key, err)
+		}
+		sets = append(sets, &set)
+	}
+	return sets, nil
+}
+
+// QuerySpecificationSet: Liest eine bestimmte Version aus dem Katalog.
+func (c *DPPQualityContract) QuerySpecificationSet(ctx contractapi.TransactionContextInterface, productTypeID string, version int) (*SpecificationSet, error) {
+	return c.getSpecificationSet(ctx, productTypeID, version)
+}
+
+// PublishSpecificationSet: Legt die nächste Version des Spezifikationssatzes für einen Produkttyp an.
+// Bestehende Versionen bleiben aktiv, bis sie mit RetireSpecificationSet zurückgezogen werden.
+// Version 1 legt den Eigentümer des Produkttyps fest; weitere Versionen nur durch ihn.
+// transportSpecificationsJSON ist optional (leer = keine Transportgrenzwerte).
+func (c *DPPQualityContract) PublishSpecificationSet(ctx contractapi.TransactionContextInterface, productTypeID string, specificationsJSON string, transportSpecificationsJSON string, description string) (*SpecificationSet, error) {
+	fmt.Printf("[PublishSpecificationSet-DEBUG] Entry: productTypeID=%s\n", productTypeID)
+	if productTypeID == "" {
+		return nil, fmt.Errorf("productTypeID darf nicht leer sein")
+	}
+
+	var specs []QualitySpecification
+	if err := json.Unmarshal([]byte(specificationsJSON), &specs); err != nil {
+		return nil, fmt.Errorf("Spezifikationen JSON fehlerhaft: %v", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("Spezifikationssatz für %s enthält keine Spezifikationen", productTypeID)
+	}
+	if err := validateSpecifications(specs); err != nil {
+		return nil, err
+	}
+	transportSpecs, err := parseTransportSpecifications(transportSpecificationsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	existing, err := c.ListSpecificationSets(ctx, productTypeID)
+	if err != nil {
+		return nil, err
+	}
+	version, owner := 1, clientMSPID
+	if len(existing) > 0 {
+		if err := existing[0].checkOwner(clientMSPID); err != nil {
+			return nil, err
+		}
+		version, owner = existing[len(existing)-1].Version+1, existing[0].owner()
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &SpecificationSet{
+		ProductTypeID:  productTypeID,
+		Version:        version,
+		Status:         SpecSetActive,
+		Description:    description,
+		Specifications: specs,
+		OwnerMSP:       owner,
+		PublishedByMSP: clientMSPID,
+		PublishedAt:    clk.timestamp(),
+		PublishedTxID:  clk.txID,
+
+		TransportSpecifications: transportSpecs,
+	}
+	if err := c.putSpecificationSet(ctx, set); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[PublishSpecificationSet-INFO] %s v%d veröffentlicht (%d Spezifikationen, %d Transportgrenzwerte).\n", productTypeID, version, len(specs), len(transportSpecs))
+	return set, nil
+}
+
+// RetireSpecificationSet: Zieht eine Version zurück (nur durch den Eigentümer des Produkttyps).
+// Bereits angelegte DPPs behalten ihre Spezifikationen; neue DPPs können die Version nicht mehr
+// referenzieren.
+func (c *DPPQualityContract) RetireSpecificationSet(ctx contractapi.TransactionContextInterface, productTypeID string, version int, reason string) error {
+	set, err := c.getSpecificationSet(ctx, productTypeID, version)
+	if err != nil {
+		return err
+	}
+	if set.Status == SpecSetRetired {
+		return fmt.Errorf("Spezifikationssatz %s v%d ist bereits zurückgezogen", productTypeID, version)
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Fehler beim Ermitteln der Client MSPID: %v", err)
+	}
+	first := set
+	if version != 1 {
+		if first, err = c.getSpecificationSet(ctx, productTypeID, 1); err != nil {
+			return err
+		}
+	}
+	if err := first.checkOwner(clientMSPID); err != nil {
+		return err
+	}
+	clk, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
+	set.Status = SpecSetRetired
+	set.RetiredByMSP = clientMSPID
+	set.RetiredAt = clk.timestamp()
+	set.RetirementReason = reason
+	return c.putSpecificationSet(ctx, set)
+}
+
+// resolveSpecificationSet liefert den aktiven Spezifikationssatz für einen neuen DPP.
+// version == 0 bedeutet: höchste aktive Version.
+func (c *DPPQualityContract) resolveSpecificationSet(ctx contractapi.TransactionContextInterface, productTypeID string, version int) (*SpecificationSet, error) {
+	if productTypeID == "" {
+		return nil, fmt.Errorf("productTypeID ist erforderlich, um den Spezifikationssatz aufzulösen")
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("ungültige Spezifikationsversion %d", version)
+	}
+	if version > 0 {
+		set, err := c.getSpecificationSet(ctx, productTypeID, version)
+		if err != nil {
+			return nil, err
+		}
+		if set.Status != SpecSetActive {
+			return nil, fmt.Errorf("Spezifikationssatz %s v%d ist zurückgezogen (%s)", productTypeID, version, set.RetirementReason)
+		}
+		return set, nil
+	}
+
+	sets, err := c.ListSpecificationSets(ctx, productTypeID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(sets) - 1; i >= 0; i-- {
+		if sets[i].Status == SpecSetActive {
+			return sets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("kein aktiver Spezifikationssatz für Produkttyp %s im Katalog", productTypeID)
+}
+
+// specSetRef: Lesbare Referenz, z.B. für Event-Extensions ("PT-COMPOUND@v3").
+func specSetRef(set *SpecificationSet) string {
+	return set.ProductTypeID + "@v" + strconv.Itoa(set.Version)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpecificationSetOwnership(t *testing.T) {
+	e := newTestEnv(t)
+	c := &DPPQualityContract{}
+	v1, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-O", retestSpecs, "", "")
+	e.must(err)
+	if v1.OwnerMSP != "Org1MSP" {
+		t.Fatalf("OwnerMSP %q, erwartet Org1MSP", v1.OwnerMSP)
+	}
+
+	if _, err := c.PublishSpecificationSet(e.ctx("Org2MSP"), "PT-O", retestSpecs, "", "fremd"); err == nil || !strings.Contains(err.Error(), "gehört Org1MSP") {
+		t.Errorf("Veröffentlichung durch Org2MSP: Fehler %v", err)
+	}
+	if err := c.RetireSpecificationSet(e.ctx("Org2MSP"), "PT-O", 1, "fremd"); err == nil || !strings.Contains(err.Error(), "gehört Org1MSP") {
+		t.Errorf("Rückzug durch Org2MSP: Fehler %v", err)
+	}
+	set, err := c.resolveSpecificationSet(e.ctx("Org3MSP"), "PT-O", 0)
+	e.must(err)
+	if set.Version != 1 || set.Status != SpecSetActive {
+		t.Errorf("aufgelöst v%d (%s), erwartet aktive v1", set.Version, set.Status)
+	}
+
+	v2, err := c.PublishSpecificationSet(e.ctx("Org1MSP"), "PT-O", retestSpecs, "", "")
+	e.must(err)
+	if v2.Version != 2 || v2.OwnerMSP != "Org1MSP" {
+		t.Errorf("v%d mit OwnerMSP %q", v2.Version, v2.OwnerMSP)
+	}
+	e.must(c.RetireSpecificationSet(e.ctx("Org1MSP"), "PT-O", 2, "ersetzt"))
+
+	// Ein anderer Produkttyp kann einer anderen Organisation gehören.
+	_, err = c.PublishSpecificationSet(e.ctx("Org2MSP"), "PT-O2", retestSpecs, "", "")
+	e.must(err)
+}
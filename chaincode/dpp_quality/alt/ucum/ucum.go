@@ -0,0 +1,353 @@
+// Package ucum liest Einheiten als UCUM-Codes (Unified Code for Units of Measure, case
+// sensitive) und rechnet Werte zwischen Einheiten gleicher Dimension um.
+//
+// Unterstützt wird die für Qualitäts- und Transportdaten übliche Teilmenge: SI-Basiseinheiten
+// mit Präfixen, abgeleitete Einheiten (L, Pa, bar, N, J, W, …), Zeit (min, h, d), Prozent und
+// ppm, einige angloamerikanische Einheiten sowie Cel und [degF]. Ausdrücke dürfen "." und "/",
+// Exponenten ("cm3", "s-2"), Klammern, ganzzahlige Faktoren ("g/(10.min)") und Annotationen
+// ("{Stück}") enthalten. Abweichend von UCUM werden Leerzeichen ignoriert und ein Faktor
+// direkt vor der Einheit ("g/10 min", "g/10min") akzeptiert, wie er in den MFI-Daten üblich ist.
+package ucum
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrIncompatible: Die Einheiten haben unterschiedliche Dimensionen.
+var ErrIncompatible = errors.New("Einheiten sind nicht ineinander umrechenbar")
+
+// Dimensionen: Länge, Masse, Zeit, Temperatur, Stoffmenge, Stromstärke, Lichtstärke.
+const dimCount = 7
+
+type dimensions [dimCount]int
+
+// Unit: Geparste Einheit. Wert in Basiseinheiten = Wert * Factor + Offset.
+type Unit struct {
+	Code   string
+	Factor float64
+	dims   dimensions
+	Offset float64 // nur Cel und [degF]
+	affine bool
+}
+
+type atom struct {
+	factor float64
+	dims   dimensions
+	metric bool // darf Präfixe tragen
+	offset float64
+	affine bool
+}
+
+func dim(l, m, t, th, n, i, j int) dimensions { return dimensions{l, m, t, th, n, i, j} }
+
+var (
+	dimless  = dimensions{}
+	length   = dim(1, 0, 0, 0, 0, 0, 0)
+	mass     = dim(0, 1, 0, 0, 0, 0, 0)
+	duration = dim(0, 0, 1, 0, 0, 0, 0)
+	temp     = dim(0, 0, 0, 1, 0, 0, 0)
+	volume   = dim(3, 0, 0, 0, 0, 0, 0)
+	pressure = dim(-1, 1, -2, 0, 0, 0, 0)
+	force    = dim(1, 1, -2, 0, 0, 0, 0)
+	energy   = dim(2, 1, -2, 0, 0, 0, 0)
+	power    = dim(2, 1, -3, 0, 0, 0, 0)
+	accel    = dim(1, 0, -2, 0, 0, 0, 0)
+)
+
+// atoms: Basiseinheiten sind m, g, s, K, mol, A, cd (wie UCUM mit g statt kg).
+var atoms = map[string]atom{
+	"m":   {factor: 1, dims: length, metric: true},
+	"g":   {factor: 1, dims: mass, metric: true},
+	"s":   {factor: 1, dims: duration, metric: true},
+	"K":   {factor: 1, dims: temp, metric: true},
+	"mol": {factor: 1, dims: dim(0, 0, 0, 0, 1, 0, 0), metric: true},
+	"A":   {factor: 1, dims: dim(0, 0, 0, 0, 0, 1, 0), metric: true},
+	"cd":  {factor: 1, dims: dim(0, 0, 0, 0, 0, 0, 1), metric: true},
+
+	"L":   {factor: 1e-3, dims: volume, metric: true},
+	"l":   {factor: 1e-3, dims: volume, metric: true},
+	"t":   {factor: 1e6, dims: mass, metric: true},
+	"Pa":  {factor: 1e3, dims: pressure, metric: true},
+	"bar": {factor: 1e8, dims: pressure, metric: true},
+	"N":   {factor: 1e3, dims: force, metric: true},
+	"J":   {factor: 1e3, dims: energy, metric: true},
+	"W":   {factor: 1e3, dims: power, metric: true},
+	"Hz":  {factor: 1, dims: dim(0, 0, -1, 0, 0, 0, 0), metric: true},
+	"V":   {factor: 1e3, dims: dim(2, 1, -3, 0, 0, -1, 0), metric: true},
+
+	"min": {factor: 60, dims: duration},
+	"h":   {factor: 3600, dims: duration},
+	"d":   {factor: 86400, dims: duration},
+
+	"%":     {factor: 1e-2, dims: dimless},
+	"[ppm]": {factor: 1e-6, dims: dimless},
+	"[ppb]": {factor: 1e-9, dims: dimless},
+
+	"[in_i]":  {factor: 0.0254, dims: length},
+	"[ft_i]":  {factor: 0.3048, dims: length},
+	"[lb_av]": {factor: 453.59237, dims: mass},
+	"[oz_av]": {factor: 28.349523125, dims: mass},
+	"[psi]":   {factor: 6894.757293168361e3, dims: pressure},
+	"[g]":     {factor: 9.80665, dims: accel},
+
+	"Cel":    {factor: 1, dims: temp, offset: 273.15, affine: true},
+	"[degF]": {factor: 5.0 / 9, dims: temp, offset: 459.67 * 5.0 / 9, affine: true},
+}
+
+// prefixes: UCUM-Präfixe; "da" ist der einzige zweistellige.
+var prefixes = map[string]float64{
+	"Y": 1e24, "Z": 1e21, "E": 1e18, "P": 1e15, "T": 1e12, "G": 1e9, "M": 1e6, "k": 1e3, "h": 1e2, "da": 1e1,
+	"d": 1e-1, "c": 1e-2, "m": 1e-3, "u": 1e-6, "n": 1e-9, "p": 1e-12, "f": 1e-15, "a": 1e-18,
+}
+
+// Parse liest einen UCUM-Code.
+func Parse(code string) (*Unit, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("leere Einheit")
+	}
+	p := &parser{code: strings.ReplaceAll(code, " ", "")}
+	u, err := p.term()
+	if err != nil {
+		return nil, fmt.Errorf("Einheit '%s' ist kein gültiger UCUM-Code: %v", code, err)
+	}
+	if p.pos < len(p.code) {
+		return nil, fmt.Errorf("Einheit '%s' ist kein gültiger UCUM-Code: unerwartetes Zeichen '%c'", code, p.code[p.pos])
+	}
+	u.Code = code
+	return u, nil
+}
+
+// Compatible: Beide Einheiten haben dieselbe Dimension.
+func (u *Unit) Compatible(other *Unit) bool {
+	return u.dims == other.dims
+}
+
+// Convert rechnet value von der Einheit from in die Einheit to um. Das Ergebnis wird auf
+// 12 signifikante Stellen gerundet, damit Rundungsfehler der Faktoren (1200 kg/m3 =
+// 1.2000000000000002 g/cm3) keine Grenzwertprüfung verfälschen.
+func Convert(value float64, from, to string) (float64, error) {
+	src, err := Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	if !src.Compatible(dst) {
+		return 0, fmt.Errorf("%w: '%s' und '%s'", ErrIncompatible, from, to)
+	}
+	base := value*src.Factor + src.Offset
+	converted := (base - dst.Offset) / dst.Factor
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(converted, 'g', 12, 64), 64)
+	if err != nil || math.IsInf(rounded, 0) || math.IsNaN(rounded) {
+		return 0, fmt.Errorf("Umrechnung von %v %s in %s ergibt keinen gültigen Wert", value, from, to)
+	}
+	return rounded, nil
+}
+
+type parser struct {
+	code string
+	pos  int
+}
+
+// term := ["/"] component (("." | "/") component)*
+func (p *parser) term() (*Unit, error) {
+	result := &Unit{Factor: 1}
+	divide := false
+	if p.peek() == '/' {
+		p.pos++
+		divide = true
+	}
+	for first := true; ; first = false {
+		if !first {
+			switch p.peek() {
+			case '.':
+				divide = false
+			case '/':
+				divide = true
+			default:
+				return result, nil
+			}
+			p.pos++
+		}
+		comp, err := p.component()
+		if err != nil {
+			return nil, err
+		}
+		if err := result.combine(comp, divide); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// component := "(" term ")" | Faktor [Einheit] | Einheit [Exponent] | Annotation
+func (p *parser) component() (*Unit, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		u, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("')' fehlt")
+		}
+		p.pos++
+		return u, p.skipAnnotation()
+	case c == '{':
+		return &Unit{Factor: 1}, p.skipAnnotation()
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.code) && p.code[p.pos] >= '0' && p.code[p.pos] <= '9' {
+			p.pos++
+		}
+		factor, _ := strconv.ParseFloat(p.code[start:p.pos], 64)
+		u := &Unit{Factor: factor}
+		if p.pos < len(p.code) && isSymbolChar(p.code[p.pos]) {
+			rest, err := p.annotatable()
+			if err != nil {
+				return nil, err
+			}
+			if err := u.combine(rest, false); err != nil {
+				return nil, err
+			}
+		}
+		return u, p.skipAnnotation()
+	case c == 0:
+		return nil, fmt.Errorf("Einheit fehlt am Ende")
+	}
+	u, err := p.annotatable()
+	if err != nil {
+		return nil, err
+	}
+	return u, p.skipAnnotation()
+}
+
+// annotatable := Symbol [["+" | "-"] Ziffern]
+func (p *parser) annotatable() (*Unit, error) {
+	start := p.pos
+	if p.peek() == '[' {
+		end := strings.IndexByte(p.code[p.pos:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("']' fehlt")
+		}
+		p.pos += end + 1
+	} else {
+		for p.pos < len(p.code) && isSymbolChar(p.code[p.pos]) {
+			p.pos++
+		}
+	}
+	symbol := p.code[start:p.pos]
+	if symbol == "" {
+		return nil, fmt.Errorf("Einheit erwartet an Position %d", start+1)
+	}
+	u, err := lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	expStart := p.pos
+	if c := p.peek(); c == '+' || c == '-' {
+		p.pos++
+	}
+	digitsStart := p.pos
+	for p.pos < len(p.code) && p.code[p.pos] >= '0' && p.code[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == digitsStart {
+		p.pos = expStart
+		return u, nil
+	}
+	exp, err := strconv.Atoi(p.code[expStart:p.pos])
+	if err != nil {
+		return nil, fmt.Errorf("ungültiger Exponent '%s'", p.code[expStart:p.pos])
+	}
+	return u.pow(exp)
+}
+
+func (p *parser) skipAnnotation() error {
+	if p.peek() != '{' {
+		return nil
+	}
+	end := strings.IndexByte(p.code[p.pos:], '}')
+	if end < 0 {
+		return fmt.Errorf("'}' fehlt")
+	}
+	p.pos += end + 1
+	return nil
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.code) {
+		return 0
+	}
+	return p.code[p.pos]
+}
+
+func isSymbolChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '%' || c == '_'
+}
+
+// lookup: Einheit ohne Präfix hat Vorrang ("min", "cd", "Pa"), sonst Präfix + metrische Einheit.
+func lookup(symbol string) (*Unit, error) {
+	if a, ok := atoms[symbol]; ok {
+		return &Unit{Factor: a.factor, dims: a.dims, Offset: a.offset, affine: a.affine}, nil
+	}
+	for _, n := range []int{2, 1} {
+		if len(symbol) <= n {
+			continue
+		}
+		factor, ok := prefixes[symbol[:n]]
+		if !ok {
+			continue
+		}
+		if a, ok := atoms[symbol[n:]]; ok && a.metric {
+			return &Unit{Factor: factor * a.factor, dims: a.dims}, nil
+		}
+	}
+	return nil, fmt.Errorf("unbekannte Einheit '%s'", symbol)
+}
+
+func (u *Unit) pow(exp int) (*Unit, error) {
+	if u.affine && exp != 1 {
+		return nil, fmt.Errorf("Temperaturskalen (Cel, [degF]) können keinen Exponenten haben")
+	}
+	result := &Unit{Factor: math.Pow(u.Factor, float64(exp)), Offset: u.Offset, affine: u.affine}
+	for i := range u.dims {
+		result.dims[i] = u.dims[i] * exp
+	}
+	return result, nil
+}
+
+// combine multipliziert (oder dividiert) u mit other. Temperaturskalen mit Nullpunktverschiebung
+// sind nur als alleinstehende Einheit zulässig.
+func (u *Unit) combine(other *Unit, divide bool) error {
+	if other.affine || u.affine {
+		if u.isOne() && !divide {
+			*u = *other
+			return nil
+		}
+		return fmt.Errorf("Temperaturskalen (Cel, [degF]) sind in zusammengesetzten Einheiten nicht zulässig, K verwenden")
+	}
+	sign := 1
+	if divide {
+		sign = -1
+		u.Factor /= other.Factor
+	} else {
+		u.Factor *= other.Factor
+	}
+	for i := range u.dims {
+		u.dims[i] += sign * other.dims[i]
+	}
+	return nil
+}
+
+func (u *Unit) isOne() bool {
+	return u.Factor == 1 && u.dims == dimless && !u.affine
+}
@@ -0,0 +1,54 @@
+package ucum
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{1200, "kg/m3", "g/cm3", 1.2},
+		{0.95, "g/cm3", "kg/m3", 950},
+		{0, "Cel", "K", 273.15},
+		{25, "Cel", "K", 298.15},
+		{300, "K", "Cel", 26.85},
+		{212, "[degF]", "Cel", 100},
+		{1.5, "bar", "kPa", 150},
+		{12, "g/10 min", "g/10min", 12},
+		{12, "g/10min", "g/h", 72},
+		{250, "[ppm]", "%", 0.025},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%v, %s, %s): %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Convert(%v, %s, %s) = %v, erwartet %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertIncompatible(t *testing.T) {
+	for _, units := range [][2]string{{"kg/m3", "g/10min"}, {"Cel", "bar"}, {"%", "g"}} {
+		_, err := Convert(1, units[0], units[1])
+		if !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Convert(1, %s, %s): Fehler %v, erwartet ErrIncompatible", units[0], units[1], err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, code := range []string{"", "kgx", "g/(10.min", "m^2", "[foo]"} {
+		if _, err := Parse(code); err == nil {
+			t.Errorf("Parse(%q): Fehler erwartet", code)
+		} else if errors.Is(err, ErrIncompatible) {
+			t.Errorf("Parse(%q): Syntaxfehler als ErrIncompatible gemeldet", code)
+		}
+	}
+}